@@ -5,12 +5,14 @@ import (
 	"log"
 
 	"datapi/pkg/ops/campaignops"
+	"datapi/pkg/ops/cardrules"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 
 	"datapi/pkg/campaign"
 	"datapi/pkg/core"
+	"datapi/pkg/db"
 	"datapi/pkg/health"
 	"datapi/pkg/kanban"
 	"datapi/pkg/ops/imports"
@@ -28,18 +30,35 @@ func main() {
 	}
 	utils.ConfigureLogLevel(viper.GetString("log.level"))
 	ctx := context.Background()
-	kanbanService := initWekanService(ctx)
+	// fail fast, et le service kanban postgres trouve ses tables migrées dès son premier chargement
+	db.Init()
+	kanbanService := initKanbanService(ctx)
 	saver := buildLogSaver()
+	reader := buildLogReader()
 	statsAPI := buildStatsAPI()
 
-	datapi, err := core.PrepareDatapi(kanbanService, saver.SaveLogToDB)
+	datapi, err := core.PrepareDatapi(kanbanService, saver.SaveLogToDB, reader)
 	if err != nil {
 		log.Println("erreur pendant le démarrage de Datapi : ", err)
 	}
+	if viper.GetBool("campaign.reminders.enabled") {
+		campaign.StartReminders(ctx)
+	}
 	initAndStartAPI(datapi, statsAPI)
 }
 
-func initWekanService(ctx context.Context) core.KanbanService {
+// initKanbanService démarre le service kanban choisi par kanbanBackend : "wekan" (par défaut), "postgres" ou "memory"
+func initKanbanService(ctx context.Context) core.KanbanService {
+	switch viper.GetString("kanbanBackend") {
+	case "postgres":
+		return kanban.InitPostgresService(ctx, viper.GetString("wekanSlugDomainRegexp"))
+	case "memory":
+		service, err := kanban.InitMemoryService(viper.GetString("kanbanFixture"))
+		if err != nil {
+			log.Fatalf("erreur pendant le chargement de la fixture kanban : %s", err)
+		}
+		return service
+	}
 	return kanban.InitService(ctx,
 		viper.GetString("wekanMgoURL"),
 		viper.GetString("wekanMgoDB"),
@@ -52,10 +71,13 @@ func initAndStartAPI(datapi *core.Datapi, statsAPI *stats.API) {
 	datapi.InitAPI(router)
 	needRoleStats := core.CheckAllRolesMiddleware("stats")
 	core.AddEndpoint(router, "/ops/utils", misc.ConfigureEndpoint, core.AdminAuthMiddleware)
-	core.AddEndpoint(router, "/ops/imports", imports.ConfigureEndpoint, core.AdminAuthMiddleware)
+	scripts.AddFinishHook(cardrules.RefreshHook(datapi.KanbanService))
+	core.AddEndpoint(router, "/ops/imports", imports.ConfigureEndpoint, core.AdminAuthMiddleware, cardrules.ImportsMiddleware(datapi.KanbanService))
 	core.AddEndpoint(router, "/ops/scripts", scripts.ConfigureEndpoint, core.AdminAuthMiddleware)
+	core.AddEndpoint(router, "/ops/cardrules", cardrules.ConfigureEndpoint(datapi.KanbanService), core.AdminAuthMiddleware)
 	core.AddEndpoint(router, "/ops/campaign", campaignops.ConfigureEndpoint(datapi.KanbanService), core.AdminAuthMiddleware)
 	core.AddEndpoint(router, "/campaign", campaign.ConfigureEndpoint(datapi.KanbanService), core.AuthMiddleware(), datapi.LogMiddleware)
+	core.AddEndpoint(router, "/kanban/stats", kanban.ConfigureStatsEndpoint(datapi.KanbanService), core.AuthMiddleware(), datapi.LogMiddleware, core.CheckAnyRolesMiddleware("wekan"))
 	core.AddEndpoint(router, "/stats", statsAPI.ConfigureEndpoint, core.AuthMiddleware(), datapi.LogMiddleware, needRoleStats)
 	core.AddEndpoint(router, "/healthcheck", health.ConfigureEndpoint)
 	core.StartAPI(router)
@@ -69,6 +91,14 @@ func buildLogSaver() *stats.PostgresLogSaver {
 	return saver
 }
 
+func buildLogReader() *stats.PostgresLogReader {
+	reader, err := stats.NewPostgresLogReaderFromURL(context.Background(), viper.GetString("stats.db_url"))
+	if err != nil {
+		log.Fatal("erreur pendant l'instanciation du AccessLogReader : ", err)
+	}
+	return reader
+}
+
 func buildStatsAPI() *stats.API {
 	statsModule, err := stats.NewAPIFromConfiguration(context.Background(), viper.GetString("stats.db_url"))
 	if err != nil {
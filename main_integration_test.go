@@ -64,13 +64,18 @@ func TestMain(m *testing.M) {
 		log.Fatalf("erreur pendant la création du AccessLogSaver : %s", err)
 	}
 
+	logReader, err := stats.NewPostgresLogReaderFromURL(ctx, test.GetDatapiLogsDBURL())
+	if err != nil {
+		log.Fatalf("erreur pendant la création du AccessLogReader : %s", err)
+	}
+
 	statsAPI, err := stats.NewAPIFromConfiguration(ctx, test.GetDatapiLogsDBURL())
 
 	if err != nil {
 		log.Fatalf("erreur pendant la création de l'API Stats : %s", err)
 	}
 
-	datapi, err := core.PrepareDatapi(kanbanService, logSaver.SaveLogToDB)
+	datapi, err := core.PrepareDatapi(kanbanService, logSaver.SaveLogToDB, logReader)
 	if err != nil {
 		log.Printf("Erreur pendant le démarrage de Datapi : %s", err)
 	}
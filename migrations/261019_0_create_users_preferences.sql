@@ -0,0 +1,5 @@
+create table users_preferences (
+  username    text primary key,
+  preferences jsonb not null default '{}',
+  date_update timestamp default current_timestamp
+);
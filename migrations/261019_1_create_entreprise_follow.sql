@@ -0,0 +1,35 @@
+create sequence entreprise_follow_id;
+create table entreprise_follow (
+  id                integer primary key default nextval('entreprise_follow_id'),
+  siren             varchar(9),
+  username          text,
+  active            boolean,
+  since             timestamp,
+  until             timestamp,
+  comment           text,
+  category          text,
+  unfollow_comment  text,
+  unfollow_category text
+);
+
+create index idx_entreprise_follow_siren_username on entreprise_follow (siren, username) where active;
+
+-- suit les établissements des entreprises suivies au niveau SIREN
+-- un établissement dont le suivi a été arrêté après le début du suivi de l'entreprise n'est pas suivi à nouveau
+create or replace function follow_entreprise_etablissements(in p_siren text default null) returns integer as $$
+  with inserted as (
+    insert into etablissement_follow (siret, siren, username, active, since, comment, category)
+    select e.siret, e.siren, f.username, true, current_timestamp, f.comment, f.category
+    from entreprise_follow f
+      inner join etablissement0 e on e.siren = f.siren
+    where f.active
+      and (f.siren = p_siren or p_siren is null)
+      and not exists (
+        select 1 from etablissement_follow ef
+        where ef.siret = e.siret and ef.username = f.username
+          and (ef.active or ef.until > f.since)
+      )
+    returning 1
+  )
+  select count(*)::integer from inserted
+$$ language sql;
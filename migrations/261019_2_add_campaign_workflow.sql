@@ -0,0 +1,2 @@
+-- workflow déclaratif de la campagne, null correspond au workflow par défaut
+alter table campaign add column workflow jsonb;
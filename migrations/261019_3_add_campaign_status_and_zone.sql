@@ -0,0 +1,9 @@
+-- statut de la campagne (open, closed, archived) et restriction facultative à des départements
+alter table campaign add column status text not null default 'open';
+alter table campaign add column zone text[];
+
+-- une campagne ouverte dont la date de fin est passée est considérée close
+create or replace function campaign_status(p_status text, p_date_end date) returns text as
+$$
+select case when p_status = 'open' and p_date_end < current_date then 'closed' else p_status end
+$$ language sql stable;
@@ -0,0 +1,9 @@
+-- cartes wekan créées depuis une campagne (via upsertcard)
+create table if not exists campaign_card (
+  id_campaign_etablissement integer references campaign_etablissement (id),
+  card_id                   text,
+  username                  text,
+  date_create               timestamp default current_timestamp
+);
+
+create index idx_campaign_card_id_campaign_etablissement on campaign_card (id_campaign_etablissement);
@@ -0,0 +1,10 @@
+-- journal des évènements diffusés sur le flux des campagnes, permet le rejeu à la reconnexion
+create sequence if not exists campaign_event_id;
+create table if not exists campaign_event (
+  id          bigint primary key default nextval('campaign_event_id'),
+  id_campaign integer,
+  message     jsonb,
+  date_event  timestamp default current_timestamp
+);
+
+create index idx_campaign_event_id_campaign on campaign_event (id_campaign, id);
@@ -0,0 +1,16 @@
+-- rappels envoyés pour les campagnes, un rappel n'est envoyé qu'une fois par prise en charge
+-- ou une fois par département à l'approche de la fin de campagne
+create sequence if not exists campaign_reminder_id;
+create table if not exists campaign_reminder (
+  id                               integer primary key default nextval('campaign_reminder_id'),
+  id_campaign                      integer,
+  kind                             text,
+  id_campaign_etablissement_action integer,
+  code_departement                 text,
+  date_reminder                    timestamp default current_timestamp
+);
+
+create unique index idx_campaign_reminder_overdue on campaign_reminder (id_campaign_etablissement_action)
+  where kind = 'overdue';
+create unique index idx_campaign_reminder_ending on campaign_reminder (id_campaign, code_departement)
+  where kind = 'ending';
@@ -0,0 +1,20 @@
+-- rang et commentaire facultatifs fournis lors de l'import d'un fichier de sirets
+alter table campaign_etablissement add column if not exists rank integer;
+alter table campaign_etablissement add column if not exists comment text;
+
+-- historique des fichiers importés dans les campagnes
+-- le rapport de validation est conservé pour être appliqué après confirmation de l'utilisateur
+create sequence if not exists campaign_import_id;
+create table if not exists campaign_import (
+  id          integer primary key default nextval('campaign_import_id'),
+  id_campaign integer references campaign (id),
+  username    text,
+  filename    text,
+  report      jsonb,
+  status      text,
+  nb_inserted integer,
+  date_upload timestamp default current_timestamp,
+  date_apply  timestamp
+);
+
+create index idx_campaign_import_id_campaign on campaign_import (id_campaign, id);
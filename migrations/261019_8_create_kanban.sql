@@ -0,0 +1,115 @@
+-- stockage des tableaux kanban dans postgres, alternative à la base mongodb de wekan
+-- les identifiants reprennent ceux de wekan pour permettre la migration des données existantes
+create table if not exists kanban_user (
+  id          text primary key,
+  username    text not null unique,
+  fullname    text,
+  created_at  timestamptz default current_timestamp
+);
+
+create table if not exists kanban_board (
+  id          text primary key,
+  title       text not null,
+  slug        text not null,
+  archived    boolean default false,
+  created_at  timestamptz default current_timestamp,
+  modified_at timestamptz default current_timestamp
+);
+
+create table if not exists kanban_board_member (
+  board_id  text references kanban_board (id),
+  user_id   text references kanban_user (id),
+  is_active boolean default true,
+  is_admin  boolean default false,
+  primary key (board_id, user_id)
+);
+
+-- le titre des couloirs porte le code département ou le nom de région, comme dans wekan
+create table if not exists kanban_swimlane (
+  id       text primary key,
+  board_id text references kanban_board (id),
+  title    text not null,
+  sort     double precision default 0,
+  archived boolean default false
+);
+
+create table if not exists kanban_list (
+  id       text primary key,
+  board_id text references kanban_board (id),
+  title    text not null,
+  sort     double precision default 0,
+  archived boolean default false
+);
+
+create table if not exists kanban_label (
+  id       text,
+  board_id text references kanban_board (id),
+  name     text not null,
+  color    text,
+  primary key (board_id, id)
+);
+
+-- champs personnalisés des cartes (SIRET, Activité, Effectif, Contact, Fiche Signaux Faibles)
+create table if not exists kanban_custom_field (
+  id       text,
+  board_id text references kanban_board (id),
+  name     text not null,
+  type     text,
+  settings jsonb,
+  primary key (board_id, id)
+);
+
+-- le siret est dupliqué depuis les champs personnalisés pour permettre son indexation
+-- couloir et liste ne sont pas contraints, les cartes migrées de wekan peuvent référencer des listes archivées
+create table if not exists kanban_card (
+  id                 text primary key,
+  board_id           text references kanban_board (id),
+  swimlane_id        text,
+  list_id            text,
+  siret              text,
+  title              text,
+  description        text default '',
+  user_id            text,
+  members            text[] default '{}',
+  assignees          text[] default '{}',
+  label_ids          text[] default '{}',
+  custom_fields      jsonb default '[]',
+  sort               double precision default 0,
+  archived           boolean default false,
+  created_at         timestamptz default current_timestamp,
+  modified_at        timestamptz default current_timestamp,
+  date_last_activity timestamptz default current_timestamp,
+  start_at           timestamptz,
+  end_at             timestamptz
+);
+
+create index if not exists idx_kanban_card_siret on kanban_card (siret);
+create index if not exists idx_kanban_card_board_id on kanban_card (board_id, list_id);
+
+create table if not exists kanban_comment (
+  id          text primary key,
+  board_id    text references kanban_board (id),
+  card_id     text references kanban_card (id),
+  user_id     text,
+  text        text,
+  created_at  timestamptz default current_timestamp,
+  modified_at timestamptz default current_timestamp
+);
+
+create index if not exists idx_kanban_comment_card_id on kanban_comment (card_id);
+
+-- historique des cartes, utilisé pour retracer les périodes d'accompagnement
+create sequence if not exists kanban_activity_id;
+create table if not exists kanban_activity (
+  id            integer primary key default nextval('kanban_activity_id'),
+  card_id       text references kanban_card (id),
+  board_id      text,
+  user_id       text,
+  member_id     text,
+  activity_type text not null,
+  list_id       text,
+  old_list_id   text,
+  created_at    timestamptz default current_timestamp
+);
+
+create index if not exists idx_kanban_activity_card_id on kanban_activity (card_id, created_at);
@@ -0,0 +1,3 @@
+-- historique des étiquettes et des commentaires des cartes
+alter table kanban_activity add column if not exists label_id text;
+alter table kanban_activity add column if not exists comment_id text;
@@ -0,0 +1,20 @@
+-- listes de contrôle des cartes, ajoutées à la création d'après le modèle du tableau
+create table if not exists kanban_checklist (
+  id         text primary key,
+  card_id    text references kanban_card (id),
+  user_id    text,
+  title      text not null,
+  sort       double precision default 0,
+  created_at timestamptz default current_timestamp
+);
+
+create table if not exists kanban_checklist_item (
+  id           text primary key,
+  checklist_id text references kanban_checklist (id),
+  card_id      text references kanban_card (id),
+  title        text not null,
+  sort         double precision default 0,
+  is_finished  boolean default false
+);
+
+create index if not exists idx_kanban_checklist_card_id on kanban_checklist (card_id);
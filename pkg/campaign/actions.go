@@ -3,7 +3,6 @@ package campaign
 import (
 	"context"
 	"datapi/pkg/core"
-	"datapi/pkg/db"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/signaux-faibles/libwekan"
@@ -20,24 +19,18 @@ func doAction(ctx context.Context,
 	username string,
 	action Action,
 ) (Message, error) {
-	var campaignEtablissementActionID *int
-	var codeDepartement *string
-	zone := zoneForUser(username)
-	dbConn := db.Get()
-	row := dbConn.QueryRow(ctx, sqlActionMyEtablissement, ids.CampaignID,
-		ids.CampaignEtablissementID, username, zone, action.action, action.detail)
-	err := row.Scan(&campaignEtablissementActionID, &codeDepartement)
-	if campaignEtablissementActionID == nil || codeDepartement == nil {
-		return Message{}, TakeNotFoundError{err: errors.New("aucun id retourné par l'insert")}
+	codeDepartement, err := applyAction(ctx, ids, username, action)
+	if errors.As(err, &CampaignEtablissementNotFoundError{}) {
+		return Message{}, TakeNotFoundError{err: err}
 	} else if err != nil {
 		return Message{}, err
 	}
 	message := Message{
-		ids.CampaignID,
-		&ids.CampaignEtablissementID,
-		[]string{*codeDepartement},
-		action.action,
-		string(username),
+		CampaignID:              ids.CampaignID,
+		CampaignEtablissementID: &ids.CampaignEtablissementID,
+		Zone:                    []string{codeDepartement},
+		Type:                    action.action,
+		Username:                string(username),
 	}
 	return message, nil
 }
@@ -51,9 +44,7 @@ func actionHandlerFunc(actionLabel string, kanbanService core.KanbanService) fun
 		err := ctx.Bind(&params)
 		if err != nil {
 			ctx.JSON(http.StatusBadRequest, "décodage de la requete impossible: "+err.Error())
-		}
-		if params.Detail == "" {
-			ctx.JSON(http.StatusBadRequest, "le paramètre detail doit contenir au moins 1 caractère")
+			return
 		}
 
 		campaignID, err := strconv.Atoi(ctx.Param("campaignID"))
@@ -87,6 +78,9 @@ func actionHandlerFunc(actionLabel string, kanbanService core.KanbanService) fun
 		if errors.As(err, &TakeNotFoundError{}) {
 			ctx.JSON(http.StatusUnprocessableEntity, "traitement indisponible pour cet établissement")
 			return
+		} else if errors.As(err, &IllegalTransitionError{}) || errors.As(err, &CampaignClosedError{}) {
+			ctx.JSON(http.StatusUnprocessableEntity, err.Error())
+			return
 		} else if err != nil {
 			ctx.JSON(http.StatusInternalServerError, "erreur innattendue")
 			return
@@ -7,7 +7,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/signaux-faibles/libwekan"
 	"net/http"
-	"strconv"
 )
 
 type AddedSirets struct {
@@ -37,18 +36,16 @@ func (addedSirets AddedSirets) Tuple() []interface{} {
 func addSiretsHandler(c *gin.Context) {
 	var s core.Session
 	s.Bind(c)
-	campaignIDParam := c.Param("campaignID")
-	campaignID, err := strconv.Atoi(campaignIDParam)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, "campaignID doit être un nombre entier")
+	campaignID, ok := openCampaignFromContext(c)
+	if !ok {
 		return
 	}
 	var params CheckSiretsParams
-	err = c.Bind(&params)
+	err := c.Bind(&params)
 	if err != nil {
 		return
 	}
-	message, err := AddSirets(c, CampaignID(campaignID), params.Sirets, s.Username)
+	message, err := AddSirets(c, db.Get(), campaignID, params.Sirets, s.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, err.Error())
 		return
@@ -57,12 +54,12 @@ func addSiretsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, message)
 }
 
-func AddSirets(ctx context.Context, campaignID CampaignID, sirets []core.Siret, username string) (Message, error) {
+func AddSirets(ctx context.Context, q db.Querier, campaignID CampaignID, sirets []core.Siret, username string) (Message, error) {
 	var addedSirets AddedSirets
 	addedSirets.Sirets = make([]*AddedSiret, 0)
 	boards := core.Kanban.SelectBoardsForUsername(libwekan.Username(username))
 	zones := zonesFromBoards(boards)
-	err := db.Scan(ctx, &addedSirets, sqlAddSirets, campaignID, sirets, zones, username)
+	err := db.SelectTuples(ctx, q, &addedSirets, sqlAddSirets, campaignID, sirets, zones, username)
 	message := Message{
 		CampaignID: campaignID,
 		Zone:       zoneFromBoardZones(zones),
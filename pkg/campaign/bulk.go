@@ -0,0 +1,179 @@
+package campaign
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const maxBulkSize = 500
+
+type bulkParams struct {
+	Action                   string                    `json:"action"`
+	Detail                   string                    `json:"detail"`
+	CampaignEtablissementIDs []CampaignEtablissementID `json:"campaignEtablissementIDs"`
+}
+
+type assignParams struct {
+	Username                 string                    `json:"username"`
+	CampaignEtablissementIDs []CampaignEtablissementID `json:"campaignEtablissementIDs"`
+}
+
+// BulkResult est le résultat du traitement d'un établissement dans une action groupée
+type BulkResult struct {
+	CampaignEtablissementID CampaignEtablissementID `json:"campaignEtablissementID"`
+	Ok                      bool                    `json:"ok"`
+	Error                   string                  `json:"error,omitempty"`
+}
+
+// bulkAction applique une action à un établissement et retourne le message à diffuser
+type bulkAction func(ctx context.Context, ids IDs) (Message, error)
+
+func bulkErrorMessage(err error) string {
+	if errors.As(err, &PendingNotFoundError{}) || errors.As(err, &TakeNotFoundError{}) {
+		return "établissement indisponible pour cette action"
+	}
+	if errors.As(err, &IllegalTransitionError{}) || errors.As(err, &CampaignClosedError{}) {
+		return err.Error()
+	}
+	slog.Error("erreur inattendue lors d'une action groupée", slog.Any("error", err))
+	return "erreur inattendue"
+}
+
+// applyBulk traite chaque établissement indépendamment, un message est publié par modification
+func applyBulk(ctx context.Context, campaignID CampaignID, campaignEtablissementIDs []CampaignEtablissementID, action bulkAction, publish func(Message)) []BulkResult {
+	results := make([]BulkResult, 0, len(campaignEtablissementIDs))
+	for _, campaignEtablissementID := range campaignEtablissementIDs {
+		result := BulkResult{CampaignEtablissementID: campaignEtablissementID}
+		message, err := action(ctx, IDs{campaignID, campaignEtablissementID})
+		if err != nil {
+			result.Error = bulkErrorMessage(err)
+		} else {
+			result.Ok = true
+			publish(message)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func publishToStream(message Message) {
+	stream.Message <- message
+}
+
+func bulkActionFor(username string, params bulkParams) (bulkAction, error) {
+	switch params.Action {
+	case "take":
+		return func(ctx context.Context, ids IDs) (Message, error) {
+			return take(ctx, ids, username)
+		}, nil
+	case "withdraw":
+		return func(ctx context.Context, ids IDs) (Message, error) {
+			return withdrawPending(ctx, ids, username, params.Detail)
+		}, nil
+	case "success", "cancel":
+		return func(ctx context.Context, ids IDs) (Message, error) {
+			return doAction(ctx, ids, username, Action{action: params.Action, detail: params.Detail})
+		}, nil
+	}
+	return nil, fmt.Errorf("action inconnue: %s", params.Action)
+}
+
+func checkBulkSize(c *gin.Context, campaignEtablissementIDs []CampaignEtablissementID) bool {
+	if len(campaignEtablissementIDs) == 0 || len(campaignEtablissementIDs) > maxBulkSize {
+		c.JSON(http.StatusBadRequest, fmt.Sprintf("la liste campaignEtablissementIDs doit contenir entre 1 et %d éléments", maxBulkSize))
+		return false
+	}
+	return true
+}
+
+func bulkHandler(c *gin.Context) {
+	var s core.Session
+	s.Bind(c)
+
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, `/campaign/bulk/:campaignID: le parametre campaignID doit être un entier`)
+		return
+	}
+	var params bulkParams
+	err = c.Bind(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "décodage de la requete impossible: "+err.Error())
+		return
+	}
+	if !checkBulkSize(c, params.CampaignEtablissementIDs) {
+		return
+	}
+	action, err := bulkActionFor(s.Username, params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := applyBulk(c, CampaignID(campaignID), params.CampaignEtablissementIDs, action, publishToStream)
+	c.JSON(http.StatusOK, results)
+}
+
+// intersectZones restreint la zone aux départements communs aux deux utilisateurs
+func intersectZones(zone Zone, other Zone) Zone {
+	return slices.DeleteFunc(slices.Clone(zone), func(departement string) bool {
+		return !slices.Contains(other, departement)
+	})
+}
+
+// takeOnBehalf attribue un établissement à assignee, l'établissement doit être dans la zone des deux utilisateurs
+func takeOnBehalf(ctx context.Context, ids IDs, assignee string, zone Zone, author string) (Message, error) {
+	codeDepartement, err := applyActionInZone(ctx, ids, assignee, zone, Action{action: "take", detail: "attribué par " + author})
+	if errors.As(err, &CampaignEtablissementNotFoundError{}) {
+		return Message{}, PendingNotFoundError{err: err}
+	} else if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		CampaignID:              ids.CampaignID,
+		CampaignEtablissementID: &ids.CampaignEtablissementID,
+		Zone:                    []string{codeDepartement},
+		Type:                    "pending",
+		Username:                assignee,
+	}, nil
+}
+
+func assignHandler(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var s core.Session
+		s.Bind(c)
+
+		campaignID, err := strconv.Atoi(c.Param("campaignID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, `/campaign/assign/:campaignID: le parametre campaignID doit être un entier`)
+			return
+		}
+		var params assignParams
+		err = c.Bind(&params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, "décodage de la requete impossible: "+err.Error())
+			return
+		}
+		if !checkBulkSize(c, params.CampaignEtablissementIDs) {
+			return
+		}
+		if _, ok := kanbanService.GetUser(libwekan.Username(params.Username)); !ok {
+			c.JSON(http.StatusBadRequest, "nom d'utilisateur non présent dans la base")
+			return
+		}
+
+		zone := intersectZones(zoneForUser(s.Username), zoneForUser(params.Username))
+		results := applyBulk(c, CampaignID(campaignID), params.CampaignEtablissementIDs, func(ctx context.Context, ids IDs) (Message, error) {
+			return takeOnBehalf(ctx, ids, params.Username, zone, s.Username)
+		}, publishToStream)
+		c.JSON(http.StatusOK, results)
+	}
+}
@@ -0,0 +1,45 @@
+package campaign
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyBulk_resultatParEtablissement(t *testing.T) {
+	ass := assert.New(t)
+	action := func(_ context.Context, ids IDs) (Message, error) {
+		switch ids.CampaignEtablissementID {
+		case 2:
+			return Message{}, PendingNotFoundError{err: errors.New("indisponible")}
+		case 3:
+			return Message{}, IllegalTransitionError{msg: "l'action take est impossible depuis l'état done"}
+		}
+		return Message{CampaignID: ids.CampaignID, CampaignEtablissementID: &ids.CampaignEtablissementID}, nil
+	}
+
+	var published []Message
+	results := applyBulk(context.Background(), 1, []CampaignEtablissementID{1, 2, 3}, action, func(message Message) {
+		published = append(published, message)
+	})
+
+	ass.Equal([]BulkResult{
+		{CampaignEtablissementID: 1, Ok: true},
+		{CampaignEtablissementID: 2, Error: "établissement indisponible pour cette action"},
+		{CampaignEtablissementID: 3, Error: "transition impossible: l'action take est impossible depuis l'état done"},
+	}, results)
+	ass.Len(published, 1)
+}
+
+func Test_intersectZones(t *testing.T) {
+	ass := assert.New(t)
+	ass.Equal(Zone{"21", "25"}, intersectZones(Zone{"21", "25", "75"}, Zone{"25", "21", "39"}))
+	ass.Empty(intersectZones(Zone{"75"}, Zone{"21"}))
+}
+
+func Test_bulkActionFor_refuseLesActionsInconnues(t *testing.T) {
+	_, err := bulkActionFor("user", bulkParams{Action: "delete"})
+	assert.Error(t, err)
+}
@@ -19,6 +19,7 @@ func (cs *Campaigns) Tuple() []interface{} {
 		&c.WekanDomainRegexp,
 		&c.DateEnd,
 		&c.DateCreate,
+		&c.Status,
 		&c.NBPerimetre,
 		&c.NBPending,
 		&c.NBTake,
@@ -74,16 +75,10 @@ func GetCampaignWekanDomainRegexp(ctx context.Context, campaignID CampaignID) (w
 	return wekanDomainRegexp, err
 }
 
-func Create(ctx context.Context, title string, wekanDomainRegexp string, dateEnd time.Time) (CampaignID, error) {
-	conn := db.Get()
-	var id CampaignID
-	err := conn.QueryRow(
-		ctx,
-		"insert into campaign (libelle, wekan_domain_regexp, date_end) values ($1, $2, $3) returning id",
-		title,
-		wekanDomainRegexp,
-		dateEnd,
-	).Scan(&id)
-
-	return id, err
+func Create(ctx context.Context, q db.Querier, title string, wekanDomainRegexp string, dateEnd time.Time) (CampaignID, error) {
+	return Insert(ctx, q, NewCampaign{
+		Libelle:           title,
+		WekanDomainRegexp: wekanDomainRegexp,
+		DateEnd:           dateEnd,
+	})
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"datapi/pkg/core"
 	"datapi/pkg/db"
-	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/signaux-faibles/libwekan"
@@ -49,7 +48,7 @@ func upsertCard(ctx context.Context, campaignEtablissementID CampaignEtablisseme
 		return core.KanbanCard{}, Message{}, err
 	}
 	config := kanbanService.LoadConfigForUser(username)
-	swimlane, err := selectSwimlane(config, wekanDomainRegexp, codeDepartement)
+	swimlane, err := config.SelectSwimlane(wekanDomainRegexp, codeDepartement)
 	if err != nil {
 		return core.KanbanCard{}, Message{}, err
 	}
@@ -62,6 +61,9 @@ func upsertCard(ctx context.Context, campaignEtablissementID CampaignEtablisseme
 			Siret:       siret,
 		}
 		kanbanCard, err := kanbanService.CreateCard(ctx, params, username, nil, pool)
+		if err == nil {
+			_, err = pool.Exec(ctx, sqlInsertCampaignCard, campaignEtablissementID, kanbanCard.ID, username)
+		}
 
 		message := Message{
 			CampaignEtablissementID: &campaignEtablissementID,
@@ -72,9 +74,12 @@ func upsertCard(ctx context.Context, campaignEtablissementID CampaignEtablisseme
 		}
 		return kanbanCard, message, err
 	}
-	err = kanbanService.UpdateCard(ctx, cards[0], description, username)
-	if err != nil {
-		return core.KanbanCard{}, Message{}, err
+	// une description vide ne remplace pas celle de la carte existante
+	if description != "" {
+		err = kanbanService.UpdateCard(ctx, cards[0], description, username)
+		if err != nil {
+			return core.KanbanCard{}, Message{}, err
+		}
 	}
 	message := Message{
 		CampaignEtablissementID: &campaignEtablissementID,
@@ -102,14 +107,3 @@ func getCampaignEtablissement(ctx context.Context, campaignEtablissementID Campa
 	}
 	return siret, wekanDomainRegexp, codeDepartement, campaignID, nil
 }
-
-func selectSwimlane(config core.KanbanConfig, wekanDomainRegexp *regexp.Regexp, codeDepartement core.CodeDepartement) (core.KanbanBoardSwimlane, error) {
-	swimlanes, _ := config.Departements[codeDepartement]
-	for _, swimlane := range swimlanes {
-		board := config.Boards[swimlane.BoardID]
-		if wekanDomainRegexp.MatchString(string(board.Slug)) {
-			return swimlane, nil
-		}
-	}
-	return core.KanbanBoardSwimlane{}, errors.New("aucun couloir disponible pour cette zone")
-}
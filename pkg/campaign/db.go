@@ -10,23 +10,23 @@ var sqlSelectMatchingCampaigns string
 //go:embed sql/selectPendingEtablissement.sql
 var sqlSelectPendingEtablissement string
 
-//go:embed sql/takePendingEtablissement.sql
-var sqlTakePendingEtablissement string
-
 //go:embed sql/selectMyActions.sql
 var sqlSelectMyActions string
 
 //go:embed sql/selectTakenActions.sql
 var sqlSelectTakenActions string
 
-//go:embed sql/actionMyEtablissement.sql
-var sqlActionMyEtablissement string
+//go:embed sql/selectEtablissementState.sql
+var sqlSelectEtablissementState string
+
+//go:embed sql/insertAction.sql
+var sqlInsertAction string
 
 //go:embed sql/selectCampaignEtablissementID.sql
 var sqlSelectCampaignEtablissementID string
 
-//go:embed sql/withdrawPendingEtablissement.sql
-var sqlWithdrawPendingEtablissement string
+//go:embed sql/selectWorkflow.sql
+var sqlSelectWorkflow string
 
 //go:embed sql/checkSirets.sql
 var sqlCheckSirets string
@@ -36,3 +36,78 @@ var sqlAddSirets string
 
 //go:embed sql/selectExports.sql
 var sqlSelectExports string
+
+//go:embed sql/insertCampaignCard.sql
+var sqlInsertCampaignCard string
+
+//go:embed sql/insertEvent.sql
+var sqlInsertEvent string
+
+//go:embed sql/selectEventsSince.sql
+var sqlSelectEventsSince string
+
+//go:embed sql/deleteEventsBefore.sql
+var sqlDeleteEventsBefore string
+
+//go:embed sql/selectSuggestionPending.sql
+var sqlSelectSuggestionPending string
+
+//go:embed sql/selectOpenTakes.sql
+var sqlSelectOpenTakes string
+
+//go:embed sql/selectOverdue.sql
+var sqlSelectOverdue string
+
+//go:embed sql/selectEndingCampaigns.sql
+var sqlSelectEndingCampaigns string
+
+//go:embed sql/insertReminder.sql
+var sqlInsertReminder string
+
+//go:embed sql/insertImportedSirets.sql
+var sqlInsertImportedSirets string
+
+//go:embed sql/insertCampaignImport.sql
+var sqlInsertCampaignImport string
+
+//go:embed sql/selectCampaignImportForUpdate.sql
+var sqlSelectCampaignImportForUpdate string
+
+//go:embed sql/updateCampaignImportApplied.sql
+var sqlUpdateCampaignImportApplied string
+
+//go:embed sql/selectCampaignImports.sql
+var sqlSelectCampaignImports string
+
+//go:embed sql/selectExportDetails.sql
+var sqlSelectExportDetails string
+
+//go:embed sql/selectExportHistory.sql
+var sqlSelectExportHistory string
+
+//go:embed sql/insertCampaign.sql
+var sqlInsertCampaign string
+
+//go:embed sql/updateCampaign.sql
+var sqlUpdateCampaign string
+
+//go:embed sql/selectCampaignStatusForUpdate.sql
+var sqlSelectCampaignStatusForUpdate string
+
+//go:embed sql/updateCampaignStatus.sql
+var sqlUpdateCampaignStatus string
+
+//go:embed sql/insertCampaignClone.sql
+var sqlInsertCampaignClone string
+
+//go:embed sql/insertCampaignEtablissementClone.sql
+var sqlInsertCampaignEtablissementClone string
+
+//go:embed sql/deleteEmptyCampaign.sql
+var sqlDeleteEmptyCampaign string
+
+//go:embed sql/selectCampaignStatus.sql
+var sqlSelectCampaignStatus string
+
+//go:embed sql/selectCampaignExportHeader.sql
+var sqlSelectCampaignExportHeader string
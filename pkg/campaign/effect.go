@@ -49,7 +49,8 @@ type CampaignFollowEffect struct {
 
 func (c CampaignFollowEffect) Do(ctx context.Context) error {
 	if c.cardID == "" {
-		card, _, err := upsertCard(ctx, c.campaignEtablissementID, "inscrire ici les informations de cet accompagnement", c.kanbanService, c.user.Username)
+		// la description de la carte créée vient du modèle du tableau
+		card, _, err := upsertCard(ctx, c.campaignEtablissementID, "", c.kanbanService, c.user.Username)
 		if err != nil {
 			return err
 		}
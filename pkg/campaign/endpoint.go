@@ -5,6 +5,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// coordinatorRole rôle keycloak des coordinateurs, seuls autorisés à attribuer des établissements à leurs collègues
+const coordinatorRole = "coordination"
+
 func ConfigureEndpoint(kanbanService core.KanbanService) func(campaignRoute *gin.RouterGroup) {
 	return func(campaignRoute *gin.RouterGroup) {
 		campaignRoute.GET("/list", listCampaignsHandler) // 1
@@ -17,8 +20,20 @@ func ConfigureEndpoint(kanbanService core.KanbanService) func(campaignRoute *gin
 		campaignRoute.GET("/stream", HeadersMiddleware(), stream.serveHTTP(), streamHandler)
 		campaignRoute.POST("/upsertcard", upsertCardHandler(kanbanService))
 		campaignRoute.POST("/withdraw/:campaignID/:campaignEtablissementID", withdrawHandler)
+		campaignRoute.POST("/bulk/:campaignID", bulkHandler)
+		campaignRoute.POST("/assign/:campaignID", core.CheckAnyRolesMiddleware(coordinatorRole), assignHandler(kanbanService))
+		campaignRoute.GET("/suggest/:campaignID", suggestHandler(kanbanService))
+		campaignRoute.POST("/suggest/:campaignID/accept", core.CheckAnyRolesMiddleware(coordinatorRole), acceptSuggestionsHandler(kanbanService))
 		campaignRoute.POST("/checksirets/:campaignID", checkSiretsHandler)
 		campaignRoute.POST("/addsirets/:campaignID", addSiretsHandler)
+		campaignRoute.GET("/import/:campaignID", importHistoryHandler)
+		campaignRoute.POST("/import/:campaignID", importCheckHandler)
+		campaignRoute.POST("/import/:campaignID/apply/:importID", importApplyHandler)
+		campaignRoute.GET("/workflow/:campaignID", workflowHandler)
+		campaignRoute.GET("/stats/:campaignID", statsHandler)
+		campaignRoute.GET("/overdue/:campaignID", overdueHandler)
+		campaignRoute.GET("/stats/:campaignID/xlsx", statsXlsxHandler)
 		campaignRoute.GET("/export/:campaignID", core.CheckAnyRolesMiddleware("stats"), exportHandlerFunc(kanbanService))
+		campaignRoute.GET("/export/:campaignID/xlsx", core.CheckAnyRolesMiddleware("stats"), exportXlsxHandlerFunc(kanbanService))
 	}
 }
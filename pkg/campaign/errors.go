@@ -72,3 +72,61 @@ func (e InvalidCampaignDomainError) Error() string {
 func (e InvalidCampaignDomainError) Unwrap() error {
 	return e.err
 }
+
+type IllegalTransitionError struct {
+	msg string
+}
+
+func (e IllegalTransitionError) Error() string {
+	return "transition impossible: " + e.msg
+}
+
+type InvalidWorkflowError struct {
+	err error
+}
+
+func (e InvalidWorkflowError) Error() string {
+	return "workflow invalide: " + e.err.Error()
+}
+
+func (e InvalidWorkflowError) Unwrap() error {
+	return e.err
+}
+
+type CampaignClosedError struct {
+	status Status
+}
+
+func (e CampaignClosedError) Error() string {
+	return "la campagne n'est plus ouverte: " + string(e.status)
+}
+
+type CampaignNotEmptyError struct {
+	err error
+}
+
+func (e CampaignNotEmptyError) Error() string {
+	return "la campagne contient des établissements"
+}
+
+func (e CampaignNotEmptyError) Unwrap() error {
+	return e.err
+}
+
+type CampaignImportNotFoundError struct {
+	err error
+}
+
+func (e CampaignImportNotFoundError) Error() string {
+	return "aucun import trouvé pour cette campagne"
+}
+
+func (e CampaignImportNotFoundError) Unwrap() error {
+	return e.err
+}
+
+type CampaignImportAppliedError struct{}
+
+func (e CampaignImportAppliedError) Error() string {
+	return "cet import a déjà été appliqué"
+}
@@ -1,15 +1,78 @@
 package campaign
 
 import (
+	"context"
 	"datapi/pkg/core"
+	"datapi/pkg/db"
 	"datapi/pkg/utils"
 	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
 	"log"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var stream = NewServer()
+const (
+	// taille du tampon de chaque client, un client qui ne suit pas est déconnecté
+	clientBufferSize = 64
+	// taille du tampon des messages émis par les handlers
+	messageBufferSize = 256
+	// nombre maximum d'évènements rejoués à la reconnexion
+	replayLimit = 1000
+	// durée de conservation du journal des évènements
+	eventRetention  = 7 * 24 * time.Hour
+	heartbeatPeriod = 30 * time.Second
+	// délai au-delà duquel un message est diffusé sans identifiant
+	journalTimeout = 5 * time.Second
+)
+
+var stream = NewServer(postgresEventLog{})
+
+// EventLog persiste les messages diffusés et permet leur rejeu
+type EventLog interface {
+	Append(ctx context.Context, message Message) (int64, error)
+	Since(ctx context.Context, lastEventID int64, campaigns []CampaignID, limit int) ([]Message, error)
+	Purge(ctx context.Context, before time.Time) error
+}
+
+type postgresEventLog struct{}
+
+func (postgresEventLog) Append(ctx context.Context, message Message) (int64, error) {
+	var id int64
+	err := db.Get().QueryRow(ctx, sqlInsertEvent, message.CampaignID, message).Scan(&id)
+	return id, err
+}
+
+func (postgresEventLog) Since(ctx context.Context, lastEventID int64, campaigns []CampaignID, limit int) ([]Message, error) {
+	ids := utils.Convert(campaigns, func(id CampaignID) int { return int(id) })
+	rows, err := db.Get().Query(ctx, sqlSelectEventsSince, lastEventID, ids, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []Message
+	for rows.Next() {
+		var message Message
+		var id int64
+		err = rows.Scan(&id, &message)
+		if err != nil {
+			return nil, err
+		}
+		message.ID = id
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (postgresEventLog) Purge(ctx context.Context, before time.Time) error {
+	_, err := db.Get().Exec(ctx, sqlDeleteEventsBefore, before)
+	return err
+}
 
 func (m Message) JSON() string {
 	msg, err := json.Marshal(m)
@@ -19,86 +82,208 @@ func (m Message) JSON() string {
 	return string(msg)
 }
 
+// writeSSE écrit le message au format server-sent event, avec son identifiant s'il a été journalisé
+func (m Message) writeSSE(w io.Writer) error {
+	var err error
+	if m.ID > 0 {
+		_, err = fmt.Fprintf(w, "id:%d\nevent:message\ndata:%s\n\n", m.ID, m.JSON())
+	} else {
+		_, err = fmt.Fprintf(w, "event:message\ndata:%s\n\n", m.JSON())
+	}
+	return err
+}
+
+func (client *Client) accepts(message Message) bool {
+	return len(client.Campaigns) == 0 || slices.Contains(client.Campaigns, message.CampaignID)
+}
+
 func streamHandler(c *gin.Context) {
 	var s core.Session
 	s.Bind(c)
 
-	v, ok := c.Get("clientChan")
+	v, ok := c.Get("client")
 	if !ok {
 		return
 	}
-	clientChan, ok := v.(ClientChan)
+	client, ok := v.(*Client)
 	if !ok {
 		return
 	}
 
 	zone := zoneForUser(s.Username)
+	visible := func(message Message) bool {
+		return utils.Overlaps(zone, message.Zone)
+	}
+
+	// rejoue les évènements manqués depuis le dernier identifiant reçu par le client
+	var lastSent int64
+	if lastEventID := lastEventIDFromRequest(c); lastEventID > 0 {
+		missed, err := stream.log.Since(c, lastEventID, client.Campaigns, replayLimit)
+		if err != nil {
+			slog.Error("erreur lors du rejeu des évènements", slog.Any("error", err))
+		}
+		for _, message := range missed {
+			if visible(message) && message.writeSSE(c.Writer) != nil {
+				return
+			}
+			lastSent = message.ID
+		}
+		c.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
 
 	c.Stream(func(w io.Writer) bool {
-		// Stream message to client from message channel
-		if message, ok := <-clientChan; ok {
-			if utils.Overlaps(zone, message.Zone) {
-				c.SSEvent("message", message.JSON())
+		select {
+		case message, ok := <-client.Messages:
+			if !ok {
+				return false
+			}
+			if message.ID > 0 && message.ID <= lastSent {
+				return true
+			}
+			if visible(message) {
+				return message.writeSSE(w) == nil
 			}
 			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ":heartbeat\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
 		}
-		return false
 	})
 }
 
-// Initialize event and Start procnteessing requests
-func NewServer() *Event {
+func lastEventIDFromRequest(c *gin.Context) int64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventID")
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func campaignsFromRequest(c *gin.Context) []CampaignID {
+	var campaigns []CampaignID
+	for _, value := range strings.Split(c.Query("campaignIDs"), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil {
+			campaigns = append(campaigns, CampaignID(id))
+		}
+	}
+	return campaigns
+}
+
+// NewServer initialise le serveur d'évènements et démarre la diffusion
+func NewServer(eventLog EventLog) *Event {
 	event := &Event{
-		Message:       make(chan Message),
-		NewClients:    make(chan chan Message),
-		ClosedClients: make(chan chan Message),
-		TotalClients:  make(map[chan Message]bool),
+		Message:       make(chan Message, messageBufferSize),
+		NewClients:    make(chan *Client),
+		ClosedClients: make(chan *Client),
+		TotalClients:  make(map[*Client]bool),
+		log:           eventLog,
+		journalized:   make(chan Message, messageBufferSize),
 	}
 
+	go event.journal()
 	go event.listen()
 
 	return event
 }
 
-// It Listens all incoming requests from clients.
-// Handles addition and removal of clients and broadcast messages to clients.
+// listen gère l'ajout et le retrait des clients et diffuse les messages journalisés
 func (stream *Event) listen() {
 	for {
 		select {
-		// Add new available client
 		case client := <-stream.NewClients:
 			stream.TotalClients[client] = true
 			log.Printf("Client added. %d registered clients", len(stream.TotalClients))
 
-		// Remove closed client
 		case client := <-stream.ClosedClients:
-			delete(stream.TotalClients, client)
-			close(client)
+			stream.remove(client)
 			log.Printf("Removed client. %d registered clients", len(stream.TotalClients))
 
-		// Broadcast message to client
+		case eventMsg := <-stream.journalized:
+			stream.broadcast(eventMsg)
+		}
+	}
+}
+
+// journal persiste les messages dans leur ordre d'arrivée puis les transmet à listen,
+// hors de la boucle de diffusion pour qu'une base lente ne bloque pas les clients
+func (stream *Event) journal() {
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+	for {
+		select {
 		case eventMsg := <-stream.Message:
-			for clientMessageChan := range stream.TotalClients {
-				clientMessageChan <- eventMsg
+			stream.journalized <- stream.journalize(eventMsg)
+
+		case <-purge.C:
+			ctx, cancel := context.WithTimeout(context.Background(), journalTimeout)
+			err := stream.log.Purge(ctx, time.Now().Add(-eventRetention))
+			cancel()
+			if err != nil {
+				slog.Error("erreur lors de la purge du journal des évènements", slog.Any("error", err))
 			}
 		}
 	}
 }
 
+func (stream *Event) journalize(message Message) Message {
+	ctx, cancel := context.WithTimeout(context.Background(), journalTimeout)
+	defer cancel()
+	id, err := stream.log.Append(ctx, message)
+	if err != nil {
+		slog.Error("erreur lors de la journalisation de l'évènement", slog.Any("error", err))
+		return message
+	}
+	message.ID = id
+	return message
+}
+
+// broadcast n'attend jamais un client : un client dont le tampon est plein est déconnecté
+// et pourra rejouer les évènements manqués à la reconnexion
+func (stream *Event) broadcast(message Message) {
+	for client := range stream.TotalClients {
+		if !client.accepts(message) {
+			continue
+		}
+		select {
+		case client.Messages <- message:
+		default:
+			stream.remove(client)
+			log.Printf("Slow client dropped. %d registered clients", len(stream.TotalClients))
+		}
+	}
+}
+
+func (stream *Event) remove(client *Client) {
+	if stream.TotalClients[client] {
+		delete(stream.TotalClients, client)
+		close(client.Messages)
+	}
+}
+
 func (stream *Event) serveHTTP() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Initialize client channel
-		clientChan := make(ClientChan)
+		client := &Client{
+			Messages:  make(chan Message, clientBufferSize),
+			Campaigns: campaignsFromRequest(c),
+		}
 
-		// Send new connection to event server
-		stream.NewClients <- clientChan
+		stream.NewClients <- client
 
 		defer func() {
-			// Send closed connection to event server
-			stream.ClosedClients <- clientChan
+			stream.ClosedClients <- client
 		}()
 
-		c.Set("clientChan", clientChan)
+		c.Set("client", client)
 
 		c.Next()
 	}
@@ -0,0 +1,110 @@
+package campaign
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryEventLog struct {
+	sync.Mutex
+	messages []Message
+}
+
+func (l *memoryEventLog) Append(_ context.Context, message Message) (int64, error) {
+	l.Lock()
+	defer l.Unlock()
+	message.ID = int64(len(l.messages) + 1)
+	l.messages = append(l.messages, message)
+	return message.ID, nil
+}
+
+func (l *memoryEventLog) Since(_ context.Context, lastEventID int64, _ []CampaignID, _ int) ([]Message, error) {
+	l.Lock()
+	defer l.Unlock()
+	return l.messages[lastEventID:], nil
+}
+
+func (l *memoryEventLog) Purge(_ context.Context, _ time.Time) error {
+	return nil
+}
+
+func Test_Event_broadcast_numeroteEtFiltreParCampagne(t *testing.T) {
+	ass := assert.New(t)
+	server := NewServer(&memoryEventLog{})
+	all := &Client{Messages: make(chan Message, 2)}
+	filtered := &Client{Messages: make(chan Message, 2), Campaigns: []CampaignID{2}}
+	server.NewClients <- all
+	server.NewClients <- filtered
+
+	server.Message <- Message{CampaignID: 1, Type: "pending"}
+	server.Message <- Message{CampaignID: 2, Type: "success"}
+
+	ass.Equal(int64(1), (<-all.Messages).ID)
+	ass.Equal(int64(2), (<-all.Messages).ID)
+	received := <-filtered.Messages
+	ass.Equal(CampaignID(2), received.CampaignID)
+	ass.Equal(int64(2), received.ID)
+}
+
+// blockingEventLog simule une base indisponible : Append attend l'expiration du contexte
+type blockingEventLog struct {
+	memoryEventLog
+	appending chan struct{}
+}
+
+func (l *blockingEventLog) Append(ctx context.Context, _ Message) (int64, error) {
+	l.appending <- struct{}{}
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func Test_Event_journal_neBloquePasLesClients(t *testing.T) {
+	ass := assert.New(t)
+	eventLog := &blockingEventLog{appending: make(chan struct{}, 1)}
+	server := NewServer(eventLog)
+	server.Message <- Message{CampaignID: 1}
+	<-eventLog.appending
+
+	registered := make(chan struct{})
+	go func() {
+		client := &Client{Messages: make(chan Message, 1)}
+		server.NewClients <- client
+		server.ClosedClients <- client
+		close(registered)
+	}()
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		ass.Fail("l'ajout d'un client attend la journalisation")
+	}
+}
+
+func Test_Event_broadcast_deconnecteLesClientsLents(t *testing.T) {
+	ass := assert.New(t)
+	slow := &Client{Messages: make(chan Message, 1)}
+	server := &Event{TotalClients: map[*Client]bool{slow: true}, log: &memoryEventLog{}}
+
+	server.broadcast(Message{CampaignID: 1})
+	server.broadcast(Message{CampaignID: 1})
+
+	_, ok := <-slow.Messages
+	ass.True(ok)
+	_, ok = <-slow.Messages
+	ass.False(ok)
+	ass.Empty(server.TotalClients)
+}
+
+func Test_Message_writeSSE(t *testing.T) {
+	ass := assert.New(t)
+	buffer := new(bytes.Buffer)
+
+	ass.NoError(Message{ID: 12, CampaignID: 1, Type: "take"}.writeSSE(buffer))
+
+	ass.Contains(buffer.String(), "id:12\nevent:message\ndata:{")
+	ass.True(bytes.HasSuffix(buffer.Bytes(), []byte("\n\n")))
+}
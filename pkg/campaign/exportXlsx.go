@@ -0,0 +1,289 @@
+package campaign
+
+import (
+	"cmp"
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"datapi/pkg/stats"
+	"datapi/pkg/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gosimple/slug"
+	"github.com/jackc/pgx/v5"
+	"github.com/signaux-faibles/libwekan"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportEtablissement est une ligne de l'export détaillé d'une campagne
+type ExportEtablissement struct {
+	ID              CampaignEtablissementID `col:"id" size:"10"`
+	Rank            int                     `col:"rang" size:"8"`
+	CodeDepartement string                  `col:"département" size:"12"`
+	Siret           core.Siret              `col:"siret" size:"16"`
+	RaisonSociale   string                  `col:"raison sociale" size:"40"`
+	Effectif        *float64                `col:"effectif" size:"10"`
+	DetteUrssaf     *float64                `col:"dette urssaf" size:"14"`
+	Alert           *string                 `col:"alerte" size:"20"`
+	CodeActivite    *string                 `col:"code naf" size:"10"`
+	LibelleActivite *string                 `col:"activité" size:"40"`
+	Comment         *string                 `col:"commentaire" size:"30"`
+	Action          string                  `col:"statut" size:"12"`
+	Detail          *string                 `col:"détail" size:"30"`
+	Username        *string                 `col:"utilisateur" size:"30"`
+	Board           string                  `col:"tableau wekan" size:"30"`
+	List            string                  `col:"liste wekan" size:"30"`
+	Labels          string                  `col:"étiquettes" size:"40"`
+	LastActivity    *time.Time              `col:"dernière activité" size:"18" dateFormat:"yyyy-mm-dd"`
+}
+
+// ExportAction est une action de l'historique d'un établissement de la campagne
+type ExportAction struct {
+	ID              CampaignEtablissementID `col:"id" size:"10"`
+	CodeDepartement string                  `col:"département" size:"12"`
+	Siret           core.Siret              `col:"siret" size:"16"`
+	RaisonSociale   string                  `col:"raison sociale" size:"40"`
+	DateAction      time.Time               `col:"date" size:"18" dateFormat:"yyyy-mm-dd hh:mm"`
+	Action          string                  `col:"action" size:"12"`
+	Detail          *string                 `col:"détail" size:"30"`
+	Username        string                  `col:"utilisateur" size:"30"`
+}
+
+// ExportCard est une carte wekan d'un établissement de la campagne
+type ExportCard struct {
+	Siret        core.Siret `col:"siret" size:"16"`
+	Board        string     `col:"tableau" size:"30"`
+	List         string     `col:"liste" size:"30"`
+	Labels       string     `col:"étiquettes" size:"40"`
+	Archived     bool       `col:"archivée" size:"10"`
+	StartAt      time.Time  `col:"début" size:"14" dateFormat:"yyyy-mm-dd"`
+	LastActivity time.Time  `col:"dernière activité" size:"18" dateFormat:"yyyy-mm-dd"`
+	URL          string     `col:"lien" size:"50"`
+}
+
+type campaignExport struct {
+	CampaignName      string
+	WekanDomainRegexp string
+	Etablissements    []ExportEtablissement
+	History           []ExportAction
+	Cards             []ExportCard
+}
+
+func (e *campaignExport) Tuple() []interface{} {
+	e.Etablissements = append(e.Etablissements, ExportEtablissement{})
+	last := &e.Etablissements[len(e.Etablissements)-1]
+	return []interface{}{
+		&e.CampaignName,
+		&e.WekanDomainRegexp,
+		&last.ID,
+		&last.Rank,
+		&last.CodeDepartement,
+		&last.Siret,
+		&last.RaisonSociale,
+		&last.Effectif,
+		&last.DetteUrssaf,
+		&last.Alert,
+		&last.CodeActivite,
+		&last.LibelleActivite,
+		&last.Comment,
+		&last.Action,
+		&last.Detail,
+		&last.Username,
+	}
+}
+
+type exportActions []ExportAction
+
+func (a *exportActions) Tuple() []interface{} {
+	*a = append(*a, ExportAction{})
+	last := &(*a)[len(*a)-1]
+	return []interface{}{
+		&last.ID,
+		&last.CodeDepartement,
+		&last.Siret,
+		&last.RaisonSociale,
+		&last.DateAction,
+		&last.Action,
+		&last.Detail,
+		&last.Username,
+	}
+}
+
+func selectCampaignExport(ctx context.Context, campaignID CampaignID, boards []libwekan.ConfigBoard,
+	username libwekan.Username, kanbanService core.KanbanService) (campaignExport, error) {
+	var export campaignExport
+	err := db.Get().QueryRow(ctx, sqlSelectCampaignExportHeader, campaignID).
+		Scan(&export.CampaignName, &export.WekanDomainRegexp)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return campaignExport{}, CampaignNotFoundError{err: err}
+	}
+	if err != nil {
+		return campaignExport{}, err
+	}
+	zones := zonesFromBoards(boards)
+	err = db.Scan(ctx, &export, sqlSelectExportDetails, campaignID, zones)
+	if err != nil {
+		return campaignExport{}, err
+	}
+	history := make(exportActions, 0)
+	err = db.Scan(ctx, &history, sqlSelectExportHistory, campaignID, zones)
+	if err != nil {
+		return campaignExport{}, err
+	}
+	export.History = history
+	if len(export.Etablissements) == 0 {
+		return export, nil
+	}
+
+	// limiter les boards scannées au périmètre de la campagne
+	re, err := regexp.CompilePOSIX(export.WekanDomainRegexp)
+	if err != nil {
+		return export, err
+	}
+	matchingBoards := utils.Filter(boards, boardMatchesRegexpFunc(re))
+	sirets := utils.Convert(export.Etablissements, func(e ExportEtablissement) core.Siret { return e.Siret })
+	boardIDs := utils.Convert(matchingBoards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	cards, err := kanbanService.SelectCardsFromSiretsAndBoardIDs(ctx, sirets, boardIDs, username)
+	if err != nil {
+		return export, err
+	}
+	export.Cards = toExportCards(cards, kanbanService.GetWekanConfig())
+	appendCardsToCampaignExport(&export)
+	return export, nil
+}
+
+func labelNames(card core.KanbanCard, config libwekan.Config) string {
+	board := config.Boards[card.BoardID].Board
+	names := utils.Convert(card.LabelIDs, func(id libwekan.BoardLabelID) string {
+		return string(board.GetLabelByID(id).Name)
+	})
+	return strings.Join(names, ", ")
+}
+
+func toExportCards(cards []core.KanbanCard, config libwekan.Config) []ExportCard {
+	return utils.Convert(cards, func(card core.KanbanCard) ExportCard {
+		return ExportCard{
+			Siret:        card.Siret,
+			Board:        string(card.BoardTitle),
+			List:         card.ListTitle,
+			Labels:       labelNames(card, config),
+			Archived:     card.Archived,
+			StartAt:      card.StartAt,
+			LastActivity: card.LastActivity,
+			URL:          card.URL,
+		}
+	})
+}
+
+// appendCardsToCampaignExport reporte sur chaque établissement la carte non archivée la plus récemment active
+func appendCardsToCampaignExport(export *campaignExport) {
+	for i, etablissement := range export.Etablissements {
+		cards := utils.Filter(export.Cards, func(card ExportCard) bool {
+			return card.Siret == etablissement.Siret && !card.Archived
+		})
+		if len(cards) == 0 {
+			continue
+		}
+		card := slices.MaxFunc(cards, func(a, b ExportCard) int { return a.LastActivity.Compare(b.LastActivity) })
+		export.Etablissements[i].Board = card.Board
+		export.Etablissements[i].List = card.List
+		export.Etablissements[i].Labels = card.Labels
+		export.Etablissements[i].LastActivity = &card.LastActivity
+	}
+}
+
+// byDepartement regroupe les établissements par département, dans l'ordre des départements
+func byDepartement(etablissements []ExportEtablissement) ([]string, map[string][]ExportEtablissement) {
+	groups := make(map[string][]ExportEtablissement)
+	for _, etablissement := range etablissements {
+		groups[etablissement.CodeDepartement] = append(groups[etablissement.CodeDepartement], etablissement)
+	}
+	departements := utils.GetKeys(groups)
+	slices.SortFunc(departements, cmp.Compare[string])
+	return departements, groups
+}
+
+func deref[T any](value *T) any {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func exportEtablissementToRow(e ExportEtablissement) []any {
+	return []any{
+		int(e.ID), e.Rank, e.CodeDepartement, string(e.Siret), e.RaisonSociale,
+		deref(e.Effectif), deref(e.DetteUrssaf), deref(e.Alert), deref(e.CodeActivite), deref(e.LibelleActivite),
+		deref(e.Comment), e.Action, deref(e.Detail), deref(e.Username),
+		e.Board, e.List, e.Labels, deref(e.LastActivity),
+	}
+}
+
+// newExportWorkbook écrit une feuille par département, puis l'historique des actions et les cartes
+func newExportWorkbook(export campaignExport) (*stats.Workbook, error) {
+	workbook := stats.NewWorkbook()
+	departements, groups := byDepartement(export.Etablissements)
+	for _, departement := range departements {
+		err := stats.WriteSheet(workbook, "département "+departement, groups[departement], exportEtablissementToRow)
+		if err != nil {
+			return workbook, err
+		}
+	}
+	err := stats.WriteSheet(workbook, "historique", export.History, func(a ExportAction) []any {
+		return []any{int(a.ID), a.CodeDepartement, string(a.Siret), a.RaisonSociale, a.DateAction, a.Action, deref(a.Detail), a.Username}
+	})
+	if err != nil {
+		return workbook, err
+	}
+	err = stats.WriteSheet(workbook, "cartes", export.Cards, func(c ExportCard) []any {
+		return []any{string(c.Siret), c.Board, c.List, c.Labels, c.Archived, c.StartAt, c.LastActivity, c.URL}
+	})
+	return workbook, err
+}
+
+func exportXlsxHandlerFunc(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var s core.Session
+		s.Bind(c)
+
+		campaignID, err := strconv.Atoi(c.Param("campaignID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, `/campaign/export/:campaignID/xlsx: le parametre campaignID doit être un entier`)
+			return
+		}
+		boards := kanbanService.SelectBoardsForUsername(libwekan.Username(s.Username))
+		export, err := selectCampaignExport(c, CampaignID(campaignID), boards, libwekan.Username(s.Username), kanbanService)
+		if errors.As(err, &CampaignNotFoundError{}) {
+			c.JSON(http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		workbook, err := newExportWorkbook(export)
+		defer func() {
+			if err := workbook.Close(); err != nil {
+				slog.Error("erreur à la fermeture du fichier", slog.Any("error", err))
+			}
+		}()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		filename := fmt.Sprintf("export-campaign-%s-%s", export.CampaignName, time.Now().Format("060102"))
+		c.Header("Content-Disposition", "attachment; filename="+slug.Make(filename)+".xlsx")
+		c.Header("Content-Type", "application/octet-stream")
+		err = workbook.Export(c.Writer)
+		if err != nil {
+			slog.Error("erreur pendant l'export de la campagne", slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package campaign
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_appendCardsToCampaignExport_carteLaPlusRecente(t *testing.T) {
+	ass := assert.New(t)
+	now := time.Now()
+	export := campaignExport{
+		Etablissements: []ExportEtablissement{{Siret: "12345678901234"}, {Siret: "98765432109876"}},
+		Cards: []ExportCard{
+			{Siret: "12345678901234", List: "ancienne", LastActivity: now.Add(-time.Hour)},
+			{Siret: "12345678901234", List: "récente", LastActivity: now},
+			{Siret: "12345678901234", List: "archivée", LastActivity: now.Add(time.Hour), Archived: true},
+		},
+	}
+
+	appendCardsToCampaignExport(&export)
+
+	ass.Equal("récente", export.Etablissements[0].List)
+	ass.Equal(now, *export.Etablissements[0].LastActivity)
+	ass.Empty(export.Etablissements[1].List)
+	ass.Nil(export.Etablissements[1].LastActivity)
+}
+
+func Test_newExportWorkbook_uneFeuilleParDepartement(t *testing.T) {
+	ass := assert.New(t)
+	alert := "Alerte seuil F1"
+	export := campaignExport{
+		Etablissements: []ExportEtablissement{
+			{ID: 1, CodeDepartement: "25", Siret: "12345678901234", Alert: &alert, Action: "pending"},
+			{ID: 2, CodeDepartement: "21", Siret: "98765432109876", Action: "take"},
+		},
+		History: []ExportAction{{ID: 2, CodeDepartement: "21", DateAction: time.Now(), Action: "take"}},
+	}
+
+	workbook, err := newExportWorkbook(export)
+	ass.NoError(err)
+	defer workbook.Close()
+	var buffer bytes.Buffer
+	ass.NoError(workbook.Export(&buffer))
+
+	xls, err := excelize.OpenReader(&buffer)
+	ass.NoError(err)
+	ass.Contains(xls.GetSheetList(), "département 21")
+	ass.Contains(xls.GetSheetList(), "département 25")
+	ass.Contains(xls.GetSheetList(), "historique")
+	ass.Contains(xls.GetSheetList(), "cartes")
+	value, err := xls.GetCellValue("département 25", "H2")
+	ass.NoError(err)
+	ass.Equal(alert, value)
+}
@@ -0,0 +1,380 @@
+package campaign
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"encoding/csv"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxImportFileSize = 10 << 20
+	maxImportRows     = 10000
+
+	importStatusChecked = "checked"
+
+	// statuts propres à l'import, les autres statuts sont ceux de checkSirets
+	rowInvalide     = "invalide"
+	rowRangInvalide = "rangInvalide"
+	rowDoublon      = "doublon"
+	rowOk           = "ok"
+)
+
+// ImportRow est une ligne du fichier importé et le résultat de sa validation
+type ImportRow struct {
+	Line            int        `json:"line"`
+	Siret           core.Siret `json:"siret"`
+	Rank            *int       `json:"rank,omitempty"`
+	Comment         *string    `json:"comment,omitempty"`
+	Status          string     `json:"status"`
+	RaisonSociale   *string    `json:"raisonSociale,omitempty"`
+	CodeDepartement *string    `json:"codeDepartement,omitempty"`
+}
+
+// ImportReport est le rapport de validation d'un fichier, à confirmer avant application
+type ImportReport struct {
+	ImportID   int            `json:"importID"`
+	CampaignID CampaignID     `json:"campaignID"`
+	Filename   string         `json:"filename"`
+	Counts     map[string]int `json:"counts"`
+	Rows       []ImportRow    `json:"rows"`
+}
+
+// CampaignImport est une entrée de l'historique des imports d'une campagne
+type CampaignImport struct {
+	ID         int        `json:"id"`
+	Username   string     `json:"username"`
+	Filename   string     `json:"filename"`
+	Status     string     `json:"status"`
+	NbRows     int        `json:"nbRows"`
+	NbInserted *int       `json:"nbInserted,omitempty"`
+	DateUpload time.Time  `json:"dateUpload"`
+	DateApply  *time.Time `json:"dateApply,omitempty"`
+}
+
+type campaignImports []CampaignImport
+
+func (i *campaignImports) Tuple() []interface{} {
+	*i = append(*i, CampaignImport{})
+	last := &(*i)[len(*i)-1]
+	return []interface{}{
+		&last.ID,
+		&last.Username,
+		&last.Filename,
+		&last.Status,
+		&last.NbRows,
+		&last.NbInserted,
+		&last.DateUpload,
+		&last.DateApply,
+	}
+}
+
+// readImportFile lit la première feuille d'un fichier xlsx ou un fichier csv séparé par des virgules ou des points-virgules
+func readImportFile(filename string, file io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		xls, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer xls.Close()
+		return xls.GetRows(xls.GetSheetName(0))
+	case ".csv":
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		reader := csv.NewReader(strings.NewReader(string(content)))
+		reader.FieldsPerRecord = -1
+		if firstLine, _, _ := strings.Cut(string(content), "\n"); strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+			reader.Comma = ';'
+		}
+		return reader.ReadAll()
+	}
+	return nil, errors.New("format de fichier non supporté, le fichier doit être au format csv ou xlsx")
+}
+
+// importColumns repère les colonnes siret, rang et commentaire dans l'entête
+// sans entête, les colonnes sont dans cet ordre
+func importColumns(firstRecord []string) (siret, rank, comment int, hasHeader bool) {
+	siret, rank, comment = 0, 1, 2
+	if len(firstRecord) == 0 || core.Siret(strings.TrimSpace(firstRecord[0])).IsValid() {
+		return siret, rank, comment, false
+	}
+	rank, comment = -1, -1
+	for i, name := range firstRecord {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "siret":
+			siret = i
+		case "rang", "rank":
+			rank = i
+		case "commentaire", "comment":
+			comment = i
+		}
+	}
+	return siret, rank, comment, true
+}
+
+func cell(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// parseImportRows valide le format des lignes, les sirets valides et uniques restent à vérifier en base
+func parseImportRows(records [][]string) []ImportRow {
+	rows := make([]ImportRow, 0, len(records))
+	if len(records) == 0 {
+		return rows
+	}
+	siretColumn, rankColumn, commentColumn, hasHeader := importColumns(records[0])
+	seen := make(map[core.Siret]bool)
+	for i, record := range records {
+		if hasHeader && i == 0 {
+			continue
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+		row := ImportRow{Line: i + 1, Siret: core.Siret(strings.ReplaceAll(cell(record, siretColumn), " ", ""))}
+		if value := cell(record, commentColumn); value != "" {
+			row.Comment = &value
+		}
+		if value := cell(record, rankColumn); value != "" {
+			rank, err := strconv.Atoi(value)
+			if err != nil {
+				row.Status = rowRangInvalide
+			} else {
+				row.Rank = &rank
+			}
+		}
+		if !row.Siret.IsValid() {
+			row.Status = rowInvalide
+		} else if seen[row.Siret] {
+			row.Status = rowDoublon
+		}
+		seen[row.Siret] = true
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// applyCheckedSirets reporte le résultat de checkSirets sur les lignes dont le format est valide
+func applyCheckedSirets(rows []ImportRow, checked CheckedSirets) {
+	bySiret := make(map[core.Siret]*CheckedSiret)
+	for _, checkedSiret := range checked.Sirets {
+		if checkedSiret.Siret != nil {
+			bySiret[*checkedSiret.Siret] = checkedSiret
+		}
+	}
+	for i := range rows {
+		if rows[i].Status != "" {
+			continue
+		}
+		if checkedSiret, ok := bySiret[rows[i].Siret]; ok && checkedSiret.Status != nil {
+			rows[i].Status = *checkedSiret.Status
+			rows[i].RaisonSociale = checkedSiret.RaisonSociale
+			rows[i].CodeDepartement = checkedSiret.CodeDepartement
+		}
+	}
+}
+
+func countImportRows(rows []ImportRow) map[string]int {
+	counts := make(map[string]int)
+	for _, row := range rows {
+		counts[row.Status]++
+	}
+	return counts
+}
+
+func checkImport(ctx context.Context, campaignID CampaignID, filename string, records [][]string, username string) (ImportReport, error) {
+	rows := parseImportRows(records)
+	sirets := make([]core.Siret, 0, len(rows))
+	for _, row := range rows {
+		if row.Status == "" {
+			sirets = append(sirets, row.Siret)
+		}
+	}
+	checked, err := checkSirets(ctx, campaignID, sirets, username)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	applyCheckedSirets(rows, checked)
+
+	report := ImportReport{
+		CampaignID: campaignID,
+		Filename:   filename,
+		Counts:     countImportRows(rows),
+		Rows:       rows,
+	}
+	err = db.Get().QueryRow(ctx, sqlInsertCampaignImport, campaignID, username, filename, rows).Scan(&report.ImportID)
+	return report, err
+}
+
+// applyImport insère les lignes valides du rapport dans la campagne, un rapport ne peut être appliqué qu'une fois
+func applyImport(ctx context.Context, campaignID CampaignID, importID int, username string) (int, Message, error) {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return 0, Message{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var rows []ImportRow
+	var status string
+	err = tx.QueryRow(ctx, sqlSelectCampaignImportForUpdate, campaignID, importID).Scan(&rows, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, Message{}, CampaignImportNotFoundError{err: err}
+	} else if err != nil {
+		return 0, Message{}, err
+	}
+	if status != importStatusChecked {
+		return 0, Message{}, CampaignImportAppliedError{}
+	}
+
+	var sirets []core.Siret
+	var ranks []*int
+	var comments []*string
+	for _, row := range rows {
+		if row.Status == rowOk {
+			sirets = append(sirets, row.Siret)
+			ranks = append(ranks, row.Rank)
+			comments = append(comments, row.Comment)
+		}
+	}
+	zones := zonesFromBoards(core.Kanban.SelectBoardsForUsername(libwekan.Username(username)))
+	tag, err := tx.Exec(ctx, sqlInsertImportedSirets, campaignID, sirets, ranks, comments, zones, username)
+	if err != nil {
+		return 0, Message{}, err
+	}
+	inserted := int(tag.RowsAffected())
+	_, err = tx.Exec(ctx, sqlUpdateCampaignImportApplied, importID, inserted)
+	if err != nil {
+		return 0, Message{}, err
+	}
+	message := Message{
+		CampaignID: campaignID,
+		Zone:       zoneFromBoardZones(zones),
+		Type:       "addSiret",
+		Username:   username,
+	}
+	return inserted, message, tx.Commit(ctx)
+}
+
+func selectCampaignImports(ctx context.Context, campaignID CampaignID) ([]CampaignImport, error) {
+	imports := make(campaignImports, 0)
+	err := db.Scan(ctx, &imports, sqlSelectCampaignImports, campaignID)
+	return imports, err
+}
+
+// openCampaignFromContext vérifie le paramètre campaignID et que la campagne est ouverte
+func openCampaignFromContext(c *gin.Context) (CampaignID, bool) {
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "campaignID doit être un nombre entier")
+		return 0, false
+	}
+	open, err := isOpen(c, CampaignID(campaignID))
+	if errors.As(err, &CampaignNotFoundError{}) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return 0, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return 0, false
+	} else if !open {
+		c.JSON(http.StatusUnprocessableEntity, "la campagne n'est plus ouverte")
+		return 0, false
+	}
+	return CampaignID(campaignID), true
+}
+
+func importCheckHandler(c *gin.Context) {
+	var s core.Session
+	s.Bind(c)
+	campaignID, ok := openCampaignFromContext(c)
+	if !ok {
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "fichier absent: "+err.Error())
+		return
+	}
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, "le fichier ne doit pas dépasser 10Mo")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	records, err := readImportFile(header.Filename, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "lecture du fichier impossible: "+err.Error())
+		return
+	}
+	if len(records) > maxImportRows {
+		c.JSON(http.StatusBadRequest, "le fichier ne doit pas dépasser "+strconv.Itoa(maxImportRows)+" lignes")
+		return
+	}
+	report, err := checkImport(c, campaignID, header.Filename, records, s.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func importApplyHandler(c *gin.Context) {
+	var s core.Session
+	s.Bind(c)
+	campaignID, ok := openCampaignFromContext(c)
+	if !ok {
+		return
+	}
+	importID, err := strconv.Atoi(c.Param("importID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "importID doit être un nombre entier")
+		return
+	}
+	inserted, message, err := applyImport(c, campaignID, importID, s.Username)
+	if errors.As(err, &CampaignImportNotFoundError{}) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	} else if errors.As(err, &CampaignImportAppliedError{}) {
+		c.JSON(http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	stream.Message <- message
+	c.JSON(http.StatusOK, gin.H{"importID": importID, "nbInserted": inserted})
+}
+
+func importHistoryHandler(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "campaignID doit être un nombre entier")
+		return
+	}
+	imports, err := selectCampaignImports(c, CampaignID(campaignID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, imports)
+}
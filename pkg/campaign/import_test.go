@@ -0,0 +1,68 @@
+package campaign
+
+import (
+	"strings"
+	"testing"
+
+	"datapi/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_readImportFile_csvPointVirguleAvecEntete(t *testing.T) {
+	ass := assert.New(t)
+	content := "commentaire;siret;rang\nà voir;12345678901234;2\n"
+
+	records, err := readImportFile("sirets.CSV", strings.NewReader(content))
+
+	ass.NoError(err)
+	ass.Equal([][]string{{"commentaire", "siret", "rang"}, {"à voir", "12345678901234", "2"}}, records)
+}
+
+func Test_readImportFile_refuseLesAutresFormats(t *testing.T) {
+	_, err := readImportFile("sirets.txt", strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func Test_parseImportRows_avecEntete(t *testing.T) {
+	ass := assert.New(t)
+	rows := parseImportRows([][]string{
+		{"commentaire", "SIRET", "rang"},
+		{"à voir", "123 456 789 01234", "2"},
+		{"", "12345678901234", ""},
+		{"", "", ""},
+		{"", "1234", "1"},
+		{"", "98765432109876", "premier"},
+	})
+
+	ass.Len(rows, 4)
+	ass.Equal(core.Siret("12345678901234"), rows[0].Siret)
+	ass.Equal(2, *rows[0].Rank)
+	ass.Equal("à voir", *rows[0].Comment)
+	ass.Equal("", rows[0].Status)
+	ass.Equal(3, rows[1].Line)
+	ass.Equal(rowDoublon, rows[1].Status)
+	ass.Equal(rowInvalide, rows[2].Status)
+	ass.Equal(rowRangInvalide, rows[3].Status)
+}
+
+func Test_parseImportRows_sansEntete(t *testing.T) {
+	ass := assert.New(t)
+	rows := parseImportRows([][]string{{"12345678901234", "1", "prioritaire"}})
+
+	ass.Len(rows, 1)
+	ass.Equal(1, *rows[0].Rank)
+	ass.Equal("prioritaire", *rows[0].Comment)
+}
+
+func Test_applyCheckedSirets(t *testing.T) {
+	ass := assert.New(t)
+	siret := core.Siret("12345678901234")
+	horsZone := "horsZone"
+	rows := []ImportRow{{Siret: siret}, {Siret: "1234", Status: rowInvalide}}
+
+	applyCheckedSirets(rows, CheckedSirets{Sirets: []*CheckedSiret{{Siret: &siret, Status: &horsZone}}})
+
+	ass.Equal(horsZone, rows[0].Status)
+	ass.Equal(rowInvalide, rows[1].Status)
+	ass.Equal(map[string]int{horsZone: 1, rowInvalide: 1}, countImportRows(rows))
+}
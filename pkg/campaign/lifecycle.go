@@ -0,0 +1,188 @@
+package campaign
+
+import (
+	"context"
+	"datapi/pkg/db"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"regexp"
+	"time"
+)
+
+// Status est le statut d'une campagne
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusClosed   Status = "closed"
+	StatusArchived Status = "archived"
+)
+
+// NewCampaign décrit une campagne à créer
+type NewCampaign struct {
+	Libelle           string    `json:"libelle"`
+	WekanDomainRegexp string    `json:"wekanDomainRegexp"`
+	Zone              []string  `json:"zone"`
+	DateEnd           time.Time `json:"dateFin"`
+}
+
+// CampaignUpdate décrit les modifications d'une campagne, les champs nil sont inchangés
+type CampaignUpdate struct {
+	Libelle *string    `json:"libelle"`
+	DateEnd *time.Time `json:"dateFin"`
+}
+
+// Validate contrôle les paramètres de la nouvelle campagne
+func (n NewCampaign) Validate() error {
+	if n.Libelle == "" {
+		return errors.New("le libellé de la campagne est obligatoire")
+	}
+	if n.DateEnd.Before(time.Now()) {
+		return fmt.Errorf("dateFin doit être dans le futur : %s", n.DateEnd.Format(time.DateOnly))
+	}
+	_, err := regexp.Compile(n.WekanDomainRegexp)
+	if n.WekanDomainRegexp == "" || err != nil {
+		return InvalidCampaignDomainError{domain: n.WekanDomainRegexp, err: err}
+	}
+	return nil
+}
+
+// Insert crée la campagne et retourne son identifiant
+func Insert(ctx context.Context, q db.Querier, campaign NewCampaign) (CampaignID, error) {
+	var id CampaignID
+	err := q.QueryRow(
+		ctx,
+		sqlInsertCampaign,
+		campaign.Libelle,
+		campaign.WekanDomainRegexp,
+		campaign.Zone,
+		campaign.DateEnd,
+	).Scan(&id)
+	return id, err
+}
+
+// Validate contrôle les modifications de la campagne
+func (u CampaignUpdate) Validate() error {
+	if u.Libelle != nil && *u.Libelle == "" {
+		return errors.New("le libellé de la campagne ne peut pas être vide")
+	}
+	if u.DateEnd != nil && u.DateEnd.Before(time.Now()) {
+		return fmt.Errorf("dateFin doit être dans le futur : %s", u.DateEnd.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// Update modifie le libellé et/ou la date de fin d'une campagne
+func Update(ctx context.Context, campaignID CampaignID, update CampaignUpdate) error {
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	tag, err := db.Get().Exec(ctx, sqlUpdateCampaign, campaignID, update.Libelle, update.DateEnd)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return CampaignNotFoundError{err: errors.New("aucune campagne mise à jour")}
+	}
+	return nil
+}
+
+// SetStatus change le statut d'une campagne, un retour à `open` n'est possible que depuis `closed` avant la date de fin
+func SetStatus(ctx context.Context, campaignID CampaignID, status Status) error {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current Status
+	var dateEnd time.Time
+	err = tx.QueryRow(ctx, sqlSelectCampaignStatusForUpdate, campaignID).Scan(&current, &dateEnd)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CampaignNotFoundError{err: err}
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkStatusTransition(current, status, dateEnd); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlUpdateCampaignStatus, campaignID, status); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkStatusTransition contrôle le passage du statut from au statut to d'une campagne se terminant à dateEnd
+func checkStatusTransition(from Status, to Status, dateEnd time.Time) error {
+	if to != StatusOpen && to != StatusClosed && to != StatusArchived {
+		return fmt.Errorf("statut inconnu: %s", to)
+	}
+	if from == to {
+		return IllegalTransitionError{msg: fmt.Sprintf("la campagne est déjà %s", from)}
+	}
+	if to == StatusOpen && from != StatusClosed {
+		return IllegalTransitionError{msg: fmt.Sprintf("la campagne %s ne peut pas être rouverte", from)}
+	}
+	if to == StatusOpen && dateEnd.Before(today()) {
+		return IllegalTransitionError{msg: fmt.Sprintf("la date de fin est dépassée : %s", dateEnd.Format(time.DateOnly))}
+	}
+	if to == StatusClosed && from != StatusOpen {
+		return IllegalTransitionError{msg: fmt.Sprintf("la campagne %s ne peut pas être fermée", from)}
+	}
+	return nil
+}
+
+// today retourne la date du jour, comparable aux colonnes de type date
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Clone crée une nouvelle campagne reprenant le domaine, la zone, le workflow et les établissements d'une campagne existante
+func Clone(ctx context.Context, campaignID CampaignID, libelle string, dateEnd time.Time) (CampaignID, error) {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id CampaignID
+	err = tx.QueryRow(ctx, sqlInsertCampaignClone, campaignID, libelle, dateEnd).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, CampaignNotFoundError{err: err}
+	} else if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, sqlInsertCampaignEtablissementClone, campaignID, id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// Delete supprime une campagne qui ne contient aucun établissement
+func Delete(ctx context.Context, campaignID CampaignID) error {
+	tag, err := db.Get().Exec(ctx, sqlDeleteEmptyCampaign, campaignID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if CampaignExists(ctx, campaignID) {
+			return CampaignNotEmptyError{}
+		}
+		return CampaignNotFoundError{err: errors.New("aucune campagne supprimée")}
+	}
+	return nil
+}
+
+// isOpen indique si la campagne accepte encore des modifications
+func isOpen(ctx context.Context, campaignID CampaignID) (bool, error) {
+	var status Status
+	err := db.Get().QueryRow(ctx, sqlSelectCampaignStatus, campaignID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, CampaignNotFoundError{err: err}
+	}
+	return status == StatusOpen, err
+}
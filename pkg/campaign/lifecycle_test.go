@@ -0,0 +1,54 @@
+package campaign
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewCampaign_Validate(t *testing.T) {
+	ass := assert.New(t)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	valid := NewCampaign{Libelle: "campagne", WekanDomainRegexp: "^tableau-.*", DateEnd: tomorrow}
+	ass.NoError(valid.Validate())
+
+	noLibelle := valid
+	noLibelle.Libelle = ""
+	ass.Error(noLibelle.Validate())
+
+	past := valid
+	past.DateEnd = time.Now().AddDate(0, 0, -1)
+	ass.Error(past.Validate())
+
+	badRegexp := valid
+	badRegexp.WekanDomainRegexp = "(["
+	ass.ErrorAs(badRegexp.Validate(), &InvalidCampaignDomainError{})
+}
+
+func Test_CampaignUpdate_Validate(t *testing.T) {
+	ass := assert.New(t)
+	empty := ""
+	past := time.Now().AddDate(0, 0, -1)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+
+	ass.NoError(CampaignUpdate{}.Validate())
+	ass.NoError(CampaignUpdate{DateEnd: &tomorrow}.Validate())
+	ass.Error(CampaignUpdate{Libelle: &empty}.Validate())
+	ass.Error(CampaignUpdate{DateEnd: &past}.Validate())
+}
+
+func Test_checkStatusTransition(t *testing.T) {
+	ass := assert.New(t)
+	future := time.Now().AddDate(0, 1, 0)
+	past := time.Now().AddDate(0, -1, 0)
+	ass.NoError(checkStatusTransition(StatusOpen, StatusClosed, future))
+	ass.NoError(checkStatusTransition(StatusClosed, StatusOpen, future))
+	ass.NoError(checkStatusTransition(StatusClosed, StatusArchived, past))
+	ass.NoError(checkStatusTransition(StatusOpen, StatusArchived, future))
+	ass.ErrorAs(checkStatusTransition(StatusArchived, StatusOpen, future), &IllegalTransitionError{})
+	ass.ErrorAs(checkStatusTransition(StatusArchived, StatusClosed, future), &IllegalTransitionError{})
+	ass.ErrorAs(checkStatusTransition(StatusClosed, StatusClosed, future), &IllegalTransitionError{})
+	ass.ErrorAs(checkStatusTransition(StatusClosed, StatusOpen, past), &IllegalTransitionError{})
+	ass.Error(checkStatusTransition(StatusOpen, "inconnu", future))
+}
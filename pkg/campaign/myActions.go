@@ -31,6 +31,7 @@ func (p *MyActions) Tuple() []interface{} {
 		&ce.Action,
 		&ce.Rank,
 		&ce.CodeDepartement,
+		&ce.Comment,
 	}
 }
 
@@ -24,7 +24,7 @@ func pendingHandler(kanbanService core.KanbanService) func(c *gin.Context) {
 		}
 		username := libwekan.Username(s.Username)
 		boards := kanban.SelectBoardsForUser(username)
-		pending, err := selectPending(c, CampaignID(id), boards, core.Page{10, 0}, username, kanbanService)
+		pending, err := selectPending(c, CampaignID(id), boards, core.Page{Size: 10, Number: 0}, username, kanbanService)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, "erreur inattendue: "+err.Error())
@@ -53,6 +53,7 @@ func (p *Pending) Tuple() []interface{} {
 		&ce.Rank,
 		&ce.CodeDepartement,
 		&ce.Detail,
+		&ce.Comment,
 	}
 }
 
@@ -0,0 +1,289 @@
+package campaign
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"log/slog"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+const (
+	reminderOverdue = "overdue"
+	reminderEnding  = "ending"
+	// type des messages du flux signalant un rappel
+	messageReminder = "reminder"
+)
+
+// ReminderConfig paramètre la détection des prises en charge sans issue et des fins de campagne
+type ReminderConfig struct {
+	OverdueDays int
+	EndingDays  int
+	Period      time.Duration
+}
+
+// Overdue est une prise en charge restée sans success ni cancel au delà du délai configuré
+type Overdue struct {
+	CampaignID                    CampaignID                    `json:"campaignID"`
+	Libelle                       string                        `json:"libelle"`
+	CampaignEtablissementID       CampaignEtablissementID       `json:"campaignEtablissementID"`
+	CampaignEtablissementActionID CampaignEtablissementActionID `json:"-"`
+	Siret                         core.Siret                    `json:"siret"`
+	RaisonSociale                 string                        `json:"raisonSociale"`
+	CodeDepartement               string                        `json:"codeDepartement"`
+	Username                      string                        `json:"username"`
+	DateTake                      time.Time                     `json:"dateTake"`
+	Days                          int                           `json:"days"`
+}
+
+// EndingCampaign donne le nombre d'établissements restant à traiter dans un département d'une campagne bientôt terminée
+type EndingCampaign struct {
+	CampaignID      CampaignID `json:"campaignID"`
+	Libelle         string     `json:"libelle"`
+	DateEnd         time.Time  `json:"dateFin"`
+	CodeDepartement string     `json:"codeDepartement"`
+	NbPending       int        `json:"nbPending"`
+}
+
+// Notifier envoie un rappel à un utilisateur
+type Notifier interface {
+	Notify(ctx context.Context, username string, subject string, body string) error
+}
+
+type overdues []Overdue
+
+func (o *overdues) Tuple() []interface{} {
+	*o = append(*o, Overdue{})
+	last := &(*o)[len(*o)-1]
+	return []interface{}{
+		&last.CampaignID,
+		&last.Libelle,
+		&last.CampaignEtablissementID,
+		&last.CampaignEtablissementActionID,
+		&last.Siret,
+		&last.RaisonSociale,
+		&last.CodeDepartement,
+		&last.Username,
+		&last.DateTake,
+		&last.Days,
+	}
+}
+
+type endingCampaigns []EndingCampaign
+
+func (e *endingCampaigns) Tuple() []interface{} {
+	*e = append(*e, EndingCampaign{})
+	last := &(*e)[len(*e)-1]
+	return []interface{}{&last.CampaignID, &last.Libelle, &last.DateEnd, &last.CodeDepartement, &last.NbPending}
+}
+
+// reminderConfigFromViper lit la section [campaign.reminders] de la configuration
+func reminderConfigFromViper() ReminderConfig {
+	viper.SetDefault("campaign.reminders.overdueDays", 21)
+	viper.SetDefault("campaign.reminders.endingDays", 7)
+	viper.SetDefault("campaign.reminders.period", "24h")
+	return ReminderConfig{
+		OverdueDays: viper.GetInt("campaign.reminders.overdueDays"),
+		EndingDays:  viper.GetInt("campaign.reminders.endingDays"),
+		Period:      viper.GetDuration("campaign.reminders.period"),
+	}
+}
+
+// selectOverdue liste les prises en charge sans issue, campaignID et zone sont facultatifs
+func selectOverdue(ctx context.Context, campaignID *CampaignID, zone Zone, days int, onlyNew bool) ([]Overdue, error) {
+	result := make(overdues, 0)
+	err := db.Scan(ctx, &result, sqlSelectOverdue, campaignID, zone, days, onlyNew)
+	return result, err
+}
+
+func selectEndingCampaigns(ctx context.Context, days int) ([]EndingCampaign, error) {
+	result := make(endingCampaigns, 0)
+	err := db.Scan(ctx, &result, sqlSelectEndingCampaigns, days)
+	return result, err
+}
+
+func overdueMessage(overdue Overdue) Message {
+	return Message{
+		CampaignID:              overdue.CampaignID,
+		CampaignEtablissementID: &overdue.CampaignEtablissementID,
+		Zone:                    []string{overdue.CodeDepartement},
+		Type:                    messageReminder,
+		Username:                overdue.Username,
+	}
+}
+
+func endingMessage(ending EndingCampaign) Message {
+	return Message{
+		CampaignID: ending.CampaignID,
+		Zone:       []string{ending.CodeDepartement},
+		Type:       messageReminder,
+	}
+}
+
+func overdueMail(overdue Overdue) (string, string) {
+	subject := fmt.Sprintf("Signaux Faibles - campagne %s : prise en charge sans suite", overdue.Libelle)
+	body := fmt.Sprintf("Vous avez pris en charge l'établissement %s (%s) le %s, il y a %d jours.\n"+
+		"Merci d'indiquer l'issue de cette prise en charge ou de l'annuler.",
+		overdue.RaisonSociale, overdue.Siret, overdue.DateTake.Format("02/01/2006"), overdue.Days)
+	return subject, body
+}
+
+// remind diffuse un rappel par prise en charge et par département de campagne bientôt terminée,
+// l'agent concerné par une prise en charge est averti par mail s'il l'a accepté
+func remind(ctx context.Context, overdues []Overdue, endings []EndingCampaign, publish func(Message), notifier Notifier, wantsEmail func(ctx context.Context, username string) bool) {
+	for _, overdue := range overdues {
+		publish(overdueMessage(overdue))
+		if notifier == nil || !wantsEmail(ctx, overdue.Username) {
+			continue
+		}
+		subject, body := overdueMail(overdue)
+		if err := notifier.Notify(ctx, overdue.Username, subject, body); err != nil {
+			slog.Error("erreur lors de l'envoi du rappel", slog.String("username", overdue.Username), slog.Any("error", err))
+		}
+	}
+	for _, ending := range endings {
+		publish(endingMessage(ending))
+	}
+}
+
+// wantsCampaignEmail indique si l'utilisateur accepte les notifications de campagne par mail
+func wantsCampaignEmail(ctx context.Context, username string) bool {
+	preferences, err := core.SelectPreferences(ctx, username)
+	if err != nil {
+		slog.Error("erreur lors de la lecture des préférences", slog.String("username", username), slog.Any("error", err))
+		return false
+	}
+	return preferences.Notifications.Email && preferences.Notifications.Campaign
+}
+
+// recordReminders enregistre les rappels diffusés pour ne pas les répéter au prochain passage
+func recordReminders(ctx context.Context, overdues []Overdue, endings []EndingCampaign) error {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	for _, overdue := range overdues {
+		_, err = tx.Exec(ctx, sqlInsertReminder, overdue.CampaignID, reminderOverdue, overdue.CampaignEtablissementActionID, nil)
+		if err != nil {
+			return err
+		}
+	}
+	for _, ending := range endings {
+		_, err = tx.Exec(ctx, sqlInsertReminder, ending.CampaignID, reminderEnding, nil, ending.CodeDepartement)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func runReminders(ctx context.Context, config ReminderConfig, notifier Notifier) error {
+	overdues, err := selectOverdue(ctx, nil, nil, config.OverdueDays, true)
+	if err != nil {
+		return err
+	}
+	endings, err := selectEndingCampaigns(ctx, config.EndingDays)
+	if err != nil {
+		return err
+	}
+	err = recordReminders(ctx, overdues, endings)
+	if err != nil {
+		return err
+	}
+	remind(ctx, overdues, endings, publishToStream, notifier, wantsCampaignEmail)
+	slog.Info("rappels de campagne diffusés", slog.Int("overdue", len(overdues)), slog.Int("ending", len(endings)))
+	return nil
+}
+
+// StartReminders lance la détection périodique des prises en charge sans issue et des fins de campagne
+func StartReminders(ctx context.Context) {
+	config := reminderConfigFromViper()
+	if config.Period <= 0 {
+		config.Period = 24 * time.Hour
+	}
+	notifier := smtpNotifierFromViper()
+	go func() {
+		ticker := time.NewTicker(config.Period)
+		defer ticker.Stop()
+		for {
+			if err := runReminders(ctx, config, notifier); err != nil {
+				slog.Error("erreur lors de la détection des rappels de campagne", slog.Any("error", err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+type smtpNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// smtpNotifierFromViper retourne nil si aucun serveur smtp n'est configuré, les rappels ne sont alors pas envoyés par mail
+func smtpNotifierFromViper() Notifier {
+	host := viper.GetString("smtp.host")
+	if host == "" {
+		return nil
+	}
+	viper.SetDefault("smtp.port", 25)
+	notifier := smtpNotifier{
+		addr: host + ":" + strconv.Itoa(viper.GetInt("smtp.port")),
+		from: viper.GetString("smtp.from"),
+	}
+	if username := viper.GetString("smtp.username"); username != "" {
+		notifier.auth = smtp.PlainAuth("", username, viper.GetString("smtp.password"), host)
+	}
+	return notifier
+}
+
+// Notify envoie le mail à username, les noms d'utilisateur sont les adresses mail des agents
+func (n smtpNotifier) Notify(_ context.Context, username string, subject string, body string) error {
+	message := "From: " + n.from + "\r\n" +
+		"To: " + username + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body + "\r\n"
+	return smtp.SendMail(n.addr, n.auth, n.from, []string{username}, []byte(message))
+}
+
+func overdueHandler(c *gin.Context) {
+	var s core.Session
+	s.Bind(c)
+
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, `/campaign/overdue/:campaignID: le parametre campaignID doit être un entier`)
+		return
+	}
+	days := reminderConfigFromViper().OverdueDays
+	if value := c.Query("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 0 {
+			c.JSON(http.StatusBadRequest, "le parametre days doit être un entier positif")
+			return
+		}
+	}
+	if !CampaignExists(c, CampaignID(campaignID)) {
+		c.JSON(http.StatusNotFound, "aucune campagne trouvée")
+		return
+	}
+	id := CampaignID(campaignID)
+	result, err := selectOverdue(c, &id, zoneForUser(s.Username), days, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, "erreur inattendue: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
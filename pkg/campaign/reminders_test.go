@@ -0,0 +1,52 @@
+package campaign
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	sent []string
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, username string, _ string, _ string) error {
+	f.sent = append(f.sent, username)
+	return nil
+}
+
+func Test_remind_publieEtNotifieSelonLesPreferences(t *testing.T) {
+	ass := assert.New(t)
+	overdues := []Overdue{
+		{CampaignID: 1, CampaignEtablissementID: 10, CodeDepartement: "21", Username: "alice", DateTake: time.Now()},
+		{CampaignID: 1, CampaignEtablissementID: 11, CodeDepartement: "25", Username: "bob", DateTake: time.Now()},
+	}
+	endings := []EndingCampaign{{CampaignID: 2, CodeDepartement: "39", NbPending: 4}}
+
+	var published []Message
+	notifier := &fakeNotifier{}
+	remind(context.Background(), overdues, endings, func(message Message) {
+		published = append(published, message)
+	}, notifier, func(_ context.Context, username string) bool {
+		return username == "alice"
+	})
+
+	ass.Len(published, 3)
+	for _, message := range published {
+		ass.Equal(messageReminder, message.Type)
+	}
+	ass.Equal([]string{"21"}, published[0].Zone)
+	ass.Equal(CampaignEtablissementID(11), *published[1].CampaignEtablissementID)
+	ass.Nil(published[2].CampaignEtablissementID)
+	ass.Equal([]string{"alice"}, notifier.sent)
+}
+
+func Test_remind_sansNotifier(t *testing.T) {
+	var published []Message
+	remind(context.Background(), []Overdue{{CampaignID: 1, Username: "alice"}}, nil, func(message Message) {
+		published = append(published, message)
+	}, nil, func(context.Context, string) bool { return true })
+	assert.Len(t, published, 1)
+}
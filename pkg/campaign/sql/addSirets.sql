@@ -1,7 +1,7 @@
 with sirets as (select unnest($2::text[])::text as siret),
      zones as (select key as slug, ARRAY(SELECT jsonb_array_elements_text(value)) as zone
                from jsonb_each($3::jsonb)),
-     zone as (select flatmap(z.zone) as zone
+     zone as (select flatmap(array(select d from unnest(z.zone) d where c.zone is null or d = any (c.zone))) as zone
               from campaign c
                        inner join zones z on z.slug ~ c.wekan_domain_regexp
               where c.id = $1),
@@ -0,0 +1,11 @@
+with perimetre as (select ce.id
+                   from campaign_etablissement ce
+                            inner join campaign c on c.id = ce.id_campaign
+                            inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+                       and (c.zone is null or s.code_departement = any (c.zone))
+                   where ce.id_campaign = $1)
+select date_trunc('day', cea.date_action) as jour, cea.action, count(*) as nombre
+from campaign_etablissement_action cea
+         inner join perimetre p on p.id = cea.id_campaign_etablissement
+group by 1, 2
+order by 1, 2
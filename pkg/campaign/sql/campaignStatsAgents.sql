@@ -0,0 +1,14 @@
+with perimetre as (select ce.id
+                   from campaign_etablissement ce
+                            inner join campaign c on c.id = ce.id_campaign
+                            inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+                       and (c.zone is null or s.code_departement = any (c.zone))
+                   where ce.id_campaign = $1)
+select cea.username,
+       count(distinct cea.id_campaign_etablissement) filter (where cea.action = 'take') as taken,
+       count(*) filter (where cea.action = 'success')                                  as done,
+       count(*) filter (where cea.action = 'cancel')                                   as cancelled
+from campaign_etablissement_action cea
+         inner join perimetre p on p.id = cea.id_campaign_etablissement
+group by cea.username
+order by cea.username
@@ -0,0 +1,7 @@
+select count(distinct cc.card_id)
+from campaign_card cc
+         inner join campaign_etablissement ce on ce.id = cc.id_campaign_etablissement
+         inner join campaign c on c.id = ce.id_campaign
+         inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+    and (c.zone is null or s.code_departement = any (c.zone))
+where ce.id_campaign = $1
@@ -0,0 +1,18 @@
+with perimetre as (select ce.id, s.code_departement
+                   from campaign_etablissement ce
+                            inner join campaign c on c.id = ce.id_campaign
+                            inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+                       and (c.zone is null or s.code_departement = any (c.zone))
+                   where ce.id_campaign = $1),
+     actions as (select id_campaign_etablissement, last(action order by id) as action
+                 from campaign_etablissement_action
+                 group by id_campaign_etablissement)
+select p.code_departement,
+       count(*)                                       as perimetre,
+       count(*) filter (where a.action = 'take')     as taken,
+       count(*) filter (where a.action = 'success')  as done,
+       count(*) filter (where a.action = 'withdraw') as withdrawn
+from perimetre p
+         left join actions a on a.id_campaign_etablissement = p.id
+group by p.code_departement
+order by p.code_departement
@@ -0,0 +1,17 @@
+with perimetre as (select ce.id
+                   from campaign_etablissement ce
+                            inner join campaign c on c.id = ce.id_campaign
+                            inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+                       and (c.zone is null or s.code_departement = any (c.zone))
+                   where ce.id_campaign = $1),
+     actions as (select id_campaign_etablissement,
+                        last(action order by id) as action,
+                        last(detail order by id) as detail
+                 from campaign_etablissement_action
+                 group by id_campaign_etablissement)
+select a.action, coalesce(a.detail, '') as detail, count(*) as nombre
+from perimetre p
+         inner join actions a on a.id_campaign_etablissement = p.id
+where a.action != 'take'
+group by 1, 2
+order by 1, 2
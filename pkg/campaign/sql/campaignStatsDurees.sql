@@ -0,0 +1,17 @@
+with perimetre as (select ce.id
+                   from campaign_etablissement ce
+                            inner join campaign c on c.id = ce.id_campaign
+                            inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+                       and (c.zone is null or s.code_departement = any (c.zone))
+                   where ce.id_campaign = $1)
+select a.action, extract(epoch from a.date_action - t.date_action)::bigint as duree
+from campaign_etablissement_action a
+         inner join perimetre p on p.id = a.id_campaign_etablissement
+         inner join lateral (select date_action
+                             from campaign_etablissement_action t
+                             where t.id_campaign_etablissement = a.id_campaign_etablissement
+                               and t.action = 'take'
+                               and t.id < a.id
+                             order by t.id desc
+                             limit 1) t on true
+where a.action in ('success', 'cancel')
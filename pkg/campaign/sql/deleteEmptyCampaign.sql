@@ -0,0 +1,4 @@
+delete
+from campaign c
+where id = $1
+  and not exists (select from campaign_etablissement ce where ce.id_campaign = c.id)
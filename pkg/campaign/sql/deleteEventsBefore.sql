@@ -0,0 +1,2 @@
+delete from campaign_event
+where date_event < $1
@@ -0,0 +1,11 @@
+with inserted as (
+  insert into campaign_etablissement_action
+    (id_campaign_etablissement, username, date_action, action, detail)
+    select ce.id, $3, current_timestamp, $4, nullif($5, '')
+    from campaign_etablissement ce
+    where ce.id_campaign = $1
+      and ce.id = $2
+    returning id, id_campaign_etablissement)
+select i.id, s.code_departement from inserted i
+inner join campaign_etablissement ce on ce.id = i.id_campaign_etablissement
+inner join v_summaries s on s.siret = ce.siret
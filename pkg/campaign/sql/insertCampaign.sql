@@ -0,0 +1,3 @@
+insert into campaign (libelle, wekan_domain_regexp, zone, date_end)
+values ($1, $2, $3, $4)
+returning id
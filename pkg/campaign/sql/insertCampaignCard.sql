@@ -0,0 +1,2 @@
+insert into campaign_card (id_campaign_etablissement, card_id, username)
+values ($1, $2, $3)
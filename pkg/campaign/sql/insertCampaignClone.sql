@@ -0,0 +1,5 @@
+insert into campaign (libelle, wekan_domain_regexp, zone, workflow, date_end)
+select $2, wekan_domain_regexp, zone, workflow, $3
+from campaign
+where id = $1
+returning id
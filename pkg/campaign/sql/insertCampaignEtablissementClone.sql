@@ -0,0 +1,5 @@
+insert into campaign_etablissement (id_campaign, siret, username)
+select $2, siret, username
+from campaign_etablissement
+where id_campaign = $1
+order by id
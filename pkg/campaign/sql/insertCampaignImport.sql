@@ -0,0 +1,3 @@
+insert into campaign_import (id_campaign, username, filename, report, status)
+values ($1, $2, $3, $4, 'checked')
+returning id
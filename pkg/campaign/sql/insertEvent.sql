@@ -0,0 +1,3 @@
+insert into campaign_event (id_campaign, message)
+values ($1, $2)
+returning id
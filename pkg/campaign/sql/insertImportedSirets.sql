@@ -0,0 +1,14 @@
+with rows as (select siret, rank, comment
+              from unnest($2::text[], $3::integer[], $4::text[]) as r(siret, rank, comment)),
+     zones as (select key as slug, ARRAY(SELECT jsonb_array_elements_text(value)) as zone
+               from jsonb_each($5::jsonb)),
+     zone as (select flatmap(array(select d from unnest(z.zone) d where c.zone is null or d = any (c.zone))) as zone
+              from campaign c
+                       inner join zones z on z.slug ~ c.wekan_domain_regexp
+              where c.id = $1)
+insert into campaign_etablissement (id_campaign, siret, username, rank, comment)
+select $1, r.siret, $6, r.rank, r.comment
+from rows r
+         inner join v_summaries v on v.siret = r.siret
+         inner join zone z on v.code_departement = any (z.zone)
+where not exists (select 1 from campaign_etablissement ce where ce.siret = r.siret and ce.id_campaign = $1)
@@ -0,0 +1,3 @@
+insert into campaign_reminder (id_campaign, kind, id_campaign_etablissement_action, code_departement)
+values ($1, $2, $3, $4)
+on conflict do nothing
@@ -0,0 +1,3 @@
+select libelle, wekan_domain_regexp
+from campaign
+where id = $1
@@ -0,0 +1,5 @@
+select report, status
+from campaign_import
+where id_campaign = $1
+  and id = $2
+for update
@@ -0,0 +1,4 @@
+select id, username, filename, status, jsonb_array_length(report), nb_inserted, date_upload, date_apply
+from campaign_import
+where id_campaign = $1
+order by id desc
@@ -0,0 +1,3 @@
+select campaign_status(status, date_end)
+from campaign
+where id = $1
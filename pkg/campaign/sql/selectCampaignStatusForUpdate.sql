@@ -0,0 +1,4 @@
+select status, date_end
+from campaign
+where id = $1
+for update
@@ -0,0 +1,20 @@
+with actions as (
+  select id_campaign_etablissement,
+    last(action order by id) as action
+  from campaign_etablissement_action
+  group by id_campaign_etablissement
+)
+select c.id, c.libelle, c.date_end, s.code_departement, count(*)
+from campaign c
+  inner join campaign_etablissement ce on ce.id_campaign = c.id
+  inner join v_summaries s on s.siret = ce.siret
+    and (c.zone is null or s.code_departement = any (c.zone))
+  left join actions a on a.id_campaign_etablissement = ce.id
+where campaign_status(c.status, c.date_end) = 'open'
+  and c.date_end <= current_date + $1::integer
+  and (a.action in ('cancel', 'withdraw') or a.action is null)
+  and not exists (
+    select 1 from campaign_reminder r
+    where r.kind = 'ending' and r.id_campaign = c.id and r.code_departement = s.code_departement)
+group by c.id, c.libelle, c.date_end, s.code_departement
+order by c.id, s.code_departement
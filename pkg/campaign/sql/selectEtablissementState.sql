@@ -0,0 +1,15 @@
+select campaign_status(c.status, c.date_end), c.workflow, a.action, a.username
+from campaign c
+inner join campaign_etablissement ce on ce.id_campaign = c.id
+inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($3)
+  and (c.zone is null or s.code_departement = any (c.zone))
+left join lateral (
+  select action, username
+  from campaign_etablissement_action cea
+  where cea.id_campaign_etablissement = ce.id
+  order by cea.id desc
+  limit 1
+) a on true
+where c.id = $1
+  and ce.id = $2
+for update of ce
@@ -0,0 +1,6 @@
+select id, message
+from campaign_event
+where id > $1
+  and (cardinality($2::integer[]) = 0 or id_campaign = any ($2))
+order by id
+limit $3
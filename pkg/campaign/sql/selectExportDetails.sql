@@ -0,0 +1,35 @@
+with actions as (select id_campaign_etablissement,
+                        last(action order by id) as action,
+                        last(detail order by id) as detail,
+                        last(username order by id) as username
+                 from campaign_etablissement_action
+                 group by id_campaign_etablissement),
+     zones as (select key as slug, ARRAY(SELECT jsonb_array_elements_text(value)) as zone
+               from jsonb_each($2::jsonb)),
+     zone as (select flatmap(array(select d from unnest(z.zone) d where c.zone is null or d = any (c.zone))) as zone
+              from campaign c
+                       inner join zones z on z.slug ~ c.wekan_domain_regexp
+              where id = $1)
+select c.libelle,
+       c.wekan_domain_regexp,
+       ce.id,
+       rank() over (order by ce.rank nulls last, ce.id) as rank,
+       s.code_departement,
+       s.siret,
+       s.raison_sociale,
+       s.effectif,
+       s.dette_urssaf,
+       s.alert,
+       s.code_activite,
+       s.libelle_n5,
+       ce.comment,
+       coalesce(a.action, 'pending'),
+       a.detail,
+       a.username
+from campaign_etablissement ce
+         inner join zone z on true
+         inner join campaign c on c.id = ce.id_campaign
+         inner join v_summaries s on s.siret = ce.siret and s.code_departement = any (z.zone)
+         left join actions a on a.id_campaign_etablissement = ce.id
+where ce.id_campaign = $1
+order by s.code_departement, rank
@@ -0,0 +1,20 @@
+with zones as (select key as slug, ARRAY(SELECT jsonb_array_elements_text(value)) as zone
+               from jsonb_each($2::jsonb)),
+     zone as (select flatmap(array(select d from unnest(z.zone) d where c.zone is null or d = any (c.zone))) as zone
+              from campaign c
+                       inner join zones z on z.slug ~ c.wekan_domain_regexp
+              where id = $1)
+select ce.id,
+       s.code_departement,
+       s.siret,
+       s.raison_sociale,
+       cea.date_action,
+       cea.action,
+       cea.detail,
+       cea.username
+from campaign_etablissement ce
+         inner join zone z on true
+         inner join v_summaries s on s.siret = ce.siret and s.code_departement = any (z.zone)
+         inner join campaign_etablissement_action cea on cea.id_campaign_etablissement = ce.id
+where ce.id_campaign = $1
+order by ce.id, cea.id
@@ -8,10 +8,12 @@ with zones as (
       c.wekan_domain_regexp,
       date_end,
       date_create,
-      array_agg(z.slug) as slugs, flatmap(z.zone) as zones, array_agg(i.id_board) as id_boards
+      campaign_status(c.status, c.date_end) as status,
+      array_agg(z.slug) as slugs, flatmap(array(select d from unnest(z.zone) d where c.zone is null or d = any (c.zone))) as zones, array_agg(i.id_board) as id_boards
    from campaign c
    inner join zones z on z.slug ~ c.wekan_domain_regexp
    inner join id_boards i on i.slug = z.slug
+   where c.status != 'archived'
    group by c.id, c.libelle, c.wekan_domain_regexp, date_end, date_create
 ), actions as (
   select ce.id as id_campaign_etablissement,
@@ -21,7 +23,7 @@ with zones as (
          left join campaign_etablissement_action cea on cea.id_campaign_etablissement = ce.id
   group by ce.id
 )
-select cs.id, cs.libelle, cs.wekan_domain_regexp, cs.date_end, cs.date_create,
+select cs.id, cs.libelle, cs.wekan_domain_regexp, cs.date_end, cs.date_create, cs.status,
        coalesce(sum(case when s.code_departement = any(cs.zones) then 1 else 0 end), 0) as nb_perimetre,
        coalesce(sum(case when s.code_departement = any(cs.zones) and a.action in ('pending', 'cancel') then 1 else 0 end), 0) as nb_pending,
        coalesce(sum(case when s.code_departement = any(cs.zones) and a.action = 'take' then 1 else 0 end), 0) as nb_take,
@@ -32,5 +34,5 @@ from campaigns cs
        left join campaign_etablissement ce on ce.id_campaign = cs.id
        left join v_summaries s on s.siret = ce.siret
        left join actions a on a.id_campaign_etablissement = ce.id
-group by cs.id, cs.libelle, cs.wekan_domain_regexp, cs.date_end, cs.date_create, cs.slugs, cs.zones, cs.id_boards
+group by cs.id, cs.libelle, cs.wekan_domain_regexp, cs.date_end, cs.date_create, cs.status, cs.slugs, cs.zones, cs.id_boards
 order by cs.id desc
@@ -7,7 +7,7 @@ with actions as (
 ), zones as (
   select key as slug, ARRAY(SELECT jsonb_array_elements_text(value)) as zone
   from jsonb_each($2::jsonb)
-), zone as (select flatmap(z.zone) as zone
+), zone as (select flatmap(array(select d from unnest(z.zone) d where c.zone is null or d = any (c.zone))) as zone
   from campaign c
   inner join zones z on z.slug ~ c.wekan_domain_regexp
   where id = $1)
@@ -23,8 +23,9 @@ select count(*) over () as nb_total,
    s.first_alert,
    s.etat_administratif,
    a.action,
-   rank() over (order by ce.id) as rank,
-   s.code_departement
+   rank() over (order by ce.rank nulls last, ce.id) as rank,
+   s.code_departement,
+   ce.comment
 from campaign_etablissement ce
   inner join zone z on true
   inner join campaign c on c.id = ce.id_campaign
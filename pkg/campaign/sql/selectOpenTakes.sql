@@ -0,0 +1,15 @@
+with actions as (
+  select id_campaign_etablissement,
+    last(action order by id) as action,
+    last(username order by id) as username
+  from campaign_etablissement_action
+  group by id_campaign_etablissement
+)
+select a.username, count(*)
+from actions a
+  inner join campaign_etablissement ce on ce.id = a.id_campaign_etablissement
+  inner join campaign c on c.id = ce.id_campaign
+where a.action = 'take'
+  and campaign_status(c.status, c.date_end) = 'open'
+  and a.username = any ($1)
+group by a.username
@@ -0,0 +1,33 @@
+with actions as (
+  select id_campaign_etablissement,
+    last(id order by id) as id,
+    last(action order by id) as action,
+    last(username order by id) as username,
+    last(date_action order by id) as date_action
+  from campaign_etablissement_action
+  group by id_campaign_etablissement
+)
+select c.id,
+  c.libelle,
+  ce.id,
+  a.id,
+  s.siret,
+  s.raison_sociale,
+  s.code_departement,
+  a.username,
+  a.date_action,
+  extract(day from current_timestamp - a.date_action)::integer as days
+from actions a
+  inner join campaign_etablissement ce on ce.id = a.id_campaign_etablissement
+  inner join campaign c on c.id = ce.id_campaign
+  inner join v_summaries s on s.siret = ce.siret
+    and (c.zone is null or s.code_departement = any (c.zone))
+where a.action = 'take'
+  and campaign_status(c.status, c.date_end) = 'open'
+  and a.date_action < current_timestamp - make_interval(days => $3)
+  and (c.id = $1 or $1 is null)
+  and (s.code_departement = any ($2) or $2 is null)
+  and not ($4 and exists (
+    select 1 from campaign_reminder r
+    where r.kind = 'overdue' and r.id_campaign_etablissement_action = a.id))
+order by a.date_action, ce.id
@@ -0,0 +1,24 @@
+with actions as (
+  select id_campaign_etablissement,
+    last(action order by id) as action
+  from campaign_etablissement_action
+  group by id_campaign_etablissement
+)
+select ce.id,
+  s.siret,
+  s.code_departement,
+  array(select f.username
+        from etablissement_follow f
+        where f.siret = ce.siret and f.active
+        union
+        select ef.username
+        from entreprise_follow ef
+        where ef.siren = substring(ce.siret from 1 for 9) and ef.active) as followers
+from campaign_etablissement ce
+  inner join campaign c on c.id = ce.id_campaign
+  inner join v_summaries s on s.siret = ce.siret and s.code_departement = any ($2)
+    and (c.zone is null or s.code_departement = any (c.zone))
+  left join actions a on a.id_campaign_etablissement = ce.id
+where ce.id_campaign = $1
+  and (a.action in ('cancel', 'withdraw') or a.action is null)
+order by ce.rank nulls last, ce.id
@@ -0,0 +1 @@
+select workflow from campaign where id = $1
@@ -0,0 +1,4 @@
+update campaign
+set libelle  = coalesce($2, libelle),
+    date_end = coalesce($3, date_end)
+where id = $1
@@ -0,0 +1,5 @@
+update campaign_import
+set status      = 'applied',
+    nb_inserted = $2,
+    date_apply  = current_timestamp
+where id = $1
@@ -0,0 +1,3 @@
+update campaign
+set status = $2
+where id = $1
@@ -0,0 +1,338 @@
+package campaign
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"datapi/pkg/stats"
+	_ "embed"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gosimple/slug"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/campaignStatsActionsParJour.sql
+var sqlCampaignStatsActionsParJour string
+
+//go:embed sql/campaignStatsDurees.sql
+var sqlCampaignStatsDurees string
+
+//go:embed sql/campaignStatsDepartements.sql
+var sqlCampaignStatsDepartements string
+
+//go:embed sql/campaignStatsAgents.sql
+var sqlCampaignStatsAgents string
+
+//go:embed sql/campaignStatsDetails.sql
+var sqlCampaignStatsDetails string
+
+//go:embed sql/campaignStatsCards.sql
+var sqlCampaignStatsCards string
+
+type ActionsParJour struct {
+	Jour   time.Time `json:"jour" col:"jour" size:"16" dateFormat:"yyyy-mm-dd"`
+	Action string    `json:"action" col:"action" size:"16"`
+	Nombre int       `json:"nombre" col:"nombre" size:"10"`
+}
+
+type DureeTraitement struct {
+	Action  string `json:"action" col:"action" size:"16"`
+	Tranche string `json:"tranche" col:"durée depuis la prise en charge" size:"32"`
+	Nombre  int    `json:"nombre" col:"nombre" size:"10"`
+}
+
+type StatsDepartement struct {
+	CodeDepartement string  `json:"codeDepartement" col:"département" size:"14"`
+	Perimetre       int     `json:"perimetre" col:"périmètre" size:"12"`
+	Taken           int     `json:"taken" col:"en cours" size:"12"`
+	Done            int     `json:"done" col:"terminés" size:"12"`
+	Withdrawn       int     `json:"withdrawn" col:"reportés" size:"12"`
+	Completion      float64 `json:"completion" col:"taux de réalisation" size:"20"`
+}
+
+type StatsAgent struct {
+	Username   string  `json:"username" col:"utilisateur" size:"40"`
+	Taken      int     `json:"taken" col:"prises en charge" size:"16"`
+	Done       int     `json:"done" col:"terminés" size:"12"`
+	Cancelled  int     `json:"cancelled" col:"annulés" size:"12"`
+	Completion float64 `json:"completion" col:"taux de réalisation" size:"20"`
+}
+
+type StatsDetail struct {
+	Action string `json:"action" col:"action" size:"16"`
+	Detail string `json:"detail" col:"détail" size:"40"`
+	Nombre int    `json:"nombre" col:"nombre" size:"10"`
+}
+
+type CampaignStats struct {
+	CampaignID     CampaignID         `json:"campaignID"`
+	ActionsParJour []ActionsParJour   `json:"actionsParJour"`
+	Durees         []DureeTraitement  `json:"durees"`
+	Departements   []StatsDepartement `json:"departements"`
+	Agents         []StatsAgent       `json:"agents"`
+	Details        []StatsDetail      `json:"details"`
+	CardsCreated   int                `json:"cardsCreated"`
+}
+
+type actionsParJours []ActionsParJour
+
+func (a *actionsParJours) Tuple() []interface{} {
+	*a = append(*a, ActionsParJour{})
+	last := &(*a)[len(*a)-1]
+	return []interface{}{&last.Jour, &last.Action, &last.Nombre}
+}
+
+type statsDepartements []StatsDepartement
+
+func (d *statsDepartements) Tuple() []interface{} {
+	*d = append(*d, StatsDepartement{})
+	last := &(*d)[len(*d)-1]
+	return []interface{}{&last.CodeDepartement, &last.Perimetre, &last.Taken, &last.Done, &last.Withdrawn}
+}
+
+type statsAgents []StatsAgent
+
+func (a *statsAgents) Tuple() []interface{} {
+	*a = append(*a, StatsAgent{})
+	last := &(*a)[len(*a)-1]
+	return []interface{}{&last.Username, &last.Taken, &last.Done, &last.Cancelled}
+}
+
+type statsDetails []StatsDetail
+
+func (d *statsDetails) Tuple() []interface{} {
+	*d = append(*d, StatsDetail{})
+	last := &(*d)[len(*d)-1]
+	return []interface{}{&last.Action, &last.Detail, &last.Nombre}
+}
+
+// dureeTranches sont les tranches de durée en jours utilisées pour la distribution
+var dureeTranches = []struct {
+	label string
+	max   time.Duration
+}{
+	{"moins d'un jour", 24 * time.Hour},
+	{"1 à 7 jours", 7 * 24 * time.Hour},
+	{"7 à 30 jours", 30 * 24 * time.Hour},
+	{"30 à 90 jours", 90 * 24 * time.Hour},
+}
+
+const dureeTrancheMax = "plus de 90 jours"
+
+func tranche(duree time.Duration) string {
+	for _, t := range dureeTranches {
+		if duree < t.max {
+			return t.label
+		}
+	}
+	return dureeTrancheMax
+}
+
+// distribution répartit les durées de traitement par action et par tranche
+func distribution(actions []string, secondes []int64) []DureeTraitement {
+	counts := make(map[string]map[string]int)
+	for i, action := range actions {
+		if counts[action] == nil {
+			counts[action] = make(map[string]int)
+		}
+		counts[action][tranche(time.Duration(secondes[i])*time.Second)]++
+	}
+	labels := make([]string, 0, len(dureeTranches)+1)
+	for _, t := range dureeTranches {
+		labels = append(labels, t.label)
+	}
+	labels = append(labels, dureeTrancheMax)
+
+	result := make([]DureeTraitement, 0)
+	for _, action := range []string{"success", "cancel"} {
+		for _, label := range labels {
+			if n := counts[action][label]; n > 0 {
+				result = append(result, DureeTraitement{Action: action, Tranche: label, Nombre: n})
+			}
+		}
+	}
+	return result
+}
+
+func completion(done int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(done) / float64(total)
+}
+
+func selectDurees(ctx context.Context, campaignID CampaignID, zone Zone) ([]DureeTraitement, error) {
+	rows, err := db.Get().Query(ctx, sqlCampaignStatsDurees, campaignID, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var actions []string
+	var secondes []int64
+	for rows.Next() {
+		var action string
+		var duree int64
+		err = rows.Scan(&action, &duree)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+		secondes = append(secondes, duree)
+	}
+	return distribution(actions, secondes), rows.Err()
+}
+
+func selectCampaignStats(ctx context.Context, campaignID CampaignID, zone Zone) (CampaignStats, error) {
+	campaignStats := CampaignStats{CampaignID: campaignID}
+
+	parJour := make(actionsParJours, 0)
+	err := db.Scan(ctx, &parJour, sqlCampaignStatsActionsParJour, campaignID, zone)
+	if err != nil {
+		return CampaignStats{}, err
+	}
+	campaignStats.ActionsParJour = parJour
+
+	campaignStats.Durees, err = selectDurees(ctx, campaignID, zone)
+	if err != nil {
+		return CampaignStats{}, err
+	}
+
+	departements := make(statsDepartements, 0)
+	err = db.Scan(ctx, &departements, sqlCampaignStatsDepartements, campaignID, zone)
+	if err != nil {
+		return CampaignStats{}, err
+	}
+	for i := range departements {
+		departements[i].Completion = completion(departements[i].Done, departements[i].Perimetre)
+	}
+	campaignStats.Departements = departements
+
+	agents := make(statsAgents, 0)
+	err = db.Scan(ctx, &agents, sqlCampaignStatsAgents, campaignID, zone)
+	if err != nil {
+		return CampaignStats{}, err
+	}
+	for i := range agents {
+		agents[i].Completion = completion(agents[i].Done, agents[i].Taken)
+	}
+	campaignStats.Agents = agents
+
+	details := make(statsDetails, 0)
+	err = db.Scan(ctx, &details, sqlCampaignStatsDetails, campaignID, zone)
+	if err != nil {
+		return CampaignStats{}, err
+	}
+	campaignStats.Details = details
+
+	err = db.Get().QueryRow(ctx, sqlCampaignStatsCards, campaignID, zone).Scan(&campaignStats.CardsCreated)
+	return campaignStats, err
+}
+
+func campaignStatsFromContext(c *gin.Context) (CampaignStats, bool) {
+	var s core.Session
+	s.Bind(c)
+
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, `/campaign/stats/:campaignID: le parametre campaignID doit être un entier`)
+		return CampaignStats{}, false
+	}
+	if !CampaignExists(c, CampaignID(campaignID)) {
+		c.JSON(http.StatusNotFound, "aucune campagne trouvée")
+		return CampaignStats{}, false
+	}
+	campaignStats, err := selectCampaignStats(c, CampaignID(campaignID), zoneForUser(s.Username))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, "erreur inattendue: "+err.Error())
+		return CampaignStats{}, false
+	}
+	return campaignStats, true
+}
+
+func statsHandler(c *gin.Context) {
+	campaignStats, ok := campaignStatsFromContext(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, campaignStats)
+}
+
+func statsXlsxHandler(c *gin.Context) {
+	campaignStats, ok := campaignStatsFromContext(c)
+	if !ok {
+		return
+	}
+	workbook := newStatsWorkbook(campaignStats)
+	defer workbook.close()
+	if workbook.err != nil {
+		c.JSON(http.StatusInternalServerError, workbook.err.Error())
+		return
+	}
+	filename := fmt.Sprintf("stats-campaign-%d-%s", campaignStats.CampaignID, time.Now().Format("060102"))
+	c.Header("Content-Disposition", "attachment; filename="+slug.Make(filename)+".xlsx")
+	c.Header("Content-Type", "application/octet-stream")
+	err := workbook.Export(c.Writer)
+	if err != nil {
+		slog.Error("erreur pendant l'export des statistiques de campagne", slog.Any("error", err))
+	}
+}
+
+type statsWorkbook struct {
+	*stats.Workbook
+	err error
+}
+
+type statsSynthese struct {
+	Indicateur string `col:"indicateur" size:"40"`
+	Valeur     int    `col:"valeur" size:"12"`
+}
+
+// newStatsWorkbook écrit une feuille par indicateur
+func newStatsWorkbook(campaignStats CampaignStats) statsWorkbook {
+	workbook := statsWorkbook{Workbook: stats.NewWorkbook()}
+	synthese := []statsSynthese{{"cartes wekan créées depuis la campagne", campaignStats.CardsCreated}}
+	workbook.err = stats.WriteSheet(workbook.Workbook, "synthèse", synthese, func(s statsSynthese) []any {
+		return []any{s.Indicateur, s.Valeur}
+	})
+	if workbook.err != nil {
+		return workbook
+	}
+	workbook.err = stats.WriteSheet(workbook.Workbook, "actions par jour", campaignStats.ActionsParJour, func(a ActionsParJour) []any {
+		return []any{a.Jour, a.Action, a.Nombre}
+	})
+	if workbook.err != nil {
+		return workbook
+	}
+	workbook.err = stats.WriteSheet(workbook.Workbook, "durées de traitement", campaignStats.Durees, func(d DureeTraitement) []any {
+		return []any{d.Action, d.Tranche, d.Nombre}
+	})
+	if workbook.err != nil {
+		return workbook
+	}
+	workbook.err = stats.WriteSheet(workbook.Workbook, "départements", campaignStats.Departements, func(d StatsDepartement) []any {
+		return []any{d.CodeDepartement, d.Perimetre, d.Taken, d.Done, d.Withdrawn, d.Completion}
+	})
+	if workbook.err != nil {
+		return workbook
+	}
+	workbook.err = stats.WriteSheet(workbook.Workbook, "agents", campaignStats.Agents, func(a StatsAgent) []any {
+		return []any{a.Username, a.Taken, a.Done, a.Cancelled, a.Completion}
+	})
+	if workbook.err != nil {
+		return workbook
+	}
+	workbook.err = stats.WriteSheet(workbook.Workbook, "détails", campaignStats.Details, func(d StatsDetail) []any {
+		return []any{d.Action, d.Detail, d.Nombre}
+	})
+	return workbook
+}
+
+func (w statsWorkbook) close() {
+	if err := w.Close(); err != nil {
+		slog.Error("erreur à la fermeture du fichier", slog.Any("error", err))
+	}
+}
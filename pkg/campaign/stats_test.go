@@ -0,0 +1,47 @@
+package campaign
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func Test_distribution_repartitParTranche(t *testing.T) {
+	ass := assert.New(t)
+	heure := int64(time.Hour / time.Second)
+	actions := []string{"success", "success", "cancel", "success"}
+	secondes := []int64{2 * heure, 3 * heure, 48 * heure, 100 * 24 * heure}
+
+	actual := distribution(actions, secondes)
+
+	ass.Equal([]DureeTraitement{
+		{Action: "success", Tranche: "moins d'un jour", Nombre: 2},
+		{Action: "success", Tranche: dureeTrancheMax, Nombre: 1},
+		{Action: "cancel", Tranche: "1 à 7 jours", Nombre: 1},
+	}, actual)
+}
+
+func Test_newStatsWorkbook_ecritUneFeuilleParIndicateur(t *testing.T) {
+	campaignStats := CampaignStats{
+		ActionsParJour: []ActionsParJour{{Jour: time.Now(), Action: "take", Nombre: 3}},
+		Agents:         []StatsAgent{{Username: "a", Taken: 2, Done: 1, Completion: completion(1, 2)}},
+		CardsCreated:   4,
+	}
+
+	workbook := newStatsWorkbook(campaignStats)
+	defer workbook.close()
+	require.NoError(t, workbook.err)
+	buffer := new(bytes.Buffer)
+	require.NoError(t, workbook.Export(buffer))
+
+	xlsx, err := excelize.OpenReader(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"synthèse", "actions par jour", "durées de traitement", "départements", "agents", "détails"}, xlsx.GetSheetList())
+	value, err := xlsx.GetCellValue("agents", "E2")
+	require.NoError(t, err)
+	assert.Equal(t, "0.5", value)
+}
@@ -0,0 +1,294 @@
+package campaign
+
+import (
+	"cmp"
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+const (
+	reasonCard      = "détient une carte"
+	reasonFollow    = "suit l'entreprise"
+	reasonWorkload  = "charge la plus faible"
+	reasonNoAgent   = "aucun agent dans le département"
+	maxSuggestCount = maxBulkSize
+)
+
+// SuggestionAgent est un agent pouvant recevoir des établissements, Load est son nombre de prises en charge ouvertes
+type SuggestionAgent struct {
+	Username string `json:"username"`
+	Zone     Zone   `json:"zone"`
+	Load     int    `json:"load"`
+}
+
+// Suggestion est l'attribution proposée pour un établissement à traiter
+type Suggestion struct {
+	CampaignEtablissementID CampaignEtablissementID `json:"campaignEtablissementID"`
+	Siret                   core.Siret              `json:"siret"`
+	CodeDepartement         string                  `json:"codeDepartement"`
+	Username                string                  `json:"username,omitempty"`
+	Reason                  string                  `json:"reason"`
+}
+
+// Suggestions est la proposition de répartition, Agents donne la charge de chacun après répartition
+type Suggestions struct {
+	CampaignID  CampaignID        `json:"campaignID"`
+	Agents      []SuggestionAgent `json:"agents"`
+	Suggestions []Suggestion      `json:"suggestions"`
+}
+
+type suggestionCandidate struct {
+	ID              CampaignEtablissementID
+	Siret           core.Siret
+	CodeDepartement string
+	Followers       []string
+	CardHolders     []string
+}
+
+type suggestionCandidates []suggestionCandidate
+
+func (s *suggestionCandidates) Tuple() []interface{} {
+	*s = append(*s, suggestionCandidate{})
+	last := &(*s)[len(*s)-1]
+	return []interface{}{&last.ID, &last.Siret, &last.CodeDepartement, &last.Followers}
+}
+
+type acceptParams struct {
+	Assignments []struct {
+		CampaignEtablissementID CampaignEtablissementID `json:"campaignEtablissementID"`
+		Username                string                  `json:"username"`
+	} `json:"assignments"`
+}
+
+// suggestAssignments répartit les établissements dans l'ordre de la liste :
+// un agent du département qui détient une carte ou suit l'entreprise est privilégié,
+// sinon l'établissement revient à l'agent du département le moins chargé
+func suggestAssignments(candidates []suggestionCandidate, agents []SuggestionAgent) ([]Suggestion, []SuggestionAgent) {
+	agents = slices.Clone(agents)
+	slices.SortFunc(agents, func(a, b SuggestionAgent) int { return cmp.Compare(a.Username, b.Username) })
+
+	suggestions := make([]Suggestion, 0, len(candidates))
+	for _, candidate := range candidates {
+		suggestion := Suggestion{
+			CampaignEtablissementID: candidate.ID,
+			Siret:                   candidate.Siret,
+			CodeDepartement:         candidate.CodeDepartement,
+		}
+		eligible := func(agent SuggestionAgent) bool { return slices.Contains(agent.Zone, candidate.CodeDepartement) }
+		choice := leastLoaded(agents, func(agent SuggestionAgent) bool {
+			return eligible(agent) && slices.Contains(candidate.CardHolders, agent.Username)
+		})
+		suggestion.Reason = reasonCard
+		if choice < 0 {
+			choice = leastLoaded(agents, func(agent SuggestionAgent) bool {
+				return eligible(agent) && slices.Contains(candidate.Followers, agent.Username)
+			})
+			suggestion.Reason = reasonFollow
+		}
+		if choice < 0 {
+			choice = leastLoaded(agents, eligible)
+			suggestion.Reason = reasonWorkload
+		}
+		if choice < 0 {
+			suggestion.Reason = reasonNoAgent
+		} else {
+			suggestion.Username = agents[choice].Username
+			agents[choice].Load++
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, agents
+}
+
+// leastLoaded retourne l'indice de l'agent le moins chargé satisfaisant test, -1 si aucun
+func leastLoaded(agents []SuggestionAgent, test func(SuggestionAgent) bool) int {
+	choice := -1
+	for i, agent := range agents {
+		if test(agent) && (choice < 0 || agent.Load < agents[choice].Load) {
+			choice = i
+		}
+	}
+	return choice
+}
+
+// selectSuggestionAgents liste les membres actifs des tableaux de la campagne présents dans zone,
+// la zone de chaque agent est calculée à partir de ses propres tableaux
+func selectSuggestionAgents(ctx context.Context, kanbanService core.KanbanService, re *regexp.Regexp, zone Zone) ([]SuggestionAgent, error) {
+	config := kanbanService.GetWekanConfig()
+	var usernames []string
+	for _, board := range utils.Filter(utils.GetValues(config.Boards), boardMatchesRegexpFunc(re)) {
+		for _, member := range board.Board.Members {
+			if user, ok := config.Users[member.UserID]; ok && member.IsActive {
+				usernames = append(usernames, string(user.Username))
+			}
+		}
+	}
+
+	var agents []SuggestionAgent
+	for _, username := range utils.Uniq(usernames) {
+		boards := utils.Filter(kanbanService.SelectBoardsForUsername(libwekan.Username(username)), boardMatchesRegexpFunc(re))
+		agentZone := intersectZones(zoneFromBoardZones(zonesFromBoards(boards)), zone)
+		if len(agentZone) > 0 {
+			agents = append(agents, SuggestionAgent{Username: username, Zone: agentZone})
+		}
+	}
+
+	loads, err := selectOpenTakes(ctx, utils.Convert(agents, func(agent SuggestionAgent) string { return agent.Username }))
+	if err != nil {
+		return nil, err
+	}
+	for i := range agents {
+		agents[i].Load = loads[agents[i].Username]
+	}
+	return agents, nil
+}
+
+// selectOpenTakes compte les prises en charge en cours des agents sur l'ensemble des campagnes ouvertes
+func selectOpenTakes(ctx context.Context, usernames []string) (map[string]int, error) {
+	rows, err := db.Get().Query(ctx, sqlSelectOpenTakes, usernames)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	loads := make(map[string]int)
+	for rows.Next() {
+		var username string
+		var load int
+		err = rows.Scan(&username, &load)
+		if err != nil {
+			return nil, err
+		}
+		loads[username] = load
+	}
+	return loads, rows.Err()
+}
+
+// appendCardHolders renseigne les membres et assignés des cartes wekan de chaque établissement
+func appendCardHolders(ctx context.Context, candidates []suggestionCandidate, boards []libwekan.ConfigBoard, kanbanService core.KanbanService, username libwekan.Username) error {
+	sirets := utils.Convert(candidates, func(c suggestionCandidate) core.Siret { return c.Siret })
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	cards, err := kanbanService.SelectCardsFromSiretsAndBoardIDs(ctx, sirets, boardIDs, username)
+	if err != nil {
+		return err
+	}
+	users := kanbanService.GetWekanConfig().Users
+	for i := range candidates {
+		for _, card := range utils.Filter(cards, func(card core.KanbanCard) bool { return card.Siret == candidates[i].Siret }) {
+			for _, userID := range append(slices.Clone(card.AssigneeIDs), card.MemberIDs...) {
+				if user, ok := users[userID]; ok {
+					candidates[i].CardHolders = append(candidates[i].CardHolders, string(user.Username))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func selectSuggestions(ctx context.Context, campaignID CampaignID, username libwekan.Username, kanbanService core.KanbanService) (Suggestions, error) {
+	wekanDomainRegexp, err := GetCampaignWekanDomainRegexp(ctx, campaignID)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	re, err := regexp.CompilePOSIX(wekanDomainRegexp)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	boards := utils.Filter(kanbanService.SelectBoardsForUsername(username), boardMatchesRegexpFunc(re))
+	zone := zoneFromBoardZones(zonesFromBoards(boards))
+
+	candidates := make(suggestionCandidates, 0)
+	err = db.Scan(ctx, &candidates, sqlSelectSuggestionPending, campaignID, zone)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	if len(candidates) > 0 {
+		err = appendCardHolders(ctx, candidates, boards, kanbanService, username)
+		if err != nil {
+			return Suggestions{}, err
+		}
+	}
+
+	agents, err := selectSuggestionAgents(ctx, kanbanService, re, zone)
+	if err != nil {
+		return Suggestions{}, err
+	}
+	suggestions, agents := suggestAssignments(candidates, agents)
+	return Suggestions{CampaignID: campaignID, Agents: agents, Suggestions: suggestions}, nil
+}
+
+func suggestHandler(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var s core.Session
+		s.Bind(c)
+
+		campaignID, err := strconv.Atoi(c.Param("campaignID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, `/campaign/suggest/:campaignID: le parametre campaignID doit être un entier`)
+			return
+		}
+		if !CampaignExists(c, CampaignID(campaignID)) {
+			c.JSON(http.StatusNotFound, "aucune campagne trouvée")
+			return
+		}
+		suggestions, err := selectSuggestions(c, CampaignID(campaignID), libwekan.Username(s.Username), kanbanService)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, "erreur inattendue: "+err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, suggestions)
+	}
+}
+
+// acceptSuggestionsHandler attribue les établissements selon la proposition, éventuellement modifiée par le coordinateur
+func acceptSuggestionsHandler(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var s core.Session
+		s.Bind(c)
+
+		campaignID, err := strconv.Atoi(c.Param("campaignID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, `/campaign/suggest/:campaignID/accept: le parametre campaignID doit être un entier`)
+			return
+		}
+		var params acceptParams
+		err = c.Bind(&params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, "décodage de la requete impossible: "+err.Error())
+			return
+		}
+		if len(params.Assignments) == 0 || len(params.Assignments) > maxSuggestCount {
+			c.JSON(http.StatusBadRequest, "la liste assignments doit contenir entre 1 et "+strconv.Itoa(maxSuggestCount)+" éléments")
+			return
+		}
+
+		zone := zoneForUser(s.Username)
+		assigneeZones := make(map[string]Zone)
+		for _, assignment := range params.Assignments {
+			if _, ok := assigneeZones[assignment.Username]; ok {
+				continue
+			}
+			if _, ok := kanbanService.GetUser(libwekan.Username(assignment.Username)); !ok {
+				c.JSON(http.StatusBadRequest, "nom d'utilisateur non présent dans la base: "+assignment.Username)
+				return
+			}
+			assigneeZones[assignment.Username] = intersectZones(zone, zoneForUser(assignment.Username))
+		}
+
+		results := make([]BulkResult, 0, len(params.Assignments))
+		for _, assignment := range params.Assignments {
+			assignee := assignment.Username
+			results = append(results, applyBulk(c, CampaignID(campaignID), []CampaignEtablissementID{assignment.CampaignEtablissementID}, func(ctx context.Context, ids IDs) (Message, error) {
+				return takeOnBehalf(ctx, ids, assignee, assigneeZones[assignee], s.Username)
+			}, publishToStream)...)
+		}
+		c.JSON(http.StatusOK, results)
+	}
+}
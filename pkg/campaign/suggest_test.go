@@ -0,0 +1,75 @@
+package campaign
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_suggestAssignments_repartitParCharge(t *testing.T) {
+	ass := assert.New(t)
+	candidates := []suggestionCandidate{
+		{ID: 1, CodeDepartement: "21"},
+		{ID: 2, CodeDepartement: "21"},
+		{ID: 3, CodeDepartement: "21"},
+	}
+	agents := []SuggestionAgent{
+		{Username: "bob", Zone: Zone{"21"}, Load: 1},
+		{Username: "alice", Zone: Zone{"21"}, Load: 0},
+	}
+
+	suggestions, loads := suggestAssignments(candidates, agents)
+
+	ass.Equal([]string{"alice", "alice", "bob"}, usernamesOf(suggestions))
+	ass.Equal([]SuggestionAgent{
+		{Username: "alice", Zone: Zone{"21"}, Load: 2},
+		{Username: "bob", Zone: Zone{"21"}, Load: 2},
+	}, loads)
+	ass.Equal(1, agents[0].Load)
+}
+
+func Test_suggestAssignments_privilegieCarteEtSuivi(t *testing.T) {
+	ass := assert.New(t)
+	candidates := []suggestionCandidate{
+		{ID: 1, CodeDepartement: "21", Followers: []string{"bob"}, CardHolders: []string{"carol"}},
+		{ID: 2, CodeDepartement: "21", Followers: []string{"bob"}},
+		{ID: 3, CodeDepartement: "21", CardHolders: []string{"dave"}},
+	}
+	agents := []SuggestionAgent{
+		{Username: "alice", Zone: Zone{"21"}},
+		{Username: "bob", Zone: Zone{"21"}, Load: 5},
+		{Username: "carol", Zone: Zone{"21"}, Load: 5},
+		{Username: "dave", Zone: Zone{"25"}},
+	}
+
+	suggestions, _ := suggestAssignments(candidates, agents)
+
+	ass.Equal([]string{"carol", "bob", "alice"}, usernamesOf(suggestions))
+	ass.Equal([]string{reasonCard, reasonFollow, reasonWorkload}, reasonsOf(suggestions))
+}
+
+func Test_suggestAssignments_sansAgentDansLeDepartement(t *testing.T) {
+	ass := assert.New(t)
+	suggestions, _ := suggestAssignments(
+		[]suggestionCandidate{{ID: 1, CodeDepartement: "75"}},
+		[]SuggestionAgent{{Username: "alice", Zone: Zone{"21"}}},
+	)
+	ass.Empty(suggestions[0].Username)
+	ass.Equal(reasonNoAgent, suggestions[0].Reason)
+}
+
+func usernamesOf(suggestions []Suggestion) []string {
+	var usernames []string
+	for _, s := range suggestions {
+		usernames = append(usernames, s.Username)
+	}
+	return usernames
+}
+
+func reasonsOf(suggestions []Suggestion) []string {
+	var reasons []string
+	for _, s := range suggestions {
+		reasons = append(reasons, s.Reason)
+	}
+	return reasons
+}
@@ -3,7 +3,6 @@ package campaign
 import (
 	"context"
 	"datapi/pkg/core"
-	"datapi/pkg/db"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -34,6 +33,8 @@ func takeHandler(c *gin.Context) {
 
 	if errors.As(err, &PendingNotFoundError{}) {
 		c.JSON(http.StatusUnprocessableEntity, "établissement indisponible pour cette action")
+	} else if errors.As(err, &IllegalTransitionError{}) || errors.As(err, &CampaignClosedError{}) {
+		c.JSON(http.StatusUnprocessableEntity, err.Error())
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, "erreur imprévue")
 	} else {
@@ -43,16 +44,9 @@ func takeHandler(c *gin.Context) {
 }
 
 func take(ctx context.Context, ids IDs, username string) (Message, error) {
-	var campaignEtablissementActionID *int
-	var codeDepartement *string
-	zone := zoneForUser(username)
-
-	dbConn := db.Get()
-	row := dbConn.QueryRow(ctx, sqlTakePendingEtablissement, ids.CampaignID, ids.CampaignEtablissementID, username, zone)
-	err := row.Scan(&campaignEtablissementActionID, &codeDepartement)
-
-	if campaignEtablissementActionID == nil || codeDepartement == nil {
-		return Message{}, PendingNotFoundError{err: errors.New("aucun id retourné par l'insert")}
+	codeDepartement, err := applyAction(ctx, ids, username, Action{action: "take"})
+	if errors.As(err, &CampaignEtablissementNotFoundError{}) {
+		return Message{}, PendingNotFoundError{err: err}
 	} else if err != nil {
 		return Message{}, err
 	}
@@ -60,7 +54,7 @@ func take(ctx context.Context, ids IDs, username string) (Message, error) {
 	return Message{
 		CampaignID:              ids.CampaignID,
 		CampaignEtablissementID: &ids.CampaignEtablissementID,
-		Zone:                    []string{*codeDepartement},
+		Zone:                    []string{codeDepartement},
 		Type:                    "pending",
 		Username:                username,
 	}, nil
@@ -31,6 +31,7 @@ func (p *TakenActions) Tuple() []interface{} {
 		&ce.Username,
 		&ce.Detail,
 		&ce.CodeDepartement,
+		&ce.Comment,
 	}
 }
 
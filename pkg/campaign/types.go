@@ -21,6 +21,7 @@ type Campaign struct {
 	Libelle           string     `json:"libelle"`
 	DateEnd           time.Time  `json:"dateFin"`
 	DateCreate        time.Time  `json:"dateCreate"`
+	Status            Status     `json:"status"`
 	WekanDomainRegexp string     `json:"wekanDomainRegexp"`
 	NBPerimetre       int        `json:"nbPerimetre"`
 	NBPending         int        `json:"nbPending"`
@@ -34,6 +35,7 @@ type Campaign struct {
 type Campaigns []*Campaign
 
 type Message struct {
+	ID                      int64                    `json:"id,omitempty"`
 	CampaignID              CampaignID               `json:"campaignID"`
 	CampaignEtablissementID *CampaignEtablissementID `json:"campaignEtablissementID"`
 	Zone                    []string                 `json:"zone"`
@@ -75,6 +77,7 @@ type CampaignEtablissement struct {
 	List                *string                 `json:"list,omitempty"`
 	Description         *string                 `json:"description,omitempty"`
 	Detail              *string                 `json:"detail,omitempty"`
+	Comment             *string                 `json:"comment,omitempty"`
 }
 
 type Pending struct {
@@ -105,12 +108,18 @@ type actionEffect struct {
 
 type Event struct {
 	Message       chan Message
-	NewClients    chan chan Message
-	ClosedClients chan chan Message
-	TotalClients  map[chan Message]bool
+	NewClients    chan *Client
+	ClosedClients chan *Client
+	TotalClients  map[*Client]bool
+	log           EventLog
+	journalized   chan Message
 }
 
-type ClientChan chan Message
+// Client est un abonné au flux, limité aux campagnes demandées (toutes si vide)
+type Client struct {
+	Messages  chan Message
+	Campaigns []CampaignID
+}
 
 type BoardZones map[string][]string
 type Zone []string
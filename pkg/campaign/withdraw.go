@@ -3,7 +3,6 @@ package campaign
 import (
 	"context"
 	"datapi/pkg/core"
-	"datapi/pkg/db"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -11,28 +10,19 @@ import (
 )
 
 func withdrawPending(ctx context.Context, ids IDs, username string, detail string) (Message, error) {
-	var campaignEtablissementActionID *int
-	var codeDepartement *string
-
-	zone := zoneForUser(username)
-
-	dbConn := db.Get()
-	row := dbConn.QueryRow(ctx, sqlWithdrawPendingEtablissement, ids.CampaignID,
-		ids.CampaignEtablissementID, username, zone, detail)
-	err := row.Scan(&campaignEtablissementActionID, &codeDepartement)
-
-	if campaignEtablissementActionID == nil || codeDepartement == nil {
-		return Message{}, TakeNotFoundError{err: errors.New("aucun id retourné par l'insert")}
+	codeDepartement, err := applyAction(ctx, ids, username, Action{action: "withdraw", detail: detail})
+	if errors.As(err, &CampaignEtablissementNotFoundError{}) {
+		return Message{}, TakeNotFoundError{err: err}
 	} else if err != nil {
 		return Message{}, err
 	}
 
 	message := Message{
-		ids.CampaignID,
-		&ids.CampaignEtablissementID,
-		[]string{*codeDepartement},
-		"withdraw",
-		string(username),
+		CampaignID:              ids.CampaignID,
+		CampaignEtablissementID: &ids.CampaignEtablissementID,
+		Zone:                    []string{codeDepartement},
+		Type:                    "withdraw",
+		Username:                string(username),
 	}
 
 	return message, nil
@@ -48,10 +38,6 @@ func withdrawHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, "décodage de la requete impossible: "+err.Error())
 		return
 	}
-	if params.Detail == "" {
-		ctx.JSON(http.StatusBadRequest, "le paramètre detail doit contenir au moins 1 caractère")
-		return
-	}
 
 	campaignID, err := strconv.Atoi(ctx.Param("campaignID"))
 	if err != nil {
@@ -78,6 +64,9 @@ func withdrawHandler(ctx *gin.Context) {
 	if errors.As(err, &TakeNotFoundError{}) {
 		ctx.JSON(http.StatusUnprocessableEntity, "traitement indisponible pour cet établissement")
 		return
+	} else if errors.As(err, &IllegalTransitionError{}) || errors.As(err, &CampaignClosedError{}) {
+		ctx.JSON(http.StatusUnprocessableEntity, err.Error())
+		return
 	} else if err != nil {
 		ctx.JSON(http.StatusInternalServerError, "erreur innattendue")
 		return
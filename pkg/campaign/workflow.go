@@ -0,0 +1,257 @@
+package campaign
+
+import (
+	"context"
+	"datapi/pkg/db"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// State est l'état d'un établissement dans une campagne, déduit de la dernière action
+type State string
+
+// Transition décrit une action autorisée depuis un ensemble d'états
+type Transition struct {
+	Action  string   `json:"action"`
+	From    []State  `json:"from"`
+	To      State    `json:"to"`
+	Owner   bool     `json:"owner,omitempty"`
+	Detail  bool     `json:"detail,omitempty"`
+	Details []string `json:"details,omitempty"`
+}
+
+// RollOver décrit le devenir d'une action lors du passage à la campagne suivante
+type RollOver struct {
+	Action string `json:"action"`
+	Detail string `json:"detail"`
+	Next   string `json:"next,omitempty"`
+	Drop   bool   `json:"drop,omitempty"`
+}
+
+// Workflow est la définition déclarative du cycle de vie des établissements d'une campagne
+type Workflow struct {
+	Initial     State        `json:"initial"`
+	States      []State      `json:"states"`
+	Transitions []Transition `json:"transitions"`
+	RollOvers   []RollOver   `json:"rollOvers"`
+}
+
+// DefaultWorkflow reprend les règles historiques des campagnes
+func DefaultWorkflow() Workflow {
+	return Workflow{
+		Initial: "pending",
+		States:  []State{"pending", "taken", "withdrawn", "done"},
+		Transitions: []Transition{
+			{Action: "take", From: []State{"pending", "withdrawn"}, To: "taken"},
+			{Action: "success", From: []State{"taken"}, To: "done", Owner: true, Detail: true},
+			{Action: "cancel", From: []State{"taken"}, To: "pending", Owner: true, Detail: true},
+			{Action: "withdraw", From: []State{"pending"}, To: "withdrawn", Detail: true},
+		},
+		RollOvers: []RollOver{
+			{Action: "withdraw", Detail: "delai_9mois", Next: "delai_6mois"},
+			{Action: "withdraw", Detail: "delai_6mois", Next: "delai_3mois"},
+			{Action: "withdraw", Detail: "delai_3mois", Drop: true},
+		},
+	}
+}
+
+// etablissementState est la dernière action enregistrée pour un établissement de campagne
+type etablissementState struct {
+	action   *string
+	username *string
+}
+
+// Validate vérifie la cohérence de la définition du workflow
+func (w Workflow) Validate() error {
+	if !slices.Contains(w.States, w.Initial) {
+		return fmt.Errorf("état initial inconnu: %s", w.Initial)
+	}
+	targets := make(map[string]State)
+	for _, t := range w.Transitions {
+		if t.Action == "" {
+			return errors.New("une transition n'a pas d'action")
+		}
+		if !slices.Contains(w.States, t.To) {
+			return fmt.Errorf("état inconnu pour l'action %s: %s", t.Action, t.To)
+		}
+		for _, from := range t.From {
+			if !slices.Contains(w.States, from) {
+				return fmt.Errorf("état inconnu pour l'action %s: %s", t.Action, from)
+			}
+		}
+		if to, ok := targets[t.Action]; ok && to != t.To {
+			return fmt.Errorf("l'action %s mène à plusieurs états", t.Action)
+		}
+		targets[t.Action] = t.To
+	}
+	for _, r := range w.RollOvers {
+		if _, ok := targets[r.Action]; !ok {
+			return fmt.Errorf("report d'une action inconnue: %s", r.Action)
+		}
+		if !r.Drop && r.Next == "" {
+			return fmt.Errorf("report de l'action %s sans détail suivant ni abandon", r.Action)
+		}
+	}
+	return nil
+}
+
+func (w Workflow) state(current etablissementState) (State, error) {
+	if current.action == nil {
+		return w.Initial, nil
+	}
+	for _, t := range w.Transitions {
+		if t.Action == *current.action {
+			return t.To, nil
+		}
+	}
+	return "", IllegalTransitionError{msg: "état inconnu après l'action " + *current.action}
+}
+
+func (w Workflow) hasAction(action string) bool {
+	return slices.ContainsFunc(w.Transitions, func(t Transition) bool {
+		return t.Action == action
+	})
+}
+
+func (w Workflow) transition(from State, action string) (Transition, bool) {
+	for _, t := range w.Transitions {
+		if t.Action == action && slices.Contains(t.From, from) {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// check valide l'action demandée par username au regard de l'état courant de l'établissement
+func (w Workflow) check(current etablissementState, username string, action Action) error {
+	if !w.hasAction(action.action) {
+		return IllegalTransitionError{msg: "action inconnue: " + action.action}
+	}
+	from, err := w.state(current)
+	if err != nil {
+		return err
+	}
+	transition, ok := w.transition(from, action.action)
+	if !ok {
+		return IllegalTransitionError{msg: fmt.Sprintf("l'action %s est impossible depuis l'état %s", action.action, from)}
+	}
+	if transition.Owner && (current.username == nil || *current.username != username) {
+		return IllegalTransitionError{msg: fmt.Sprintf("l'action %s est réservée à l'utilisateur qui a pris en charge l'établissement", action.action)}
+	}
+	if transition.Detail && action.detail == "" {
+		return IllegalTransitionError{msg: fmt.Sprintf("l'action %s nécessite un détail", action.action)}
+	}
+	if len(transition.Details) > 0 && !slices.Contains(transition.Details, action.detail) {
+		return IllegalTransitionError{msg: fmt.Sprintf("détail invalide pour l'action %s: %s", action.action, action.detail)}
+	}
+	return nil
+}
+
+// RollOver retourne le détail à reporter dans la campagne suivante, false si l'action n'est pas reportée
+func (w Workflow) RollOver(action string, detail string) (string, bool) {
+	for _, r := range w.RollOvers {
+		if r.Action == action && r.Detail == detail {
+			return r.Next, !r.Drop
+		}
+	}
+	return detail, true
+}
+
+// applyAction valide l'action au regard du workflow de la campagne puis l'enregistre
+func applyAction(ctx context.Context, ids IDs, username string, action Action) (string, error) {
+	return applyActionInZone(ctx, ids, username, zoneForUser(username), action)
+}
+
+// applyActionInZone enregistre l'action pour username sur un établissement de la zone
+func applyActionInZone(ctx context.Context, ids IDs, username string, zone Zone, action Action) (string, error) {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var status Status
+	var workflow *Workflow
+	var current etablissementState
+	err = tx.QueryRow(ctx, sqlSelectEtablissementState, ids.CampaignID, ids.CampaignEtablissementID, zone).
+		Scan(&status, &workflow, &current.action, &current.username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", CampaignEtablissementNotFoundError{err: err}
+	} else if err != nil {
+		return "", err
+	}
+	if status != StatusOpen {
+		return "", CampaignClosedError{status: status}
+	}
+	if workflow == nil {
+		defaultWorkflow := DefaultWorkflow()
+		workflow = &defaultWorkflow
+	}
+
+	err = workflow.check(current, username, action)
+	if err != nil {
+		return "", err
+	}
+
+	var campaignEtablissementActionID int
+	var codeDepartement string
+	err = tx.QueryRow(ctx, sqlInsertAction, ids.CampaignID, ids.CampaignEtablissementID,
+		username, action.action, action.detail).Scan(&campaignEtablissementActionID, &codeDepartement)
+	if err != nil {
+		return "", err
+	}
+	return codeDepartement, tx.Commit(ctx)
+}
+
+// GetWorkflow retourne le workflow de la campagne, le workflow par défaut si aucun n'est défini
+func GetWorkflow(ctx context.Context, campaignID CampaignID) (Workflow, error) {
+	var workflow *Workflow
+	err := db.Get().QueryRow(ctx, sqlSelectWorkflow, campaignID).Scan(&workflow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Workflow{}, CampaignNotFoundError{err: err}
+	} else if err != nil {
+		return Workflow{}, err
+	}
+	if workflow == nil {
+		return DefaultWorkflow(), nil
+	}
+	return *workflow, nil
+}
+
+// SetWorkflow enregistre le workflow de la campagne après validation
+func SetWorkflow(ctx context.Context, q db.Querier, campaignID CampaignID, workflow Workflow) error {
+	err := workflow.Validate()
+	if err != nil {
+		return InvalidWorkflowError{err: err}
+	}
+	tag, err := q.Exec(ctx, "update campaign set workflow = $2 where id = $1", campaignID, workflow)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return CampaignNotFoundError{err: errors.New("aucune campagne mise à jour")}
+	}
+	return nil
+}
+
+func workflowHandler(c *gin.Context) {
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, `/campaign/workflow/:campaignID: le parametre campaignID doit être un entier`)
+		return
+	}
+	workflow, err := GetWorkflow(c, CampaignID(campaignID))
+	if errors.As(err, &CampaignNotFoundError{}) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, "erreur imprévue")
+		return
+	}
+	c.JSON(http.StatusOK, workflow)
+}
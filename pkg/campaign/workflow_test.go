@@ -0,0 +1,72 @@
+package campaign
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Workflow_check_refuseSuccessSansPriseEnCharge(t *testing.T) {
+	ass := assert.New(t)
+	workflow := DefaultWorkflow()
+
+	err := workflow.check(etablissementState{}, "user", Action{action: "success", detail: "ok"})
+
+	ass.ErrorAs(err, &IllegalTransitionError{})
+	ass.ErrorContains(err, "impossible depuis l'état pending")
+}
+
+func Test_Workflow_check_reserveLaClotureAuPreneur(t *testing.T) {
+	ass := assert.New(t)
+	workflow := DefaultWorkflow()
+	take := "take"
+	owner := "owner"
+	current := etablissementState{action: &take, username: &owner}
+
+	ass.NoError(workflow.check(current, "owner", Action{action: "success", detail: "ok"}))
+	ass.ErrorAs(workflow.check(current, "other", Action{action: "success", detail: "ok"}), &IllegalTransitionError{})
+	ass.ErrorAs(workflow.check(current, "owner", Action{action: "success"}), &IllegalTransitionError{})
+	ass.ErrorAs(workflow.check(current, "owner", Action{action: "take"}), &IllegalTransitionError{})
+}
+
+func Test_Workflow_check_autoriseLaRepriseApresAnnulation(t *testing.T) {
+	ass := assert.New(t)
+	workflow := DefaultWorkflow()
+	cancel := "cancel"
+	withdraw := "withdraw"
+
+	ass.NoError(workflow.check(etablissementState{action: &cancel}, "user", Action{action: "take"}))
+	ass.NoError(workflow.check(etablissementState{action: &withdraw}, "user", Action{action: "take"}))
+	ass.ErrorAs(workflow.check(etablissementState{action: &withdraw}, "user", Action{action: "withdraw", detail: "delai_3mois"}), &IllegalTransitionError{})
+}
+
+func Test_Workflow_RollOver(t *testing.T) {
+	ass := assert.New(t)
+	workflow := DefaultWorkflow()
+
+	detail, keep := workflow.RollOver("withdraw", "delai_9mois")
+	ass.True(keep)
+	ass.Equal("delai_6mois", detail)
+	_, keep = workflow.RollOver("withdraw", "delai_3mois")
+	ass.False(keep)
+	detail, keep = workflow.RollOver("withdraw", "autre")
+	ass.True(keep)
+	ass.Equal("autre", detail)
+}
+
+func Test_Workflow_Validate(t *testing.T) {
+	ass := assert.New(t)
+	ass.NoError(DefaultWorkflow().Validate())
+
+	workflow := DefaultWorkflow()
+	workflow.Transitions = append(workflow.Transitions, Transition{Action: "take", From: []State{"done"}, To: "pending"})
+	ass.Error(workflow.Validate())
+
+	workflow = DefaultWorkflow()
+	workflow.Initial = "unknown"
+	ass.Error(workflow.Validate())
+
+	workflow = DefaultWorkflow()
+	workflow.RollOvers = append(workflow.RollOvers, RollOver{Action: "withdraw", Detail: "delai_1mois"})
+	ass.Error(workflow.Validate())
+}
@@ -35,13 +35,14 @@ var Kanban KanbanService
 
 type Datapi struct {
 	saveAccessLog AccessLogSaver
+	accessLogs    AccessLogReader
 	KanbanService KanbanService
 }
 
 // PrepareDatapi se connecte aux bases de données et keycloak
-func PrepareDatapi(kanbanService KanbanService, saver AccessLogSaver) (*Datapi, error) {
+func PrepareDatapi(kanbanService KanbanService, saver AccessLogSaver, reader AccessLogReader) (*Datapi, error) {
 	var err error
-	db.Init() // fail fast - on n'attend pas la première requête pour savoir si on peut se connecter à la db
+	db.Get() // fail fast - on n'attend pas la première requête pour savoir si on peut se connecter à la db
 	Departements, err = loadDepartementReferentiel()
 	if err != nil {
 		return nil, fmt.Errorf("erreur pendant le chargement du référentiel des départements : %w", err)
@@ -60,6 +61,7 @@ func PrepareDatapi(kanbanService KanbanService, saver AccessLogSaver) (*Datapi,
 
 	datapi := Datapi{
 		saveAccessLog: saver,
+		accessLogs:    reader,
 		KanbanService: kanbanService,
 	}
 	return &datapi, nil
@@ -73,7 +75,7 @@ func (datapi *Datapi) InitAPI(router *gin.Engine) {
 	config.AddExposeHeaders("Content-Disposition", "responseType", "Content-Type", "Cache-Control", "Connection", "Transfer-Encoding", "X-Accel-Buffering")
 
 	config.AddAllowHeaders("Authorization", "responseType")
-	config.AddAllowMethods("GET", "POST", "DELETE")
+	config.AddAllowMethods("GET", "POST", "PUT", "DELETE")
 	router.Use(cors.New(config))
 	const (
 		IP_REVERSE_PROXY       string = "10.0.2.100"
@@ -90,12 +92,12 @@ func (datapi *Datapi) InitAPI(router *gin.Engine) {
 	}
 
 	entreprise := router.Group("/entreprise", AuthMiddleware(), datapi.LogMiddleware)
-	entreprise.GET("/viewers/:siren", checkSirenFormat, getEntrepriseViewers)
+	entreprise.GET("/viewers/:siren", checkSirenFormat, datapi.getEntrepriseViewers)
 	entreprise.GET("/get/:siren", checkSirenFormat, getEntreprise)
 	entreprise.GET("/all/:siren", checkSirenFormat, getEntrepriseEtablissements)
 
 	etablissement := router.Group("/etablissement", AuthMiddleware(), datapi.LogMiddleware)
-	etablissement.GET("/viewers/:siret", checkSiretFormat, getEtablissementViewers)
+	etablissement.GET("/viewers/:siret", checkSiretFormat, datapi.getEtablissementViewers)
 	etablissement.GET("/get/:siret", checkSiretFormat, getEtablissement)
 	etablissement.GET("/comments/:siret", checkSiretFormat, getEntrepriseComments)
 	etablissement.POST("/comments/:siret", checkSiretFormat, addEntrepriseComment)
@@ -103,10 +105,22 @@ func (datapi *Datapi) InitAPI(router *gin.Engine) {
 	etablissement.POST("/search", searchEtablissementHandler)
 	etablissement.POST("/search/total", searchEtablissementTotalHandler)
 
+	groupe := router.Group("/groupe", AuthMiddleware(), datapi.LogMiddleware)
+	groupe.GET("/:sirenGroupe", getGroupeHandler)
+
+	me := router.Group("/me", AuthMiddleware(), datapi.LogMiddleware)
+	me.GET("", getProfileHandler)
+	me.PUT("/preferences", updatePreferencesHandler)
+	me.GET("/history", datapi.getHistoryHandler)
+	me.DELETE("/history", datapi.deleteHistoryHandler)
+
 	follow := router.Group("/follow", AuthMiddleware(), datapi.LogMiddleware)
 	follow.GET("", getEtablissementsFollowedByCurrentUser)
 	follow.POST("/:siret", checkSiretFormat, followEtablissement)
 	follow.DELETE("/:siret", checkSiretFormat, unfollowEtablissement)
+	follow.GET("/entreprise", getEntreprisesFollowedByCurrentUser)
+	follow.POST("/entreprise/:siren", checkSirenFormat, followEntrepriseHandler)
+	follow.DELETE("/entreprise/:siren", checkSirenFormat, unfollowEntrepriseHandler)
 
 	export := router.Group("/export/", AuthMiddleware(), datapi.LogMiddleware)
 	export.POST("/xlsx/follow", getXLSXFollowedByCurrentUser)
@@ -250,10 +264,20 @@ func configureKanbanEndpoint(path string, api *gin.Engine, handlers ...gin.Handl
 	kanban.GET("/unarchive/:cardID", CheckAnyRolesMiddleware("wekan"), kanbanUnarchiveCardHandler)
 	kanban.POST("/updateCard", CheckAnyRolesMiddleware("wekan"), kanbanUpdateCardHandler)
 	kanban.POST("/card", CheckAnyRolesMiddleware("wekan"), kanbanNewCardHandler)
+	kanban.POST("/cards/bulk", CheckAnyRolesMiddleware("wekan"), kanbanBulkCardsHandler)
 	kanban.GET("/card/join/:cardID", CheckAnyRolesMiddleware("wekan"), kanbanJoinCardHandler)
 	kanban.GET("/card/part/:cardID", CheckAnyRolesMiddleware("wekan"), kanbanPartCardHandler)
 	kanban.GET("/card/get/:cardID", CheckAnyRolesMiddleware("wekan"), kanbanGetCardHandler)
 	kanban.GET("/card/membersHistory/:cardID", CheckAnyRolesMiddleware("wekan"), kanbanGetCardMembersHistoryHandler)
+	kanban.GET("/card/timeline/:cardID", CheckAnyRolesMiddleware("wekan"), kanbanGetCardTimelineHandler)
+	kanban.PUT("/card/:cardID/labels", CheckAnyRolesMiddleware("wekan"), kanbanUpdateCardLabelsHandler)
+	kanban.PUT("/card/:cardID/users", CheckAnyRolesMiddleware("wekan"), kanbanUpdateCardUsersHandler)
+	kanban.PUT("/card/:cardID/startAt", CheckAnyRolesMiddleware("wekan"), kanbanSetCardStartAtHandler)
+	kanban.PUT("/card/:cardID/endAt", CheckAnyRolesMiddleware("wekan"), kanbanSetCardEndAtHandler)
+	kanban.PUT("/card/:cardID/list", CheckAnyRolesMiddleware("wekan"), kanbanMoveCardHandler)
+	kanban.POST("/card/:cardID/comments", CheckAnyRolesMiddleware("wekan"), kanbanAddCardCommentHandler)
+	kanban.PUT("/card/:cardID/comments/:commentID", CheckAnyRolesMiddleware("wekan"), kanbanUpdateCardCommentHandler)
+	kanban.DELETE("/card/:cardID/comments/:commentID", CheckAnyRolesMiddleware("wekan"), kanbanDeleteCardCommentHandler)
 }
 
 // True made global to ease pointers
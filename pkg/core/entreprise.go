@@ -1039,64 +1039,6 @@ func getSiegeFromSiren(siren string) (string, error) {
 	return siret, err
 }
 
-func getEntrepriseViewers(c *gin.Context) {
-	sqlViewers := `select username, firstname, lastname from v_roles r
-		inner join users u on u.roles && r.roles
-		where siren = $1`
-
-	siren := c.Param("siren")
-	rows, err := db.Get().Query(context.Background(), sqlViewers, siren)
-	defer rows.Close()
-	if err != nil {
-		c.JSON(500, err.Error())
-		return
-	}
-	var users []keycloakUser
-	for rows.Next() {
-		var u keycloakUser
-		err := rows.Scan(&u.Username, &u.FirstName, &u.LastName)
-		if err != nil {
-			c.JSON(500, err.Error())
-			return
-		}
-		users = append(users, u)
-	}
-	if len(users) == 0 {
-		c.JSON(204, "")
-		return
-	}
-	c.JSON(200, users)
-}
-
-func getEtablissementViewers(c *gin.Context) {
-	sqlViewers := `select username, firstname, lastname from v_roles r
-		inner join users u on u.roles && r.roles
-		where siren = $1`
-
-	siren := c.Param("siret")[0:9]
-	rows, err := db.Get().Query(context.Background(), sqlViewers, siren)
-	defer rows.Close()
-	if err != nil {
-		c.JSON(500, err.Error())
-		return
-	}
-	var users []keycloakUser
-	for rows.Next() {
-		var u keycloakUser
-		err := rows.Scan(&u.Username, &u.FirstName, &u.LastName)
-		if err != nil {
-			c.JSON(500, err.Error())
-			return
-		}
-		users = append(users, u)
-	}
-	if len(users) == 0 {
-		c.JSON(204, "")
-		return
-	}
-	c.JSON(200, users)
-}
-
 func sumPFloats(floats ...*float64) float64 {
 	var total float64
 	for _, f := range floats {
@@ -43,3 +43,19 @@ type DatabaseExecutionError struct {
 func (e DatabaseExecutionError) Error() string {
 	return fmt.Sprintf("la requête `%s` a rencontré une erreur", e.QueryIdentifier)
 }
+
+type UnknownLabelError struct {
+	LabelIdentifier string
+}
+
+func (e UnknownLabelError) Error() string {
+	return fmt.Sprintf("aucune étiquette trouvée avec l'identifiant `%s`", e.LabelIdentifier)
+}
+
+type UnknownCommentError struct {
+	CommentIdentifier string
+}
+
+func (e UnknownCommentError) Error() string {
+	return fmt.Sprintf("aucun commentaire trouvé avec l'identifiant `%s`", e.CommentIdentifier)
+}
@@ -4,13 +4,15 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
 	"datapi/pkg/db"
+	"datapi/pkg/docx"
 	"datapi/pkg/utils"
-	"encoding/json"
 	"fmt"
 	"github.com/signaux-faibles/libwekan"
 	"net/http"
-	"os/exec"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -28,6 +30,7 @@ func (cards KanbanExports) xlsx(wekan bool) ([]byte, error) {
 
 	row := xlSheet.AddRow()
 	row.AddCell().Value = "Raison sociale"
+	row.AddCell().Value = "Siren"
 	row.AddCell().Value = "Siret"
 	row.AddCell().Value = "Type d'établissement"
 	row.AddCell().Value = "Tête de groupe"
@@ -63,6 +66,7 @@ func (cards KanbanExports) xlsx(wekan bool) ([]byte, error) {
 	for _, e := range cards {
 		row := xlSheet.AddRow()
 		row.AddCell().Value = e.RaisonSociale
+		row.AddCell().Value = e.siren()
 		row.AddCell().Value = e.Siret
 		row.AddCell().Value = e.TypeEtablissement
 		row.AddCell().Value = e.TeteDeGroupe
@@ -108,6 +112,35 @@ func (cards KanbanExports) xlsx(wekan bool) ([]byte, error) {
 	return data.Bytes(), nil
 }
 
+func (kanbanExport KanbanExport) siren() string {
+	if len(kanbanExport.Siret) < 9 {
+		return kanbanExport.Siret
+	}
+	return kanbanExport.Siret[0:9]
+}
+
+// groupByEntreprise regroupe les établissements sous leur entreprise, le siège en premier
+func (cards KanbanExports) groupByEntreprise() KanbanExports {
+	grouped := slices.Clone(cards)
+	firstIndex := make(map[string]int)
+	for i, card := range cards {
+		if _, ok := firstIndex[card.siren()]; !ok {
+			firstIndex[card.siren()] = i
+		}
+	}
+	sort.SliceStable(grouped, func(i, j int) bool {
+		if grouped[i].siren() != grouped[j].siren() {
+			return firstIndex[grouped[i].siren()] < firstIndex[grouped[j].siren()]
+		}
+		return grouped[i].isSiege() && !grouped[j].isSiege()
+	})
+	return grouped
+}
+
+func (kanbanExport KanbanExport) isSiege() bool {
+	return kanbanExport.TypeEtablissement == "Siège social"
+}
+
 func boolToString(boolean bool, true string, false string) string {
 	if boolean {
 		return true
@@ -115,35 +148,52 @@ func boolToString(boolean bool, true string, false string) string {
 	return false
 }
 
-func (kanbanExport KanbanExport) docx(head ExportHeader) (Docx, error) {
-	// docxify prend un tableau en entrée pour un rendu multipage
-	input := []interface{}{kanbanExport}
-	data, err := json.MarshalIndent(input, " ", " ")
-	script := viper.GetString("docxifyPath")
-	dir := viper.GetString("docxifyWorkingDir")
-	python := viper.GetString("docxifyPython")
-	if err != nil {
-		return Docx{}, err
+// docxFields valeurs des champs de fusion de la fiche établissement
+func (kanbanExport KanbanExport) docxFields() docx.Fields {
+	return docx.Fields{
+		"raison_sociale":               kanbanExport.RaisonSociale,
+		"siret":                        kanbanExport.Siret,
+		"type_etablissement":           kanbanExport.TypeEtablissement,
+		"tete_de_groupe":               kanbanExport.TeteDeGroupe,
+		"departement":                  kanbanExport.Departement,
+		"commune":                      kanbanExport.Commune,
+		"territoire_industrie":         kanbanExport.TerritoireIndustrie,
+		"secteur_activite":             kanbanExport.SecteurActivite,
+		"activite":                     kanbanExport.Activite,
+		"secteurs_covid":               kanbanExport.SecteursCovid,
+		"statut_juridique":             kanbanExport.StatutJuridique,
+		"date_ouverture_etablissement": kanbanExport.DateOuvertureEtablissement,
+		"date_creation_entreprise":     kanbanExport.DateCreationEntreprise,
+		"effectif":                     kanbanExport.Effectif,
+		"activite_partielle":           kanbanExport.ActivitePartielle,
+		"dette_sociale":                kanbanExport.DetteSociale,
+		"part_salariale":               kanbanExport.PartSalariale,
+		"annee_exercice":               kanbanExport.AnneeExercice,
+		"ca":                           kanbanExport.ChiffreAffaire,
+		"ebe":                          kanbanExport.ExcedentBrutExploitation,
+		"rex":                          kanbanExport.ResultatExploitation,
+		"procol":                       kanbanExport.ProcedureCollective,
+		"detection_sf":                 kanbanExport.DetectionSF,
+		"date_debut_suivi":             kanbanExport.DateDebutSuivi,
+		"date_fin_suivi":               kanbanExport.DateFinSuivi,
+		"description_wekan":            kanbanExport.DescriptionWekan,
+	}
+}
+
+// docxFields valeurs des champs de l'entête de la fiche
+func (head ExportHeader) docxFields() docx.Fields {
+	return docx.Fields{
+		"auteur":          head.Auteur,
+		"date_edition":    head.Date.Format("02/01/2006"),
+		"confidentialite": "Haute",
 	}
-	cmd := exec.Command(python, script, head.Auteur, head.Date.Format("02/01/2006"), "Haute")
-	cmd.Dir = dir
-	cmd.Stdin = bytes.NewReader(data)
-	var out bytes.Buffer
-	var outErr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &outErr
-	err = cmd.Run()
+}
+
+func (kanbanExport KanbanExport) docx(head ExportHeader) (Docx, error) {
+	data, err := docx.Merge(head.docxFields(), []docx.Fields{kanbanExport.docxFields()})
 	if err != nil {
-		fmt.Println(string(outErr.Bytes()))
 		return Docx{}, err
 	}
-	file := out.Bytes()
-	if len(file) == 0 {
-		fmt.Println("no docx file generated")
-	}
-	if len(outErr.Bytes()) > 0 {
-		fmt.Println(outErr.String())
-	}
 
 	var filename string
 	if kanbanExport.Board == "" {
@@ -161,10 +211,19 @@ func (kanbanExport KanbanExport) docx(head ExportHeader) (Docx, error) {
 
 	return Docx{
 		filename: filename,
-		data:     file,
+		data:     data,
 	}, nil
 }
 
+// docx regroupe les fiches dans un seul document, une section par établissement
+func (cards KanbanExports) docx(head ExportHeader, filename string) (Docx, error) {
+	data, err := docx.Merge(head.docxFields(), utils.Convert(cards, KanbanExport.docxFields))
+	if err != nil {
+		return Docx{}, err
+	}
+	return Docx{filename: filename, data: data}, nil
+}
+
 func getEtablissementsFollowedByCurrentUser(c *gin.Context) {
 	username := c.GetString("username")
 	scope := scopeFromContext(c)
@@ -212,7 +271,7 @@ func getXLSXFollowedByCurrentUser(c *gin.Context) {
 		utils.AbortWithError(c, err)
 		return
 	}
-	xlsxFile, err := exports.xlsx(s.hasRole("wekan"))
+	xlsxFile, err := exports.groupByEntreprise().xlsx(s.hasRole("wekan"))
 	if err != nil {
 		utils.AbortWithError(c, err)
 		return
@@ -253,6 +312,45 @@ func (docxs Docxs) zip() []byte {
 	return zipData.Bytes()
 }
 
+// docxOptions options des exports de fiches : `format=pdf` convertit les documents avec `docxPdfConverter`,
+// `merge=true` regroupe les établissements dans un seul document
+type docxOptions struct {
+	pdf   bool
+	merge bool
+}
+
+func docxOptionsFromContext(c *gin.Context) (docxOptions, bool) {
+	format := c.DefaultQuery("format", "docx")
+	if format != "docx" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, "le paramètre `format` doit valoir `docx` ou `pdf`")
+		return docxOptions{}, false
+	}
+	if format == "pdf" && viper.GetString("docxPdfConverter") == "" {
+		c.JSON(http.StatusNotImplemented, "la conversion pdf n'est pas configurée")
+		return docxOptions{}, false
+	}
+	return docxOptions{pdf: format == "pdf", merge: c.Query("merge") == "true"}, true
+}
+
+// convert applique les options au document, le fichier prend l'extension .pdf après conversion
+func (document Docx) convert(ctx context.Context, options docxOptions) (Docx, error) {
+	if !options.pdf {
+		return document, nil
+	}
+	data, err := docx.ToPDF(ctx, viper.GetString("docxPdfConverter"), document.data)
+	if err != nil {
+		return Docx{}, err
+	}
+	return Docx{filename: strings.TrimSuffix(document.filename, ".docx") + ".pdf", data: data}, nil
+}
+
+func (document Docx) contentType() string {
+	if strings.HasSuffix(document.filename, ".pdf") {
+		return "application/pdf"
+	}
+	return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+}
+
 func getDOCXFollowedByCurrentUser(c *gin.Context) {
 	var s Session
 	s.Bind(c)
@@ -261,6 +359,10 @@ func getDOCXFollowedByCurrentUser(c *gin.Context) {
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
 	}
+	options, ok := docxOptionsFromContext(c)
+	if !ok {
+		return
+	}
 
 	if s.hasRole("wekan") {
 		var ok bool
@@ -287,14 +389,37 @@ func getDOCXFollowedByCurrentUser(c *gin.Context) {
 		Date:   time.Now(),
 	}
 
+	if options.merge {
+		if len(exports) == 0 {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		document, err := exports.groupByEntreprise().docx(header, fmt.Sprintf("export-suivi-%s.docx", time.Now().Format("060102")))
+		if err == nil {
+			document, err = document.convert(c, options)
+		}
+		if err != nil {
+			utils.AbortWithError(c, err)
+			return
+		}
+		c.Writer.Header().Set("Content-disposition", "attachment;filename="+document.filename)
+		c.Data(200, document.contentType(), document.data)
+		return
+	}
+
 	var docxs Docxs
-	for _, export := range exports {
-		docx, err := export.docx(header)
+	for _, export := range exports.groupByEntreprise() {
+		document, err := export.docx(header)
+		if err == nil {
+			document, err = document.convert(c, options)
+		}
 		if err != nil {
 			utils.AbortWithError(c, err)
 			return
 		}
-		docxs = append(docxs, docx)
+		// un dossier par entreprise dans l'archive
+		document.filename = export.siren() + "/" + document.filename
+		docxs = append(docxs, document)
 	}
 	filename := fmt.Sprintf("export-suivi-%s.zip", time.Now().Format("060102"))
 	c.Writer.Header().Set("Content-disposition", "attachment;filename="+filename)
@@ -304,6 +429,10 @@ func getDOCXFollowedByCurrentUser(c *gin.Context) {
 func getDOCXFromSiret(c *gin.Context) {
 	var s Session
 	s.Bind(c)
+	options, ok := docxOptionsFromContext(c)
+	if !ok {
+		return
+	}
 
 	siret := c.Param("siret")
 	kanbanExports, err := Kanban.SelectKanbanExportsWithSiret(c, siret, s.Username, db.Get(), s.Roles)
@@ -317,15 +446,18 @@ func getDOCXFromSiret(c *gin.Context) {
 		Date:   time.Now(),
 	}
 
-	if len(kanbanExports) > 1 {
+	if len(kanbanExports) > 1 && !options.merge {
 		var docxs Docxs
 		for _, export := range kanbanExports {
-			docx, err := export.docx(header)
+			document, err := export.docx(header)
+			if err == nil {
+				document, err = document.convert(c, options)
+			}
 			if err != nil {
 				utils.AbortWithError(c, err)
 				return
 			}
-			docxs = append(docxs, docx)
+			docxs = append(docxs, document)
 		}
 		filename := fmt.Sprintf("export-suivi-%s.zip", time.Now().Format("060102"))
 		c.Writer.Header().Set("Content-Disposition", "attachment;filename="+filename)
@@ -333,12 +465,20 @@ func getDOCXFromSiret(c *gin.Context) {
 		return
 	}
 
-	docx, err := kanbanExports[0].docx(header)
+	var document Docx
+	if len(kanbanExports) > 1 {
+		document, err = kanbanExports.docx(header, fmt.Sprintf("ETABLISSEMENT-%s.docx", siret))
+	} else {
+		document, err = kanbanExports[0].docx(header)
+	}
+	if err == nil {
+		document, err = document.convert(c, options)
+	}
 	if err != nil {
 		utils.AbortWithError(c, err)
 		return
 	}
 
-	c.Writer.Header().Set("Content-disposition", "attachment;filename="+docx.filename)
-	c.Data(200, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", docx.data)
+	c.Writer.Header().Set("Content-disposition", "attachment;filename="+document.filename)
+	c.Data(200, document.contentType(), document.data)
 }
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KanbanExports_docx_remplitTousLesChamps(t *testing.T) {
+	ass := assert.New(t)
+	header := ExportHeader{Auteur: "Jean Dupont", Date: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)}
+	exports := KanbanExports{
+		{RaisonSociale: "ENTREPRISE A", Siret: "12345678900011", DescriptionWekan: "accompagnement"},
+		{RaisonSociale: "ENTREPRISE B", Siret: "12345678900022"},
+	}
+
+	document, err := exports.docx(header, "export.docx")
+	require.NoError(t, err)
+	ass.Equal("export.docx", document.filename)
+	ass.Equal("application/vnd.openxmlformats-officedocument.wordprocessingml.document", document.contentType())
+
+	reader, err := zip.NewReader(bytes.NewReader(document.data), int64(len(document.data)))
+	require.NoError(t, err)
+	for _, file := range reader.File {
+		part, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(part)
+		require.NoError(t, err)
+		part.Close()
+		// les champs du modèle sans valeur laisseraient leur libellé «champ»
+		ass.NotContains(string(content), "«", file.Name)
+	}
+}
+
+func Test_KanbanExport_docx_filename(t *testing.T) {
+	ass := assert.New(t)
+	header := ExportHeader{Auteur: "Jean Dupont", Date: time.Now()}
+
+	document, err := KanbanExport{RaisonSociale: "ENTREPRISE A", Siret: "12345678900011", Board: "CRP BFC"}.docx(header)
+	require.NoError(t, err)
+	ass.Equal("CRP-BFC-ENTREPRISE-A-12345678900011.docx", document.filename)
+	ass.NotEmpty(document.data)
+}
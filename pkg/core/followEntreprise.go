@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/signaux-faibles/libwekan"
+
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+)
+
+// FollowEntreprise suivi d'une entreprise au niveau SIREN, établissements futurs compris
+type FollowEntreprise struct {
+	Siren                  string    `json:"siren"`
+	Username               string    `json:"username"`
+	Active                 bool      `json:"active"`
+	Since                  time.Time `json:"since"`
+	Comment                string    `json:"comment"`
+	Category               string    `json:"category"`
+	UnfollowComment        string    `json:"unfollowComment,omitempty"`
+	UnfollowCategory       string    `json:"unfollowCategory,omitempty"`
+	FollowedEtablissements int       `json:"followedEtablissements"`
+}
+
+// FollowedEntreprise établissements suivis regroupés sous leur entreprise
+type FollowedEntreprise struct {
+	Siren           string  `json:"siren"`
+	RaisonSociale   *string `json:"raisonSociale"`
+	WholeEntreprise bool    `json:"wholeEntreprise"`
+	Etablissements  Follows `json:"etablissements"`
+}
+
+func followEntrepriseHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var param struct {
+		Comment  string `json:"comment"`
+		Category string `json:"category"`
+	}
+	if err := c.ShouldBind(&param); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if param.Category == "" {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			gin.H{"message": "la propriété `category` est obligatoire"},
+		)
+		return
+	}
+	follow := FollowEntreprise{
+		Siren:    c.Param("siren"),
+		Username: s.Username,
+		Comment:  param.Comment,
+		Category: param.Category,
+	}
+	exists, visible, err := selectEntrepriseScope(c, follow.Siren, s.Roles, s.Username)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": "entreprise inconnue"})
+		return
+	}
+	if !visible {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "entreprise hors du périmètre de l'utilisateur"})
+		return
+	}
+	created, err := follow.activate(c)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"message": "entreprise inconnue"})
+			return
+		}
+		utils.AbortWithError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusNoContent, follow)
+		return
+	}
+	c.JSON(http.StatusCreated, follow)
+}
+
+func unfollowEntrepriseHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var param struct {
+		UnfollowComment  string `json:"unfollowComment"`
+		UnfollowCategory string `json:"unfollowCategory"`
+	}
+	if err := c.ShouldBind(&param); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if param.UnfollowCategory == "" {
+		c.JSON(http.StatusBadRequest, "mandatory non-empty `unfollowCategory` property")
+		return
+	}
+	follow := FollowEntreprise{
+		Siren:            c.Param("siren"),
+		Username:         s.Username,
+		UnfollowComment:  param.UnfollowComment,
+		UnfollowCategory: param.UnfollowCategory,
+	}
+
+	sirets, err := follow.deactivate(c)
+	if err != nil {
+		c.JSON(err.Code(), err.Error())
+		return
+	}
+
+	if s.hasRole("wekan") {
+		user, ok := Kanban.GetUser(libwekan.Username(s.Username))
+		if !ok {
+			c.JSON(http.StatusInternalServerError, "l'utilisateur a le rôle wekan mais n'est pas présent dans l'application")
+			return
+		}
+		for _, siret := range sirets {
+			cards, err := Kanban.SelectCardsFromSiret(c, siret, libwekan.Username(s.Username))
+			if err != nil {
+				utils.AbortWithError(c, err)
+				return
+			}
+			for _, card := range cards {
+				if err := Kanban.PartCard(c, card.ID, user); err != nil {
+					utils.AbortWithError(c, err)
+					return
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, "this enterprise is no longer followed")
+}
+
+func getEntreprisesFollowedByCurrentUser(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	follow := Follow{Username: &s.Username}
+	follows, jerr := follow.list(s.Roles)
+	if jerr != nil {
+		c.JSON(jerr.Code(), jerr.Error())
+		return
+	}
+	sirens, err := selectFollowedSirens(c, s.Username)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, groupFollowsByEntreprise(follows, sirens))
+}
+
+// activate démarre le suivi de l'entreprise et de tous ses établissements
+// retourne false si l'entreprise était déjà suivie
+// selectEntrepriseScope indique si l'entreprise existe et si un de ses établissements est visible par l'utilisateur
+func selectEntrepriseScope(ctx context.Context, siren string, roles Scope, username string) (exists bool, visible bool, err error) {
+	err = db.Get().QueryRow(ctx,
+		`select exists (select 1 from entreprise0 where siren = $1),
+		exists (select 1 from f_etablissement_permissions($2, $3) p where p.siren = $1 and p.visible)`,
+		siren, roles, username,
+	).Scan(&exists, &visible)
+	return exists, visible, err
+}
+
+func (f *FollowEntreprise) activate(ctx context.Context) (bool, error) {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx,
+		`select since, comment, category from entreprise_follow where siren = $1 and username = $2 and active`,
+		f.Siren, f.Username,
+	).Scan(&f.Since, &f.Comment, &f.Category)
+	if err == nil {
+		f.Active = true
+		return false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	err = tx.QueryRow(ctx,
+		`insert into entreprise_follow (siren, username, active, since, comment, category)
+		select $1, $2, true, current_timestamp, $3, $4
+		from entreprise0 where siren = $1
+		returning since, active`,
+		f.Siren, f.Username, f.Comment, f.Category,
+	).Scan(&f.Since, &f.Active)
+	if err != nil {
+		return false, err
+	}
+	err = tx.QueryRow(ctx, `select follow_entreprise_etablissements($1)`, f.Siren).Scan(&f.FollowedEtablissements)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// deactivate arrête le suivi de l'entreprise et de ses établissements, retourne les sirets dont le suivi a été arrêté
+func (f *FollowEntreprise) deactivate(ctx context.Context) ([]string, utils.Jerror) {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return nil, utils.ErrorToJSON(http.StatusInternalServerError, err)
+	}
+	defer tx.Rollback(ctx)
+
+	entreprise, err := tx.Exec(ctx,
+		`update entreprise_follow set active = false, until = current_timestamp, unfollow_comment = $3, unfollow_category = $4
+		where siren = $1 and username = $2 and active`,
+		f.Siren, f.Username, f.UnfollowComment, f.UnfollowCategory,
+	)
+	if err != nil {
+		return nil, utils.ErrorToJSON(http.StatusInternalServerError, err)
+	}
+
+	rows, err := tx.Query(ctx,
+		`update etablissement_follow set active = false, until = current_timestamp, unfollow_comment = $3, unfollow_category = $4
+		where siren = $1 and username = $2 and active
+		returning siret`,
+		f.Siren, f.Username, f.UnfollowComment, f.UnfollowCategory,
+	)
+	if err != nil {
+		return nil, utils.ErrorToJSON(http.StatusInternalServerError, err)
+	}
+	sirets, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, utils.ErrorToJSON(http.StatusInternalServerError, err)
+	}
+
+	if entreprise.RowsAffected() == 0 && len(sirets) == 0 {
+		return nil, utils.NewJSONerror(http.StatusNoContent, "this enterprise is already not followed")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, utils.ErrorToJSON(http.StatusInternalServerError, err)
+	}
+	return sirets, nil
+}
+
+func selectFollowedSirens(ctx context.Context, username string) (map[string]bool, error) {
+	rows, err := db.Get().Query(ctx,
+		`select siren from entreprise_follow where username = $1 and active`,
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	sirens, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	followed := make(map[string]bool)
+	for _, siren := range sirens {
+		followed[siren] = true
+	}
+	return followed, nil
+}
+
+// groupFollowsByEntreprise regroupe les suivis par siren, le siège en tête de chaque entreprise
+func groupFollowsByEntreprise(follows Follows, followedSirens map[string]bool) []FollowedEntreprise {
+	index := make(map[string]int)
+	var entreprises []FollowedEntreprise
+	for _, follow := range follows {
+		summary := follow.EtablissementSummary
+		if summary == nil {
+			continue
+		}
+		i, ok := index[summary.Siren]
+		if !ok {
+			entreprises = append(entreprises, FollowedEntreprise{
+				Siren:           summary.Siren,
+				RaisonSociale:   summary.RaisonSociale,
+				WholeEntreprise: followedSirens[summary.Siren],
+			})
+			i = len(entreprises) - 1
+			index[summary.Siren] = i
+		}
+		entreprises[i].Etablissements = append(entreprises[i].Etablissements, follow)
+	}
+	for _, entreprise := range entreprises {
+		sort.SliceStable(entreprise.Etablissements, func(i, j int) bool {
+			return isSiege(entreprise.Etablissements[i].EtablissementSummary) && !isSiege(entreprise.Etablissements[j].EtablissementSummary)
+		})
+	}
+	return entreprises
+}
+
+func isSiege(summary *Summary) bool {
+	return summary != nil && summary.Siege != nil && *summary.Siege
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_groupFollowsByEntreprise_siegeEnPremier(t *testing.T) {
+	ass := assert.New(t)
+	siege := true
+	secondaire := false
+	follows := Follows{
+		{EtablissementSummary: &Summary{Siren: "111111111", Siret: "11111111100022", Siege: &secondaire}},
+		{EtablissementSummary: &Summary{Siren: "222222222", Siret: "22222222200011", Siege: &siege}},
+		{EtablissementSummary: &Summary{Siren: "111111111", Siret: "11111111100011", Siege: &siege}},
+	}
+
+	entreprises := groupFollowsByEntreprise(follows, map[string]bool{"111111111": true})
+
+	ass.Len(entreprises, 2)
+	ass.Equal("111111111", entreprises[0].Siren)
+	ass.True(entreprises[0].WholeEntreprise)
+	ass.Equal("11111111100011", entreprises[0].Etablissements[0].EtablissementSummary.Siret)
+	ass.Equal("11111111100022", entreprises[0].Etablissements[1].EtablissementSummary.Siret)
+	ass.False(entreprises[1].WholeEntreprise)
+}
+
+func Test_KanbanExports_groupByEntreprise(t *testing.T) {
+	ass := assert.New(t)
+	exports := KanbanExports{
+		{Siret: "11111111100022", TypeEtablissement: "Établissement secondaire"},
+		{Siret: "22222222200011", TypeEtablissement: "Siège social"},
+		{Siret: "11111111100011", TypeEtablissement: "Siège social"},
+	}
+
+	grouped := exports.groupByEntreprise()
+
+	ass.Equal(
+		[]string{"11111111100011", "11111111100022", "22222222200011"},
+		[]string{grouped[0].Siret, grouped[1].Siret, grouped[2].Siret},
+	)
+	ass.Equal("11111111100022", exports[0].Siret)
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+)
+
+// Groupe ensemble des filiales connues d'un groupe Ellisphere
+type Groupe struct {
+	SirenGroupe   string          `json:"sirenGroupe"`
+	RaisonSociale string          `json:"raisonSociale"`
+	Totals        GroupeTotals    `json:"totals"`
+	Filiales      []GroupeFiliale `json:"filiales"`
+	Detention     DetentionNode   `json:"detention"`
+}
+
+// GroupeTotals agrégats calculés sur les filiales visibles par l'utilisateur
+type GroupeTotals struct {
+	NbFiliales       int     `json:"nbFiliales"`
+	NbEtablissements int     `json:"nbEtablissements"`
+	Effectif         float64 `json:"effectif"`
+	DetteUrssaf      float64 `json:"detteUrssaf"`
+	NbAlertesF1      int     `json:"nbAlertesF1"`
+	NbAlertesF2      int     `json:"nbAlertesF2"`
+	NbSuivis         int     `json:"nbSuivis"`
+}
+
+// GroupeFiliale filiale d'un groupe et ses établissements
+type GroupeFiliale struct {
+	Siren           string     `json:"siren"`
+	RaisonSociale   string     `json:"raisonSociale"`
+	NiveauDetention int        `json:"niveauDetention"`
+	PartFinanciere  float64    `json:"partFinanciere"`
+	RefIDFiliere    string     `json:"-"`
+	Followed        bool       `json:"followed"`
+	Etablissements  []*Summary `json:"etablissements"`
+}
+
+// DetentionNode noeud de l'arbre de détention du groupe
+type DetentionNode struct {
+	Siren           string          `json:"siren"`
+	RaisonSociale   string          `json:"raisonSociale"`
+	NiveauDetention int             `json:"niveauDetention"`
+	PartFinanciere  float64         `json:"partFinanciere"`
+	Filiales        []DetentionNode `json:"filiales,omitempty"`
+}
+
+type groupeFiliales []GroupeFiliale
+
+func (filiales *groupeFiliales) Tuple() []interface{} {
+	*filiales = append(*filiales, GroupeFiliale{})
+	last := &(*filiales)[len(*filiales)-1]
+	return []interface{}{
+		&last.Siren,
+		&last.RaisonSociale,
+		&last.NiveauDetention,
+		&last.PartFinanciere,
+		&last.RefIDFiliere,
+	}
+}
+
+// le siren de la tête de groupe est porté par le champ refid d'Ellisphere
+const sqlGroupeFiliales = `select g.siren, coalesce(en.raison_sociale, ''), coalesce(g.niveau_detention, 0),
+		coalesce(g.part_financiere, 0), coalesce(g.refid_filiere, '')
+	from entreprise_ellisphere0 g
+		left join entreprise0 en on en.siren = g.siren
+	where g.refid = $1
+	order by g.niveau_detention, g.siren`
+
+// la raison sociale du groupe est portée par chaque filiale, comme pour raison_sociale_groupe
+const sqlRaisonSocialeGroupe = `select coalesce(raison_sociale, '')
+	from entreprise_ellisphere0
+	where refid = $1
+	order by niveau_detention, siren
+	limit 1`
+
+var sirenGroupeFormat = regexp.MustCompile("^[0-9]{9}$")
+
+func getGroupeHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	sirenGroupe := c.Param("sirenGroupe")
+	if !sirenGroupeFormat.MatchString(sirenGroupe) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "SIREN valide obligatoire")
+		return
+	}
+	groupe, err := selectGroupe(c, sirenGroupe, s.Roles, s.Username)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if len(groupe.Filiales) == 0 {
+		c.JSON(http.StatusNoContent, "")
+		return
+	}
+	c.JSON(http.StatusOK, groupe)
+}
+
+func selectGroupe(ctx context.Context, sirenGroupe string, roles Scope, username string) (Groupe, error) {
+	var filiales groupeFiliales
+	err := db.Scan(ctx, &filiales, sqlGroupeFiliales, sirenGroupe)
+	if err != nil {
+		return Groupe{}, err
+	}
+	raisonSociale, err := selectRaisonSocialeGroupe(ctx, sirenGroupe)
+	if err != nil {
+		return Groupe{}, err
+	}
+
+	sirens := append(utils.Convert(filiales, func(f GroupeFiliale) string { return f.Siren }), sirenGroupe)
+	summaries, err := getSummariesFromSirens(ctx, roles, username, sirens)
+	if err != nil {
+		return Groupe{}, err
+	}
+	return buildGroupe(sirenGroupe, raisonSociale, filiales, summaries.Summaries), nil
+}
+
+// selectRaisonSocialeGroupe retourne la raison sociale Ellisphere du groupe, vide si le groupe est inconnu
+func selectRaisonSocialeGroupe(ctx context.Context, sirenGroupe string) (string, error) {
+	var raisonSociale string
+	err := db.Get().QueryRow(ctx, sqlRaisonSocialeGroupe, sirenGroupe).Scan(&raisonSociale)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return raisonSociale, err
+}
+
+// buildGroupe rattache les établissements visibles à leur filiale, écarte les filiales sans établissement visible
+// et calcule les totaux du groupe
+func buildGroupe(sirenGroupe string, raisonSociale string, filiales []GroupeFiliale, summaries []*Summary) Groupe {
+	bySiren := make(map[string][]*Summary)
+	for _, summary := range summaries {
+		if summary.Visible != nil && *summary.Visible {
+			bySiren[summary.Siren] = append(bySiren[summary.Siren], summary)
+		}
+	}
+
+	// la tête de groupe n'apparaît pas forcément parmi ses propres filiales
+	if !utils.Any(filiales, func(f GroupeFiliale) bool { return f.Siren == sirenGroupe }) {
+		filiales = append([]GroupeFiliale{{Siren: sirenGroupe, RaisonSociale: raisonSociale}}, filiales...)
+	}
+
+	groupe := Groupe{SirenGroupe: sirenGroupe, RaisonSociale: raisonSociale, Filiales: []GroupeFiliale{}}
+	for _, filiale := range filiales {
+		etablissements, ok := bySiren[filiale.Siren]
+		if !ok {
+			continue
+		}
+		filiale.Etablissements = etablissements
+		groupe.Totals.add(&filiale)
+		groupe.Filiales = append(groupe.Filiales, filiale)
+	}
+	groupe.Detention = buildDetentionTree(sirenGroupe, raisonSociale, groupe.Filiales)
+	return groupe
+}
+
+func (totals *GroupeTotals) add(filiale *GroupeFiliale) {
+	totals.NbFiliales++
+	var effectifEntreprise *float64
+	for _, etablissement := range filiale.Etablissements {
+		totals.NbEtablissements++
+		if etablissement.DetteUrssaf != nil {
+			totals.DetteUrssaf += *etablissement.DetteUrssaf
+		}
+		if etablissement.Alert != nil && *etablissement.Alert == "Alerte seuil F1" {
+			totals.NbAlertesF1++
+		}
+		if etablissement.Alert != nil && *etablissement.Alert == "Alerte seuil F2" {
+			totals.NbAlertesF2++
+		}
+		if etablissement.Followed != nil && *etablissement.Followed {
+			totals.NbSuivis++
+			filiale.Followed = true
+		}
+		effectifEntreprise = utils.Coalesce(effectifEntreprise, etablissement.EffectifEntreprise)
+	}
+	if effectifEntreprise != nil {
+		totals.Effectif += *effectifEntreprise
+	}
+}
+
+// buildDetentionTree construit l'arbre de détention : une filiale est rattachée à sa filière
+// lorsque celle-ci est connue dans le groupe, sinon directement à la tête de groupe
+func buildDetentionTree(sirenGroupe string, raisonSociale string, filiales []GroupeFiliale) DetentionNode {
+	known := make(map[string]GroupeFiliale)
+	for _, filiale := range filiales {
+		known[filiale.Siren] = filiale
+	}
+	children := make(map[string][]GroupeFiliale)
+	for _, filiale := range filiales {
+		if filiale.Siren == sirenGroupe {
+			continue
+		}
+		parent := sirenGroupe
+		if holder, ok := known[filiale.RefIDFiliere]; ok && holder.Siren != filiale.Siren && holder.NiveauDetention < filiale.NiveauDetention {
+			parent = holder.Siren
+		}
+		children[parent] = append(children[parent], filiale)
+	}
+
+	var build func(node DetentionNode) DetentionNode
+	build = func(node DetentionNode) DetentionNode {
+		nodeChildren := children[node.Siren]
+		sort.Slice(nodeChildren, func(i, j int) bool { return nodeChildren[i].Siren < nodeChildren[j].Siren })
+		for _, child := range nodeChildren {
+			node.Filiales = append(node.Filiales, build(DetentionNode{
+				Siren:           child.Siren,
+				RaisonSociale:   child.RaisonSociale,
+				NiveauDetention: child.NiveauDetention,
+				PartFinanciere:  child.PartFinanciere,
+			}))
+		}
+		return node
+	}
+	return build(DetentionNode{Siren: sirenGroupe, RaisonSociale: raisonSociale})
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_buildDetentionTree_rattacheLesFilialesASaFiliere(t *testing.T) {
+	ass := assert.New(t)
+	filiales := []GroupeFiliale{
+		{Siren: "222222222", NiveauDetention: 1},
+		{Siren: "333333333", NiveauDetention: 2, RefIDFiliere: "222222222"},
+		{Siren: "444444444", NiveauDetention: 2, RefIDFiliere: "inconnu"},
+	}
+
+	tree := buildDetentionTree("111111111", "Tête", filiales)
+
+	ass.Equal("111111111", tree.Siren)
+	ass.Len(tree.Filiales, 2)
+	ass.Equal("222222222", tree.Filiales[0].Siren)
+	ass.Equal("333333333", tree.Filiales[0].Filiales[0].Siren)
+	ass.Equal("444444444", tree.Filiales[1].Siren)
+}
+
+func Test_buildGroupe_ignoreLesEtablissementsNonVisibles(t *testing.T) {
+	ass := assert.New(t)
+	visible := true
+	invisible := false
+	dette := 1000.0
+	effectif := 50.0
+	f1 := "Alerte seuil F1"
+	filiales := []GroupeFiliale{
+		{Siren: "222222222", NiveauDetention: 1},
+		{Siren: "333333333", NiveauDetention: 1},
+	}
+	summaries := []*Summary{
+		{Siren: "222222222", Siret: "22222222200011", Visible: &visible, DetteUrssaf: &dette, EffectifEntreprise: &effectif, Alert: &f1},
+		{Siren: "222222222", Siret: "22222222200022", Visible: &visible, EffectifEntreprise: &effectif},
+		{Siren: "333333333", Siret: "33333333300011", Visible: &invisible},
+	}
+
+	groupe := buildGroupe("111111111", "Tête", filiales, summaries)
+
+	ass.Len(groupe.Filiales, 1)
+	ass.Equal(GroupeTotals{NbFiliales: 1, NbEtablissements: 2, Effectif: 50, DetteUrssaf: 1000, NbAlertesF1: 1}, groupe.Totals)
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datapi/pkg/utils"
+)
+
+const defaultHistoryLength = 20
+const maxHistoryLength = 100
+
+// HistoryEntry fiche entreprise ou établissement consultée par l'utilisateur
+type HistoryEntry struct {
+	Type    string    `json:"type"`
+	Siren   string    `json:"siren"`
+	Siret   string    `json:"siret,omitempty"`
+	Date    time.Time `json:"date"`
+	Summary *Summary  `json:"summary,omitempty"`
+}
+
+func (datapi *Datapi) getHistoryHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	if datapi.accessLogs == nil {
+		c.JSON(http.StatusServiceUnavailable, "l'historique n'est pas disponible")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("n", strconv.Itoa(defaultHistoryLength)))
+	if err != nil || limit <= 0 || limit > maxHistoryLength {
+		c.JSON(http.StatusBadRequest, "le paramètre `n` doit être un entier entre 1 et "+strconv.Itoa(maxHistoryLength))
+		return
+	}
+
+	consultations, err := datapi.accessLogs.SelectHistory(c, s.Username, limit)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	sirets, sirens := consultationIDs(consultations)
+	summaries, err := getSummariesFromSirets(c, s.Roles, s.Username, sirets, sirens)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, buildHistory(consultations, summaries.Summaries))
+}
+
+func (datapi *Datapi) deleteHistoryHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	if datapi.accessLogs == nil {
+		c.JSON(http.StatusServiceUnavailable, "l'historique n'est pas disponible")
+		return
+	}
+	err := datapi.accessLogs.ClearHistory(c, s.Username)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func consultationIDs(consultations []Consultation) (sirets []string, sirens []string) {
+	for _, consultation := range consultations {
+		if consultation.Type == "entreprise" {
+			sirens = append(sirens, consultation.ID)
+		} else {
+			sirets = append(sirets, consultation.ID)
+		}
+	}
+	return sirets, sirens
+}
+
+// buildHistory associe chaque consultation au summary de l'établissement ou du siège de l'entreprise
+func buildHistory(consultations []Consultation, summaries []*Summary) []HistoryEntry {
+	bySiret := make(map[string]*Summary)
+	bySiren := make(map[string]*Summary)
+	for _, summary := range summaries {
+		bySiret[summary.Siret] = summary
+		if summary.Siege != nil && *summary.Siege {
+			bySiren[summary.Siren] = summary
+		}
+	}
+
+	history := []HistoryEntry{}
+	for _, consultation := range consultations {
+		entry := HistoryEntry{Type: consultation.Type, Date: consultation.Date}
+		if consultation.Type == "entreprise" {
+			entry.Siren = consultation.ID
+			entry.Summary = bySiren[consultation.ID]
+		} else {
+			entry.Siret = consultation.ID
+			entry.Siren = consultation.ID[0:min(9, len(consultation.ID))]
+			entry.Summary = bySiret[consultation.ID]
+		}
+		history = append(history, entry)
+	}
+	return history
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_buildHistory_associeLesSummaries(t *testing.T) {
+	ass := assert.New(t)
+	now := time.Now()
+	siege := true
+	summaries := []*Summary{
+		{Siren: "123456789", Siret: "12345678900011", Siege: &siege},
+		{Siren: "987654321", Siret: "98765432100022"},
+	}
+	consultations := []Consultation{
+		{Type: "entreprise", ID: "123456789", Date: now},
+		{Type: "etablissement", ID: "98765432100022", Date: now.Add(-time.Hour)},
+		{Type: "etablissement", ID: "11111111100011", Date: now.Add(-2 * time.Hour)},
+	}
+
+	history := buildHistory(consultations, summaries)
+
+	ass.Len(history, 3)
+	ass.Equal(summaries[0], history[0].Summary)
+	ass.Equal("123456789", history[0].Siren)
+	ass.Equal(summaries[1], history[1].Summary)
+	ass.Equal("987654321", history[1].Siren)
+	ass.Nil(history[2].Summary)
+}
+
+func Test_consultationIDs(t *testing.T) {
+	ass := assert.New(t)
+	consultations := []Consultation{
+		{Type: "entreprise", ID: "123456789"},
+		{Type: "etablissement", ID: "98765432100022"},
+	}
+
+	sirets, sirens := consultationIDs(consultations)
+
+	ass.Equal([]string{"98765432100022"}, sirets)
+	ass.Equal([]string{"123456789"}, sirens)
+}
@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"errors"
+	"regexp"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -32,6 +34,15 @@ type KanbanService interface {
 	GetCardMembersHistory(ctx context.Context, cardID libwekan.CardID, username string) ([]KanbanActivity, error)
 	SelectCardsFromListeAndDomainRegexp(ctx context.Context, wekanDomainRegexp string, liste string) ([]libwekan.Card, error)
 	GetWekanConfig() libwekan.Config
+	UpdateCardLabels(ctx context.Context, cardID libwekan.CardID, params KanbanCardLabelsParams, username libwekan.Username) error
+	UpdateCardUsers(ctx context.Context, cardID libwekan.CardID, params KanbanCardUsersParams, username libwekan.Username) error
+	SetCardStartAt(ctx context.Context, cardID libwekan.CardID, startAt *time.Time, username libwekan.Username) error
+	SetCardEndAt(ctx context.Context, cardID libwekan.CardID, endAt *time.Time, username libwekan.Username) error
+	AddCardComment(ctx context.Context, cardID libwekan.CardID, text string, username libwekan.Username) (KanbanComment, error)
+	UpdateCardComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, text string, username libwekan.Username) error
+	DeleteCardComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, username libwekan.Username) error
+	GetCardTimeline(ctx context.Context, card KanbanCard) ([]KanbanTimelineEvent, error)
+	SelectKanbanStats(ctx context.Context, username libwekan.Username, staleDays int) (KanbanStats, error)
 }
 
 type KanbanUsers map[libwekan.UserID]KanbanUser
@@ -88,6 +99,18 @@ type KanbanConfig struct {
 	UserID       libwekan.UserID                           `json:"userID"`
 }
 
+// SelectSwimlane retourne le couloir du département sur le premier tableau dont le slug correspond à wekanDomainRegexp
+func (config KanbanConfig) SelectSwimlane(wekanDomainRegexp *regexp.Regexp, codeDepartement CodeDepartement) (KanbanBoardSwimlane, error) {
+	swimlanes, _ := config.Departements[codeDepartement]
+	for _, swimlane := range swimlanes {
+		board := config.Boards[swimlane.BoardID]
+		if wekanDomainRegexp.MatchString(string(board.Slug)) {
+			return swimlane, nil
+		}
+	}
+	return KanbanBoardSwimlane{}, errors.New("aucun couloir disponible pour cette zone")
+}
+
 type KanbanCard struct {
 	ID                libwekan.CardID         `json:"id,omitempty"`
 	ListID            libwekan.ListID         `json:"listID,omitempty"`
@@ -144,6 +167,20 @@ type KanbanNewCardParams struct {
 	Siret       Siret                     `json:"siret"`
 }
 
+// KanbanCardLabelsParams liste les étiquettes à ajouter et à retirer d'une carte, désignées par leur nom
+type KanbanCardLabelsParams struct {
+	Add    []libwekan.BoardLabelName `json:"add"`
+	Remove []libwekan.BoardLabelName `json:"remove"`
+}
+
+// KanbanCardUsersParams liste les utilisateurs à ajouter et à retirer des membres et des assignés d'une carte
+type KanbanCardUsersParams struct {
+	AddMembers      []libwekan.Username `json:"addMembers"`
+	RemoveMembers   []libwekan.Username `json:"removeMembers"`
+	AddAssignees    []libwekan.Username `json:"addAssignees"`
+	RemoveAssignees []libwekan.Username `json:"removeAssignees"`
+}
+
 // ExportHeader contient l'entête d'un document d'export
 type ExportHeader struct {
 	Auteur string
@@ -193,6 +230,58 @@ type KanbanActivity struct {
 	To       *time.Time      `json:"to,omitempty"`
 }
 
+// KanbanTimelineEvent évènement de l'historique d'accompagnement d'une carte,
+// Source vaut `kanban` pour les activités de la carte et `datapi` pour les évènements liés au siret
+type KanbanTimelineEvent struct {
+	Date     time.Time `json:"date"`
+	Source   string    `json:"source"`
+	Type     string    `json:"type"`
+	Username string    `json:"username,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// KanbanStats indicateurs des tableaux visibles par l'utilisateur, les cartes archivées ne sont pas comptées
+type KanbanStats struct {
+	StaleDays  int                   `json:"staleDays"`
+	Lists      []KanbanListStats     `json:"lists"`
+	Swimlanes  []KanbanSwimlaneStats `json:"swimlanes"`
+	Labels     []KanbanLabelStats    `json:"labels"`
+	StaleCards []KanbanStaleCard     `json:"staleCards"`
+}
+
+// KanbanListStats nombre de cartes de la liste et durée moyenne des passages terminés dans la liste
+type KanbanListStats struct {
+	Board       libwekan.BoardTitle `json:"board" col:"tableau" size:"30"`
+	List        string              `json:"list" col:"liste" size:"30"`
+	Cards       int                 `json:"cards" col:"cartes" size:"10"`
+	Passages    int                 `json:"passages" col:"passages terminés" size:"18"`
+	AverageDays float64             `json:"averageDays" col:"durée moyenne (jours)" size:"22"`
+}
+
+type KanbanSwimlaneStats struct {
+	Board    libwekan.BoardTitle `json:"board" col:"tableau" size:"30"`
+	Swimlane string              `json:"swimlane" col:"département" size:"30"`
+	Cards    int                 `json:"cards" col:"cartes" size:"10"`
+}
+
+type KanbanLabelStats struct {
+	Board libwekan.BoardTitle     `json:"board" col:"tableau" size:"30"`
+	Label libwekan.BoardLabelName `json:"label" col:"étiquette" size:"30"`
+	Cards int                     `json:"cards" col:"cartes" size:"10"`
+}
+
+// KanbanStaleCard carte sans activité depuis au moins KanbanStats.StaleDays jours
+type KanbanStaleCard struct {
+	Board        libwekan.BoardTitle `json:"board" col:"tableau" size:"30"`
+	Swimlane     string              `json:"swimlane" col:"département" size:"30"`
+	List         string              `json:"list" col:"liste" size:"30"`
+	CardID       libwekan.CardID     `json:"cardID" col:"carte" size:"20"`
+	Title        string              `json:"title" col:"titre" size:"40"`
+	Siret        Siret               `json:"siret" col:"siret" size:"16"`
+	LastActivity time.Time           `json:"lastActivity" col:"dernière activité" size:"18" dateFormat:"yyyy-mm-dd"`
+	InactiveDays int                 `json:"inactiveDays" col:"jours sans activité" size:"18"`
+}
+
 func (k KanbanDBExport) GetSiret() string {
 	return k.Siret
 }
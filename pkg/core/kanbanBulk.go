@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/spf13/viper"
+)
+
+// maxBulkCards nombre maximum d'établissements traités par une création de cartes en masse
+const maxBulkCards = 500
+
+const sqlSelectSiretsDepartement = `select siret, code_departement from v_summaries where siret = any($1)`
+
+// KanbanBulkCardsParams établissements pour lesquels créer une carte, désignés par leurs sirets
+// ou par un filtre sur une liste de détection (la dernière liste si Liste est vide)
+type KanbanBulkCardsParams struct {
+	Sirets      []Siret                   `json:"sirets"`
+	Liste       string                    `json:"liste"`
+	Filter      *paramsListeScores        `json:"filter"`
+	ListTitle   string                    `json:"listTitle"`
+	Labels      []libwekan.BoardLabelName `json:"labels"`
+	Description string                    `json:"description"`
+}
+
+// KanbanBulkCardReport résultat de la création de carte pour un établissement
+type KanbanBulkCardReport struct {
+	Siret   Siret            `json:"siret"`
+	Status  string           `json:"status"`
+	BoardID libwekan.BoardID `json:"boardID,omitempty"`
+	CardID  libwekan.CardID  `json:"cardID,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+const (
+	bulkCardCreated = "created"
+	bulkCardExists  = "exists"
+	bulkCardError   = "error"
+)
+
+// bulkCard carte à créer ou établissement écarté lorsque report.Status est renseigné
+type bulkCard struct {
+	report KanbanBulkCardReport
+	params KanbanNewCardParams
+}
+
+func kanbanBulkCardsHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var params KanbanBulkCardsParams
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if (len(params.Sirets) == 0) == (params.Filter == nil) {
+		c.JSON(http.StatusBadRequest, "préciser soit `sirets`, soit `filter`")
+		return
+	}
+	wekanDomainRegexp, err := regexp.CompilePOSIX(viper.GetString("wekanSlugDomainRegexp"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	departements, err := selectBulkSirets(c, params, s)
+	var jerr utils.Jerror
+	if errors.As(err, &jerr) {
+		c.JSON(jerr.Code(), jerr.Error())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(departements) > maxBulkCards {
+		c.JSON(http.StatusBadRequest, fmt.Sprintf("%d établissements sélectionnés, la limite est de %d", len(departements), maxBulkCards))
+		return
+	}
+
+	username := libwekan.Username(s.Username)
+	config := Kanban.LoadConfigForUser(username)
+	existingCards, err := Kanban.SelectCardsFromSiretsAndBoardIDs(c, utils.GetKeys(departements), utils.GetKeys(config.Boards), username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reports := []KanbanBulkCardReport{}
+	for _, card := range planBulkCards(params, departements, config, wekanDomainRegexp, existingCards) {
+		if card.report.Status == "" {
+			created, err := Kanban.CreateCard(c, card.params, username, nil, db.Get())
+			if err != nil {
+				card.report.Status, card.report.Error = bulkCardError, err.Error()
+			} else {
+				card.report.Status, card.report.CardID = bulkCardCreated, created.ID
+			}
+		}
+		reports = append(reports, card.report)
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// selectBulkSirets retourne le département de chaque établissement demandé,
+// les sirets inconnus sont associés à un département vide
+func selectBulkSirets(ctx context.Context, params KanbanBulkCardsParams, s Session) (map[Siret]CodeDepartement, error) {
+	departements := make(map[Siret]CodeDepartement)
+	if params.Filter != nil {
+		liste, err := bulkListe(params)
+		if err != nil {
+			return nil, err
+		}
+		if jerr := liste.getScores(s.Roles, 0, nil, s.Username); jerr != nil && jerr.Code() != http.StatusNoContent {
+			return nil, jerr
+		}
+		for _, summary := range liste.Scores {
+			if summary.CodeDepartement != nil {
+				departements[Siret(summary.Siret)] = CodeDepartement(*summary.CodeDepartement)
+			}
+		}
+		return departements, nil
+	}
+	for _, siret := range params.Sirets {
+		departements[siret] = ""
+	}
+	rows, err := db.Get().Query(ctx, sqlSelectSiretsDepartement, params.Sirets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var siret Siret
+		var departement *CodeDepartement
+		if err := rows.Scan(&siret, &departement); err != nil {
+			return nil, err
+		}
+		if departement != nil {
+			departements[siret] = *departement
+		}
+	}
+	return departements, rows.Err()
+}
+
+func bulkListe(params KanbanBulkCardsParams) (Liste, error) {
+	listes, err := findAllListes()
+	if err != nil {
+		return Liste{}, err
+	}
+	liste := Liste{ID: params.Liste, Query: *params.Filter}
+	if liste.ID == "" {
+		liste.ID = listes[0].ID
+	}
+	liste.CurrentList = listes[0].ID == liste.ID
+	return liste, nil
+}
+
+// planBulkCards prépare une carte par établissement, dans le couloir de son département,
+// les établissements qui ont déjà une carte sur ce tableau sont écartés
+func planBulkCards(
+	params KanbanBulkCardsParams,
+	departements map[Siret]CodeDepartement,
+	config KanbanConfig,
+	wekanDomainRegexp *regexp.Regexp,
+	existingCards []KanbanCard,
+) []bulkCard {
+	sirets := utils.GetKeys(departements)
+	slices.Sort(sirets)
+	var cards []bulkCard
+	for _, siret := range sirets {
+		card := bulkCard{report: KanbanBulkCardReport{Siret: siret}}
+		swimlane, err := bulkSwimlane(siret, departements[siret], config, wekanDomainRegexp)
+		if err != nil {
+			card.report.Status, card.report.Error = bulkCardError, err.Error()
+			cards = append(cards, card)
+			continue
+		}
+		card.report.BoardID = swimlane.BoardID
+		if i := slices.IndexFunc(existingCards, func(existing KanbanCard) bool {
+			return existing.Siret == siret && existing.BoardID == swimlane.BoardID
+		}); i >= 0 {
+			card.report.Status, card.report.CardID = bulkCardExists, existingCards[i].ID
+			cards = append(cards, card)
+			continue
+		}
+		listID, err := bulkListID(config.Boards[swimlane.BoardID], params.ListTitle)
+		if err != nil {
+			card.report.Status, card.report.Error = bulkCardError, err.Error()
+			cards = append(cards, card)
+			continue
+		}
+		card.params = KanbanNewCardParams{
+			SwimlaneID:  swimlane.SwimlaneID,
+			ListID:      listID,
+			Description: params.Description,
+			Labels:      params.Labels,
+			Siret:       siret,
+		}
+		cards = append(cards, card)
+	}
+	return cards
+}
+
+func bulkSwimlane(siret Siret, departement CodeDepartement, config KanbanConfig, wekanDomainRegexp *regexp.Regexp) (KanbanBoardSwimlane, error) {
+	if !siret.IsValid() {
+		return KanbanBoardSwimlane{}, errors.New("le siret n'est pas de la bonne forme")
+	}
+	if departement == "" {
+		return KanbanBoardSwimlane{}, errors.New("le siret fourni n'existe pas")
+	}
+	return config.SelectSwimlane(wekanDomainRegexp, departement)
+}
+
+// bulkListID retrouve la liste du tableau d'après son titre, la liste par défaut de la création de carte si le titre est vide
+func bulkListID(board KanbanBoard, listTitle string) (libwekan.ListID, error) {
+	if listTitle == "" {
+		return "", nil
+	}
+	for listID, list := range board.Lists {
+		if list.Title == listTitle {
+			return listID, nil
+		}
+	}
+	return "", fmt.Errorf("la liste %s n'existe pas sur le tableau %s", listTitle, board.Title)
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func oneBulkKanbanConfig() KanbanConfig {
+	return KanbanConfig{
+		Departements: map[CodeDepartement][]KanbanBoardSwimlane{
+			"21": {{BoardID: "autre", SwimlaneID: "autre21"}, {BoardID: "bfc", SwimlaneID: "cotedor"}},
+			"25": {{BoardID: "bfc", SwimlaneID: "doubs"}},
+		},
+		Boards: KanbanBoards{
+			"bfc": {
+				Title: "CRP BFC",
+				Slug:  "tableau-crp-bfc",
+				Lists: KanbanLists{"l1": {Title: "A définir"}, "l2": {Title: "Analyse en cours"}},
+			},
+			"autre": {Title: "Autre", Slug: "tableau-autre"},
+		},
+	}
+}
+
+func Test_planBulkCards(t *testing.T) {
+	ass := assert.New(t)
+	departements := map[Siret]CodeDepartement{
+		"12345678900011": "21",
+		"12345678900022": "25",
+		"12345678900033": "",
+		"1234":           "21",
+		"12345678900044": "974",
+	}
+	existingCards := []KanbanCard{{ID: "carte", BoardID: "bfc", Siret: "12345678900022"}}
+	params := KanbanBulkCardsParams{ListTitle: "Analyse en cours", Labels: []libwekan.BoardLabelName{"CRP"}}
+
+	cards := planBulkCards(params, departements, oneBulkKanbanConfig(), regexp.MustCompile("^tableau-crp.*"), existingCards)
+	require.Len(t, cards, 5)
+	ass.Equal(Siret("1234"), cards[0].report.Siret)
+	ass.Equal(bulkCardError, cards[0].report.Status)
+
+	ass.Empty(cards[1].report.Status)
+	ass.Equal(libwekan.BoardID("bfc"), cards[1].report.BoardID)
+	ass.Equal(libwekan.SwimlaneID("cotedor"), cards[1].params.SwimlaneID)
+	ass.Equal(libwekan.ListID("l2"), cards[1].params.ListID)
+	ass.Equal([]libwekan.BoardLabelName{"CRP"}, cards[1].params.Labels)
+
+	ass.Equal(bulkCardExists, cards[2].report.Status)
+	ass.Equal(libwekan.CardID("carte"), cards[2].report.CardID)
+
+	ass.Equal(bulkCardError, cards[3].report.Status)
+	ass.Equal("le siret fourni n'existe pas", cards[3].report.Error)
+
+	ass.Equal(bulkCardError, cards[4].report.Status)
+	ass.Equal("aucun couloir disponible pour cette zone", cards[4].report.Error)
+}
+
+func Test_planBulkCards_unknownList(t *testing.T) {
+	ass := assert.New(t)
+	departements := map[Siret]CodeDepartement{"12345678900011": "21"}
+	params := KanbanBulkCardsParams{ListTitle: "Inconnue"}
+
+	cards := planBulkCards(params, departements, oneBulkKanbanConfig(), regexp.MustCompile("^tableau-crp.*"), nil)
+	require.Len(t, cards, 1)
+	ass.Equal(bulkCardError, cards[0].report.Status)
+	ass.Equal("la liste Inconnue n'existe pas sur le tableau CRP BFC", cards[0].report.Error)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/signaux-faibles/libwekan"
 	"net/http"
+	"time"
 )
 
 func kanbanConfigHandler(c *gin.Context) {
@@ -229,3 +230,200 @@ func kanbanGetCardMembersHistoryHandler(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, members)
 }
+
+// kanbanErrorStatus retourne le code http correspondant à une erreur du service kanban
+func kanbanErrorStatus(err error) int {
+	switch {
+	case errors.As(err, &ForbiddenError{}):
+		return http.StatusForbidden
+	case errors.As(err, &UnknownCardError{}), errors.As(err, &UnknownCommentError{}):
+		return http.StatusNotFound
+	case errors.As(err, &UnknownBoardError{}), errors.As(err, &UnknownListError{}),
+		errors.As(err, &UnknownLabelError{}), errors.As(err, &libwekan.ListNotFoundError{}):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func kanbanUpdateCardLabelsHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var params KanbanCardLabelsParams
+	if err := c.Bind(&params); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	err := Kanban.UpdateCardLabels(c, cardID, params, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+func kanbanUpdateCardUsersHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var params KanbanCardUsersParams
+	if err := c.Bind(&params); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	err := Kanban.UpdateCardUsers(c, cardID, params, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+// bindCardDate lit la date du corps de la requête, `{"date": null}` efface la date de la carte
+func bindCardDate(c *gin.Context) (*time.Time, bool) {
+	var params struct {
+		Date *time.Time `json:"date"`
+	}
+	if err := c.Bind(&params); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return params.Date, true
+}
+
+func kanbanSetCardStartAtHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	date, ok := bindCardDate(c)
+	if !ok {
+		return
+	}
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	err := Kanban.SetCardStartAt(c, cardID, date, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+func kanbanSetCardEndAtHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	date, ok := bindCardDate(c)
+	if !ok {
+		return
+	}
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	err := Kanban.SetCardEndAt(c, cardID, date, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+// kanbanMoveCardHandler déplace la carte dans la liste désignée par son identifiant ou, à défaut, par son titre
+func kanbanMoveCardHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var params struct {
+		ListID    libwekan.ListID `json:"listID"`
+		ListTitle string          `json:"listTitle"`
+	}
+	if err := c.Bind(&params); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.ListID == "" && params.ListTitle == "" {
+		c.JSON(http.StatusBadRequest, "listID ou listTitle est obligatoire")
+		return
+	}
+	user, ok := Kanban.GetUser(libwekan.Username(s.Username))
+	if !ok {
+		c.JSON(http.StatusForbidden, "le nom d'utilisateur n'est pas reconnu")
+		return
+	}
+
+	// la sélection de la carte vérifie que l'utilisateur est membre du tableau
+	cardID := libwekan.CardID(c.Param("cardID"))
+	card, err := Kanban.SelectCardFromCardID(c, cardID, user.Username)
+	if err == nil && params.ListID != "" {
+		err = Kanban.MoveCardList(c, cardID, params.ListID, user)
+	} else if err == nil {
+		err = Kanban.MoveCardListWithTitle(c, libwekan.Card{ID: card.ID, BoardID: card.BoardID}, params.ListTitle, user)
+	}
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+func bindCommentText(c *gin.Context) (string, bool) {
+	var params struct {
+		Text string `json:"text"`
+	}
+	if err := c.Bind(&params); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	if params.Text == "" {
+		c.JSON(http.StatusBadRequest, "le commentaire est vide")
+		return "", false
+	}
+	return params.Text, true
+}
+
+func kanbanAddCardCommentHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	text, ok := bindCommentText(c)
+	if !ok {
+		return
+	}
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	comment, err := Kanban.AddCardComment(c, cardID, text, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, comment)
+}
+
+func kanbanUpdateCardCommentHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	text, ok := bindCommentText(c)
+	if !ok {
+		return
+	}
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	commentID := libwekan.CommentID(c.Param("commentID"))
+	err := Kanban.UpdateCardComment(c, cardID, commentID, text, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+func kanbanDeleteCardCommentHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+
+	cardID := libwekan.CardID(c.Param("cardID"))
+	commentID := libwekan.CommentID(c.Param("commentID"))
+	err := Kanban.DeleteCardComment(c, cardID, commentID, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
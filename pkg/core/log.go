@@ -2,9 +2,11 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -27,6 +29,20 @@ func (infos AccessLog) String() string {
 // AccessLogSaver handler pour définir une façon de sauver les logs
 type AccessLogSaver func(log AccessLog) error
 
+// Consultation représente l'ouverture d'une fiche entreprise ou établissement
+type Consultation struct {
+	Type string // `entreprise` ou `etablissement`
+	ID   string // siren ou siret
+	Date time.Time
+}
+
+// AccessLogReader définit une façon de relire les logs
+type AccessLogReader interface {
+	SelectLastConsultations(ctx context.Context, paths []string) (map[string]time.Time, error)
+	SelectHistory(ctx context.Context, username string, limit int) ([]Consultation, error)
+	ClearHistory(ctx context.Context, username string) error
+}
+
 // LogMiddleware définit le middleware qui gère les logs
 func (datapi *Datapi) LogMiddleware(c *gin.Context) {
 	if c.Request.Body == nil {
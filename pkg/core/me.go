@@ -0,0 +1,89 @@
+package core
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+
+	"datapi/pkg/utils"
+)
+
+// Profile identité, habilitations et préférences de l'utilisateur connecté
+type Profile struct {
+	Username     string            `json:"username"`
+	FirstName    string            `json:"firstName"`
+	LastName     string            `json:"lastName"`
+	Email        string            `json:"email,omitempty"`
+	Roles        Scope             `json:"roles"`
+	Departements []CodeDepartement `json:"departements"`
+	Kanban       ProfileKanban     `json:"kanban"`
+	Preferences  Preferences       `json:"preferences"`
+}
+
+// ProfileKanban appartenance de l'utilisateur aux tableaux kanban
+type ProfileKanban struct {
+	Member bool           `json:"member"`
+	UserID string         `json:"userID,omitempty"`
+	Boards []ProfileBoard `json:"boards"`
+}
+
+type ProfileBoard struct {
+	ID    libwekan.BoardID    `json:"id"`
+	Title libwekan.BoardTitle `json:"title"`
+	Slug  libwekan.BoardSlug  `json:"slug"`
+}
+
+func getProfileHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	preferences, err := SelectPreferences(c, s.Username)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	profile := Profile{
+		Username:     s.Username,
+		FirstName:    c.GetString("given_name"),
+		LastName:     c.GetString("family_name"),
+		Email:        c.GetString("email"),
+		Roles:        s.Roles,
+		Departements: effectiveDepartements(s.Roles, Departements, Regions),
+		Kanban:       profileKanban(libwekan.Username(s.Username)),
+		Preferences:  preferences,
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+func profileKanban(username libwekan.Username) ProfileKanban {
+	profile := ProfileKanban{Boards: []ProfileBoard{}}
+	user, ok := Kanban.GetUser(username)
+	if !ok {
+		return profile
+	}
+	profile.Member = true
+	profile.UserID = string(user.ID)
+	for _, board := range Kanban.SelectBoardsForUsername(username) {
+		profile.Boards = append(profile.Boards, ProfileBoard{
+			ID:    board.Board.ID,
+			Title: board.Board.Title,
+			Slug:  board.Board.Slug,
+		})
+	}
+	return profile
+}
+
+// effectiveDepartements résout les rôles départements et régions de l'utilisateur en codes départements
+func effectiveDepartements(roles Scope, departements map[CodeDepartement]string, regions map[Region][]CodeDepartement) []CodeDepartement {
+	var zone []CodeDepartement
+	for _, role := range roles {
+		if _, ok := departements[CodeDepartement(role)]; ok {
+			zone = append(zone, CodeDepartement(role))
+		}
+		zone = append(zone, regions[Region(role)]...)
+	}
+	zone = utils.Uniq(zone)
+	slices.Sort(zone)
+	return zone
+}
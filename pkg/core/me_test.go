@@ -0,0 +1,41 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_effectiveDepartements_resoutLesRegions(t *testing.T) {
+	ass := assert.New(t)
+	departements := map[CodeDepartement]string{"21": "Côte-d'Or", "25": "Doubs", "75": "Paris"}
+	regions := map[Region][]CodeDepartement{"Bourgogne-Franche-Comté": {"21", "25"}}
+	roles := Scope{"75", "Bourgogne-Franche-Comté", "21", "score", "urssaf"}
+
+	actual := effectiveDepartements(roles, departements, regions)
+
+	ass.Equal([]CodeDepartement{"21", "25", "75"}, actual)
+}
+
+func Test_Preferences_validate(t *testing.T) {
+	ass := assert.New(t)
+	zero := 0
+	ten := 10
+	etat := "X"
+
+	ass.NoError(Preferences{}.validate())
+	ass.NoError(Preferences{PageSize: &ten}.validate())
+	ass.Error(Preferences{PageSize: &zero}.validate())
+	ass.Error(Preferences{DefaultFilters: &paramsListeScores{EtatAdministratif: &etat}}.validate())
+}
+
+func Test_Preferences_pageLength(t *testing.T) {
+	ass := assert.New(t)
+	viper.Set("searchPageLength", 50)
+	defer viper.Set("searchPageLength", nil)
+	ten := 10
+
+	ass.Equal(10, Preferences{PageSize: &ten}.pageLength())
+	ass.Equal(50, Preferences{}.pageLength())
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/viper"
+
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+)
+
+const maxPageSize = 500
+
+// Preferences paramètres propres à un utilisateur
+type Preferences struct {
+	DefaultListe   *string                 `json:"defaultListe,omitempty"`
+	DefaultFilters *paramsListeScores      `json:"defaultFilters,omitempty"`
+	PageSize       *int                    `json:"pageSize,omitempty"`
+	Notifications  NotificationPreferences `json:"notifications"`
+}
+
+// NotificationPreferences canaux de notification acceptés par l'utilisateur
+type NotificationPreferences struct {
+	Email    bool `json:"email"`
+	Campaign bool `json:"campaign"`
+	Kanban   bool `json:"kanban"`
+}
+
+func (p Preferences) validate() error {
+	if p.PageSize != nil && (*p.PageSize <= 0 || *p.PageSize > maxPageSize) {
+		return errors.New("la taille de page doit être comprise entre 1 et " + strconv.Itoa(maxPageSize))
+	}
+	if p.DefaultFilters != nil && p.DefaultFilters.EtatAdministratif != nil {
+		etat := *p.DefaultFilters.EtatAdministratif
+		if etat != "A" && etat != "F" {
+			return errors.New("etatAdministratif must be either absent or `A` or `F`")
+		}
+	}
+	return nil
+}
+
+func updatePreferencesHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	var preferences Preferences
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, "préférences invalides : "+err.Error())
+		return
+	}
+	if err := preferences.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := upsertPreferences(c, s.Username, preferences); err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
+
+// SelectPreferences retourne les préférences de l'utilisateur, ou des préférences vides s'il n'en a pas enregistré
+func SelectPreferences(ctx context.Context, username string) (Preferences, error) {
+	var preferences Preferences
+	err := db.Get().QueryRow(ctx,
+		`select preferences from users_preferences where username = $1`,
+		username,
+	).Scan(&preferences)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Preferences{}, nil
+	}
+	return preferences, err
+}
+
+func upsertPreferences(ctx context.Context, username string, preferences Preferences) error {
+	_, err := db.Get().Exec(ctx,
+		`insert into users_preferences (username, preferences) values ($1, $2)
+		on conflict (username) do update set preferences = excluded.preferences, date_update = current_timestamp`,
+		username, preferences,
+	)
+	return err
+}
+
+// searchPageLength retourne la taille de page choisie par l'utilisateur, à défaut celle de la configuration
+func searchPageLength(ctx context.Context, username string) int {
+	preferences, err := SelectPreferences(ctx, username)
+	if err != nil {
+		return viper.GetInt("searchPageLength")
+	}
+	return preferences.pageLength()
+}
+
+// pageLength retourne la taille de page des préférences, à défaut celle de la configuration
+func (p Preferences) pageLength() int {
+	if p.PageSize != nil {
+		return *p.PageSize
+	}
+	return viper.GetInt("searchPageLength")
+}
@@ -90,7 +90,7 @@ func getLastListeScores(c *gin.Context) {
 		Query:       params,
 		CurrentList: true,
 	}
-	limit := viper.GetInt("searchPageLength")
+	limit := searchPageLength(c, username)
 	if limit == 0 {
 		c.JSON(418, "searchPageLength must be > 0 in configuration therefore, I'm a teapot.")
 		return
@@ -178,7 +178,7 @@ func getListeScores(c *gin.Context) {
 		return
 	}
 
-	limit := viper.GetInt("searchPageLength")
+	limit := searchPageLength(c, username)
 	if limit == 0 {
 		c.JSON(418, "searchPageLength must be > 0 in configuration therefore, I'm a teapot.")
 		return
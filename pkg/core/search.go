@@ -1,10 +1,10 @@
 package core
 
 import (
+	"context"
 	"datapi/pkg/utils"
 	
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 )
 
 type searchParams struct {
@@ -66,7 +66,7 @@ func searchEtablissementHandler(c *gin.Context) {
 
 	params.roles = scopeFromContext(c)
 
-	result, Jerr := searchEtablissement(params)
+	result, Jerr := searchEtablissement(c, params)
 	if Jerr != nil {
 		c.JSON(Jerr.Code(), Jerr.Error())
 		return
@@ -94,13 +94,13 @@ func searchEtablissementTotalHandler(c *gin.Context) {
 }
 
 
-func searchEtablissement(params searchParams) (searchResult, utils.Jerror) {
+func searchEtablissement(ctx context.Context, params searchParams) (searchResult, utils.Jerror) {
 	liste, err := findAllListes()
 	if err != nil {
 		return searchResult{}, utils.ErrorToJSON(500, err)
 	}
 	zoneGeo := params.roles
-	limit := viper.GetInt("searchPageLength")
+	limit := searchPageLength(ctx, params.username)
 
 	offset := params.Page * limit
 
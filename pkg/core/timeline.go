@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"datapi/pkg/db"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+)
+
+// sqlSelectSiretTimeline liste les évènements datapi d'un établissement : actions de campagne, suivis,
+// commentaires, nouvelles alertes (le niveau d'alerte change d'une liste à l'autre) et procédures collectives
+const sqlSelectSiretTimeline = `with permissions as (
+		select p.score from f_etablissement_permissions($1, $2) p where p.siret = $3
+	), scores as (
+		select s.date_add, s.libelle_liste, s.alert,
+			lag(s.alert) over (order by s.batch, s.libelle_liste) as previous_alert
+		from score0 s
+		where s.siret = $3
+	)
+	select cea.date_action, 'campaignAction', cea.username, c.libelle || ' : ' || cea.action || coalesce(' (' || nullif(cea.detail, '') || ')', '')
+	from campaign_etablissement_action cea
+	inner join campaign_etablissement ce on ce.id = cea.id_campaign_etablissement
+	inner join campaign c on c.id = ce.id_campaign
+	where ce.siret = $3
+	union all
+	select f.since, 'follow', f.username, concat_ws(' : ', f.category, nullif(f.comment, ''))
+	from etablissement_follow f
+	where f.siret = $3 and f.since is not null
+	union all
+	select f.until, 'unfollow', f.username, concat_ws(' : ', f.unfollow_category, nullif(f.unfollow_comment, ''))
+	from etablissement_follow f
+	where f.siret = $3 and f.until is not null
+	union all
+	select e.date_history[array_length(e.date_history, 1)], 'comment', e.username, e.message_history[1]
+	from etablissement_comments e
+	where e.siret = $3
+	union all
+	select s.date_add, 'alert', null, s.libelle_liste || ' : ' || s.alert
+	from scores s
+	inner join permissions p on p.score
+	where s.alert != 'Pas d''alerte' and s.alert is distinct from s.previous_alert
+	union all
+	select p.date_effet, 'procol', null, concat_ws(' ', p.action_procol, p.stade_procol)
+	from etablissement_procol0 p
+	where p.siret = $3`
+
+func kanbanGetCardTimelineHandler(c *gin.Context) {
+	var s Session
+	s.Bind(c)
+	cardID := libwekan.CardID(c.Param("cardID"))
+
+	card, err := Kanban.SelectCardFromCardID(c, cardID, libwekan.Username(s.Username))
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	kanbanEvents, err := Kanban.GetCardTimeline(c, card)
+	if err != nil {
+		c.JSON(kanbanErrorStatus(err), err.Error())
+		return
+	}
+	var datapiEvents []KanbanTimelineEvent
+	if card.Siret != "" {
+		datapiEvents, err = selectSiretTimeline(c, s.Roles, s.Username, card.Siret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, mergeTimelines(kanbanEvents, datapiEvents))
+}
+
+func selectSiretTimeline(ctx context.Context, roles Scope, username string, siret Siret) ([]KanbanTimelineEvent, error) {
+	rows, err := db.Get().Query(ctx, sqlSelectSiretTimeline, roles, username, siret)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []KanbanTimelineEvent
+	for rows.Next() {
+		var date *time.Time
+		var username, detail *string
+		event := KanbanTimelineEvent{Source: "datapi"}
+		if err := rows.Scan(&date, &event.Type, &username, &detail); err != nil {
+			return nil, err
+		}
+		if date == nil {
+			continue
+		}
+		event.Date = *date
+		if username != nil {
+			event.Username = *username
+		}
+		if detail != nil {
+			event.Detail = *detail
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// mergeTimelines fusionne les évènements par ordre chronologique,
+// à date égale les évènements kanban précèdent ceux de datapi
+func mergeTimelines(kanbanEvents []KanbanTimelineEvent, datapiEvents []KanbanTimelineEvent) []KanbanTimelineEvent {
+	events := make([]KanbanTimelineEvent, 0, len(kanbanEvents)+len(datapiEvents))
+	events = append(events, kanbanEvents...)
+	events = append(events, datapiEvents...)
+	slices.SortStableFunc(events, func(a, b KanbanTimelineEvent) int {
+		return a.Date.Compare(b.Date)
+	})
+	return events
+}
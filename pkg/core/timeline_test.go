@@ -0,0 +1,28 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mergeTimelines_ordreChronologique(t *testing.T) {
+	ass := assert.New(t)
+	now := time.Now()
+	kanbanEvents := []KanbanTimelineEvent{
+		{Date: now, Source: "kanban", Type: "createCard"},
+		{Date: now.Add(2 * time.Hour), Source: "kanban", Type: "moveCard"},
+	}
+	datapiEvents := []KanbanTimelineEvent{
+		{Date: now.Add(-time.Hour), Source: "datapi", Type: "alert"},
+		{Date: now, Source: "datapi", Type: "follow"},
+	}
+
+	events := mergeTimelines(kanbanEvents, datapiEvents)
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	ass.Equal([]string{"alert", "createCard", "follow", "moveCard"}, types)
+}
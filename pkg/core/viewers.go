@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+)
+
+// Viewer représente un utilisateur habilité à consulter une entreprise, avec ses droits effectifs
+type Viewer struct {
+	Username         string     `json:"username"`
+	FirstName        *string    `json:"firstName"`
+	LastName         *string    `json:"lastName"`
+	Visible          bool       `json:"visible"`
+	InZone           bool       `json:"inZone"`
+	Score            bool       `json:"score"`
+	Urssaf           bool       `json:"urssaf"`
+	DGEFP            bool       `json:"dgefp"`
+	BDF              bool       `json:"bdf"`
+	Followed         bool       `json:"followed"`
+	CardHolder       bool       `json:"cardHolder"`
+	LastConsultation *time.Time `json:"lastConsultation,omitempty"`
+}
+
+// Viewers liste de Viewer
+type Viewers []Viewer
+
+func (viewers *Viewers) Tuple() []interface{} {
+	var v Viewer
+	*viewers = append(*viewers, v)
+	last := &(*viewers)[len(*viewers)-1]
+	return []interface{}{
+		&last.Username,
+		&last.FirstName,
+		&last.LastName,
+		&last.Visible,
+		&last.InZone,
+		&last.Score,
+		&last.Urssaf,
+		&last.DGEFP,
+		&last.BDF,
+		&last.Followed,
+	}
+}
+
+// les permissions sont calculées établissement par établissement puis agrégées au niveau de l'entreprise
+// $2 restreint le calcul à un seul établissement
+const sqlViewers = `select u.username, u.firstname, u.lastname,
+		bool_or(p.visible), bool_or(p.in_zone), bool_or(p.score),
+		bool_or(p.urssaf), bool_or(p.dgefp), bool_or(p.bdf),
+		f.username is not null as followed
+	from v_roles r
+		inner join etablissement0 e on e.siren = r.siren and (e.siret = $2 or $2 is null)
+		inner join users u on u.roles && r.roles
+		left join v_entreprise_follow f on f.siren = r.siren and f.username = u.username
+		left join v_alert_entreprise a on a.siren = r.siren
+		cross join lateral permissions(u.roles, r.roles, a.first_list, e.departement, f.username is not null) p
+	where r.siren = $1
+	group by u.username, u.firstname, u.lastname, f.username
+	order by bool_or(p.in_zone) desc, u.lastname, u.firstname`
+
+func (datapi *Datapi) getEntrepriseViewers(c *gin.Context) {
+	siren := c.Param("siren")
+	paths := []string{"/entreprise/get/" + siren, "/entreprise/all/" + siren, "/etablissement/get/" + siren}
+	datapi.handleViewers(c, siren, nil, paths)
+}
+
+func (datapi *Datapi) getEtablissementViewers(c *gin.Context) {
+	siret := c.Param("siret")
+	paths := []string{"/etablissement/get/" + siret}
+	datapi.handleViewers(c, siret[0:9], &siret, paths)
+}
+
+func (datapi *Datapi) handleViewers(c *gin.Context, siren string, siret *string, paths []string) {
+	var s Session
+	s.Bind(c)
+	ctx := c.Request.Context()
+
+	viewers, err := selectViewers(ctx, siren, siret)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	if len(viewers) == 0 {
+		c.JSON(http.StatusNoContent, "")
+		return
+	}
+
+	holders, err := selectCardHolders(ctx, siren, siret, libwekan.Username(s.Username))
+	if err != nil {
+		slog.Error("erreur pendant la récupération des cartes kanban", slog.String("siren", siren), slog.Any("error", err))
+	}
+	viewers.setCardHolders(holders)
+
+	if datapi.accessLogs != nil {
+		consultations, err := datapi.accessLogs.SelectLastConsultations(ctx, paths)
+		if err != nil {
+			slog.Error("erreur pendant la récupération des dernières consultations", slog.String("siren", siren), slog.Any("error", err))
+		}
+		viewers.setLastConsultations(consultations)
+	}
+	c.JSON(http.StatusOK, viewers)
+}
+
+func selectViewers(ctx context.Context, siren string, siret *string) (Viewers, error) {
+	var viewers Viewers
+	err := db.Scan(ctx, &viewers, sqlViewers, siren, siret)
+	return viewers, err
+}
+
+// selectCardHolders retourne les usernames membres ou assignés d'une carte non archivée
+// seules les cartes des tableaux dont l'utilisateur est membre sont détaillées
+func selectCardHolders(ctx context.Context, siren string, siret *string, username libwekan.Username) (map[string]bool, error) {
+	sirets := []Siret{}
+	if siret != nil {
+		sirets = append(sirets, Siret(*siret))
+	} else {
+		rows, err := db.Get().Query(ctx, `select siret from etablissement0 where siren = $1`, siren)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var s Siret
+			if err := rows.Scan(&s); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			sirets = append(sirets, s)
+		}
+		rows.Close()
+	}
+	boards := Kanban.SelectBoardsForUsername(username)
+	if len(boards) == 0 {
+		return map[string]bool{}, nil
+	}
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	cards, err := Kanban.SelectCardsFromSiretsAndBoardIDs(ctx, sirets, boardIDs, username)
+	if err != nil {
+		return nil, err
+	}
+	return cardHolders(cards, Kanban.GetWekanConfig().Users), nil
+}
+
+func cardHolders(cards []KanbanCard, users map[libwekan.UserID]libwekan.User) map[string]bool {
+	holders := make(map[string]bool)
+	for _, card := range cards {
+		if card.Archived {
+			continue
+		}
+		for _, userID := range card.MemberIDs {
+			if user, ok := users[userID]; ok {
+				holders[string(user.Username)] = true
+			}
+		}
+		for _, userID := range card.AssigneeIDs {
+			if user, ok := users[userID]; ok {
+				holders[string(user.Username)] = true
+			}
+		}
+	}
+	return holders
+}
+
+func (viewers Viewers) setCardHolders(holders map[string]bool) {
+	for i := range viewers {
+		viewers[i].CardHolder = holders[viewers[i].Username]
+	}
+}
+
+func (viewers Viewers) setLastConsultations(consultations map[string]time.Time) {
+	for i := range viewers {
+		if date, ok := consultations[viewers[i].Username]; ok {
+			viewers[i].LastConsultation = &date
+		}
+	}
+}
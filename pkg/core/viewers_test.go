@@ -0,0 +1,38 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cardHolders_ignoreLesCartesArchivees(t *testing.T) {
+	ass := assert.New(t)
+	users := map[libwekan.UserID]libwekan.User{
+		"a": {ID: "a", Username: "alice"},
+		"b": {ID: "b", Username: "bob"},
+		"c": {ID: "c", Username: "charlie"},
+	}
+	cards := []KanbanCard{
+		{MemberIDs: []libwekan.UserID{"a"}, AssigneeIDs: []libwekan.UserID{"b"}},
+		{MemberIDs: []libwekan.UserID{"c"}, Archived: true},
+		{MemberIDs: []libwekan.UserID{"inconnu"}},
+	}
+
+	holders := cardHolders(cards, users)
+
+	ass.Equal(map[string]bool{"alice": true, "bob": true}, holders)
+}
+
+func Test_setLastConsultations(t *testing.T) {
+	ass := assert.New(t)
+	date := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	viewers := Viewers{{Username: "alice"}, {Username: "bob"}}
+
+	viewers.setLastConsultations(map[string]time.Time{"alice": date})
+
+	ass.Equal(&date, viewers[0].LastConsultation)
+	ass.Nil(viewers[1].LastConsultation)
+}
@@ -22,12 +22,6 @@ package core
 //	t.Log("KanbanExports can generate a non-zero length docx file")
 //	cards := KanbanExports{}
 //
-//	docxifyPath, _ := filepath.Abs("../../build-container/docxify3.py")
-//	docxifyWorkingDir, _ := filepath.Abs("../../build-container")
-//
-//	viper.Set("docxifyPath", docxifyPath)
-//	viper.Set("docxifyWorkingDir", docxifyWorkingDir)
-//	viper.Set("docxifyPython", "python3")
 //	dateHeader, _ := time.Parse("02/01/2006", "05/06/2018")
 //	header := ExportHeader{
 //		Auteur: "test_auteur",
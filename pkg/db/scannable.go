@@ -3,9 +3,17 @@ package db
 import (
 	"context"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// Querier est l'interface commune au pool de connexions et aux transactions
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 // Scannable est une interface permettant l'usage de la fonction Scan
 // la fonction Items() doit retourner un slice de pointeurs des valeurs
 // recevant une nouvelle ligne de résultat de la requête
@@ -19,7 +27,7 @@ func Scan(ctx context.Context, scannable Scannable, sql string, params ...interf
 }
 
 // SelectTuples est une fonction permettant l'exécution d'une requête sql et la récupération des résultats dans un slice
-func SelectTuples(ctx context.Context, dbPool *pgxpool.Pool, scannable Scannable, sql string, params ...interface{}) error {
+func SelectTuples(ctx context.Context, dbPool Querier, scannable Scannable, sql string, params ...interface{}) error {
 	rows, err := dbPool.Query(ctx, sql, params...)
 	if err != nil {
 		return err
@@ -0,0 +1,172 @@
+// Package docx produit les fiches établissement au format DOCX à partir du modèle embarqué,
+// dont les champs de fusion (MERGEFIELD) sont remplis sans dépendance externe, et les convertit en PDF
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// template modèle de la fiche établissement, ses champs de fusion reprennent les noms json de core.KanbanExport
+// et l'entête auteur, date_edition et confidentialite
+//
+//go:embed template.docx
+var template []byte
+
+const documentPart = "word/document.xml"
+
+// pageBreak sépare les sections d'un document qui regroupe plusieurs établissements
+const pageBreak = `<w:p><w:r><w:br w:type="page"/></w:r></w:p>`
+
+var runRegexp = regexp.MustCompile(`(?s)<w:r(?:\s[^>]*)?>.*?</w:r>`)
+var instrTextRegexp = regexp.MustCompile(`(?s)<w:instrText[^>]*>(.*?)</w:instrText>`)
+var runPropertiesRegexp = regexp.MustCompile(`(?s)<w:rPr>.*?</w:rPr>`)
+var mergeFieldRegexp = regexp.MustCompile(`^\s*MERGEFIELD\s+"?([^\s"\\]+)`)
+var headerFooterRegexp = regexp.MustCompile(`^word/(header|footer)[0-9]*\.xml$`)
+
+// Fields valeurs des champs de fusion, désignées par le nom du champ
+type Fields map[string]string
+
+// Merge remplit le modèle : header renseigne les entêtes et pieds de page,
+// chaque élément de records produit une section du document, les sections sont séparées par un saut de page
+func Merge(header Fields, records []Fields) ([]byte, error) {
+	if len(records) == 0 {
+		return nil, errors.New("aucun établissement à exporter")
+	}
+	reader, err := zip.NewReader(bytes.NewReader(template), int64(len(template)))
+	if err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	writer := zip.NewWriter(&output)
+	for _, file := range reader.File {
+		content, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case file.Name == documentPart:
+			content, err = mergeDocument(content, header, records)
+			if err != nil {
+				return nil, err
+			}
+		case headerFooterRegexp.MatchString(file.Name):
+			content = mergeFields(content, header)
+		}
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: file.Modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+func readFile(file *zip.File) (string, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	return string(content), err
+}
+
+// mergeDocument répète le corps du document pour chaque élément de records,
+// les propriétés de section finales restent uniques
+func mergeDocument(document string, header Fields, records []Fields) (string, error) {
+	bodyStart := strings.Index(document, "<w:body>")
+	sectionStart := strings.LastIndex(document, "<w:sectPr")
+	if bodyStart < 0 || sectionStart < bodyStart {
+		return "", errors.New("le modèle docx n'a pas de corps de document")
+	}
+	bodyStart += len("<w:body>")
+	body := document[bodyStart:sectionStart]
+	sections := make([]string, 0, len(records))
+	for _, record := range records {
+		fields := Fields{}
+		for name, value := range header {
+			fields[name] = value
+		}
+		for name, value := range record {
+			fields[name] = value
+		}
+		sections = append(sections, mergeFields(body, fields))
+	}
+	return document[:bodyStart] + strings.Join(sections, pageBreak) + document[sectionStart:], nil
+}
+
+// mergeFields remplace chaque champ de fusion par un texte qui reprend la mise en forme du résultat du champ,
+// un champ absent de fields est vidé, les autres champs (numéros de page…) sont conservés
+func mergeFields(content string, fields Fields) string {
+	runs := runRegexp.FindAllStringIndex(content, -1)
+	var merged strings.Builder
+	last := 0
+	for i := 0; i < len(runs); i++ {
+		if !strings.Contains(content[runs[i][0]:runs[i][1]], `w:fldCharType="begin"`) {
+			continue
+		}
+		end, name, properties := parseField(content, runs[i:])
+		if end < 0 || name == "" {
+			continue
+		}
+		merged.WriteString(content[last:runs[i][0]])
+		merged.WriteString(textRun(properties, fields[name]))
+		last = runs[i+end][1]
+		i += end
+	}
+	merged.WriteString(content[last:])
+	return merged.String()
+}
+
+// parseField lit le champ qui commence au premier run : la position de son run de fin,
+// le nom du champ de fusion (vide pour un autre champ) et la mise en forme de son résultat
+func parseField(content string, runs [][]int) (end int, name string, properties string) {
+	var instruction strings.Builder
+	separated := false
+	for j, bounds := range runs {
+		run := content[bounds[0]:bounds[1]]
+		switch {
+		case strings.Contains(run, `w:fldCharType="end"`):
+			if match := mergeFieldRegexp.FindStringSubmatch(instruction.String()); match != nil {
+				name = match[1]
+			}
+			return j, name, properties
+		case strings.Contains(run, `w:fldCharType="separate"`):
+			separated = true
+		case !separated:
+			for _, match := range instrTextRegexp.FindAllStringSubmatch(run, -1) {
+				instruction.WriteString(match[1])
+			}
+			if properties == "" {
+				properties = runPropertiesRegexp.FindString(run)
+			}
+		case strings.Contains(run, "<w:t"):
+			properties = runPropertiesRegexp.FindString(run)
+		}
+	}
+	return -1, "", ""
+}
+
+// textRun écrit value dans un run, les retours à la ligne deviennent des sauts de ligne word
+func textRun(properties string, value string) string {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	texts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		var escaped strings.Builder
+		_ = xml.EscapeText(&escaped, []byte(line))
+		texts = append(texts, `<w:t xml:space="preserve">`+escaped.String()+`</w:t>`)
+	}
+	return "<w:r>" + properties + strings.Join(texts, "<w:br/>") + "</w:r>"
+}
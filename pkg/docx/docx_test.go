@@ -0,0 +1,84 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readPart(t *testing.T, document []byte, name string) string {
+	reader, err := zip.NewReader(bytes.NewReader(document), int64(len(document)))
+	require.NoError(t, err)
+	for _, file := range reader.File {
+		if file.Name == name {
+			content, err := readFile(file)
+			require.NoError(t, err)
+			return content
+		}
+	}
+	t.Fatalf("%s absent du document", name)
+	return ""
+}
+
+func Test_Merge_uneSectionParEtablissement(t *testing.T) {
+	ass := assert.New(t)
+	header := Fields{"auteur": "Jean Dupont", "date_edition": "19/10/2026", "confidentialite": "Haute"}
+	records := []Fields{
+		{"raison_sociale": "ENTREPRISE A", "siret": "12345678900011", "description_wekan": "ligne 1\nligne <2> & fin"},
+		{"raison_sociale": "ENTREPRISE B", "siret": "98765432100022"},
+	}
+
+	document, err := Merge(header, records)
+	require.NoError(t, err)
+
+	body := readPart(t, document, documentPart)
+	ass.NotContains(body, "MERGEFIELD")
+	ass.NotContains(body, "«")
+	ass.Contains(body, "ENTREPRISE A")
+	ass.Contains(body, "ENTREPRISE B")
+	ass.Contains(body, `ligne 1</w:t><w:br/><w:t xml:space="preserve">ligne &lt;2&gt; &amp; fin`)
+	ass.Equal(1, strings.Count(body, pageBreak))
+	ass.Equal(1, strings.Count(body, "<w:sectPr"))
+
+	headerPart := readPart(t, document, "word/header2.xml")
+	ass.Contains(headerPart, "Jean Dupont")
+	ass.Contains(headerPart, "19/10/2026")
+	ass.NotContains(headerPart, "MERGEFIELD")
+}
+
+func Test_Merge_conserveLesAutresChamps(t *testing.T) {
+	ass := assert.New(t)
+	document, err := Merge(Fields{}, []Fields{{}})
+	require.NoError(t, err)
+
+	footer := readPart(t, document, "word/footer2.xml")
+	template := readPart(t, template, "word/footer2.xml")
+	ass.Equal(template, footer)
+}
+
+func Test_Merge_sansEtablissement(t *testing.T) {
+	_, err := Merge(Fields{}, nil)
+	assert.Error(t, err)
+}
+
+func Test_ToPDF_converter(t *testing.T) {
+	ass := assert.New(t)
+	// simule soffice : copie le document d'entrée dans le répertoire de sortie avec l'extension pdf
+	converter := filepath.Join(t.TempDir(), "soffice")
+	script := "#!/bin/sh\nfor arg; do last=$arg; done\ncp \"$last\" \"$(dirname \"$last\")/document.pdf\"\n"
+	require.NoError(t, os.WriteFile(converter, []byte(script), 0700))
+
+	pdf, err := ToPDF(context.Background(), converter, []byte("contenu"))
+	ass.NoError(err)
+	ass.Equal([]byte("contenu"), pdf)
+
+	_, err = ToPDF(context.Background(), filepath.Join(t.TempDir(), "absent"), []byte("contenu"))
+	ass.Error(err)
+}
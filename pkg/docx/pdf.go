@@ -0,0 +1,31 @@
+package docx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// ToPDF convertit le document avec LibreOffice, converter est le chemin de l'exécutable `soffice`.
+// Chaque conversion utilise son propre profil, LibreOffice refusant deux instances sur le même profil.
+func ToPDF(ctx context.Context, converter string, document []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "datapi-docx-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "document.docx")
+	if err := os.WriteFile(input, document, 0600); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, converter,
+		"-env:UserInstallation=file://"+filepath.Join(dir, "profile"),
+		"--headless", "--convert-to", "pdf", "--outdir", dir, input,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("erreur pendant la conversion pdf : %w (%s)", err, output)
+	}
+	return os.ReadFile(filepath.Join(dir, "document.pdf"))
+}
@@ -4,10 +4,13 @@ import (
 	"runtime"
 
 	"github.com/gin-gonic/gin"
+
+	"datapi/pkg/kanban"
 )
 
 type healthResponse struct {
-	Goroutines int `json:"goroutines"`
+	Goroutines int                 `json:"goroutines"`
+	Kanban     kanban.ConfigStatus `json:"kanban"`
 }
 
 func ConfigureEndpoint(endpoint *gin.RouterGroup) {
@@ -17,6 +20,7 @@ func ConfigureEndpoint(endpoint *gin.RouterGroup) {
 func getHealth(c *gin.Context) {
 	response := healthResponse{
 		Goroutines: runtime.NumGoroutine(),
+		Kanban:     kanban.GetConfigStatus(),
 	}
 
 	c.JSON(200, response)
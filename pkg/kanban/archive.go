@@ -11,11 +11,12 @@ func (service wekanService) UnarchiveCard(ctx context.Context, cardID libwekan.C
 	if err != nil {
 		return core.UnknownCardError{CardIdentifier: "cardID=" + string(cardID)}
 	}
-	user, ok := WekanConfig.GetUserByUsername(username)
+	wc := getWekanConfig()
+	user, ok := wc.GetUserByUsername(username)
 	if !ok {
 		return core.ForbiddenError{Reason: "l'utilisateur n'est pas habilité à désarchiver cette carte"}
 	}
-	board, ok := WekanConfig.Boards[card.BoardID]
+	board, ok := wc.Boards[card.BoardID]
 	if !ok {
 		return core.UnknownBoardError{BoardIdentifier: "boardID=" + string(card.BoardID)}
 	}
@@ -10,11 +10,12 @@ func (service wekanService) SelectBoardsForUsername(username libwekan.Username)
 }
 
 func SelectBoardsForUser(username libwekan.Username) []libwekan.ConfigBoard {
-	user, ok := WekanConfig.GetUserByUsername(username)
+	wc := getWekanConfig()
+	user, ok := wc.GetUserByUsername(username)
 	if !ok {
 		return nil
 	}
-	configBoards := utils.GetValues(WekanConfig.Boards)
+	configBoards := utils.GetValues(wc.Boards)
 	userBoards := utils.Filter(configBoards, userIsBoardActiveMember(user))
 	return userBoards
 }
@@ -273,8 +273,9 @@ func kanbanDBExportHasSiret(siret string) func(export *core.KanbanDBExport) bool
 
 func joinCardsWithKanbanDBExports(kanbanDBExports core.KanbanDBExports, cards []libwekan.CardWithComments) core.KanbanExports {
 	var kanbanExports core.KanbanExports
+	cardToSiretFunc := cardToSiret(getWekanConfig())
 	for _, card := range cards {
-		siret := cardToSiret(WekanConfig)(card.Card)
+		siret := cardToSiretFunc(card.Card)
 
 		kanbanDBExport, ok := utils.First(kanbanDBExports, kanbanDBExportHasSiret(siret))
 		if ok {
@@ -293,7 +294,7 @@ func kanbanDBExportToKanbanExports(kanbanDBExports core.KanbanDBExports) core.Ka
 }
 
 func (service wekanService) SelectFollowsForUser(ctx context.Context, params core.KanbanSelectCardsForUserParams, db *pgxpool.Pool, roles []string) (core.Summaries, error) {
-	wc := wekanConfigForUser(WekanConfig.Copy(), params.User)
+	wc := wekanConfigForUser(getWekanConfig(), params.User)
 	pipeline := buildCardsForUserPipeline(wc, params)
 	pipeline.AppendPipeline(buildCardToCardAndCommentsPipeline())
 
@@ -301,6 +302,18 @@ func (service wekanService) SelectFollowsForUser(ctx context.Context, params cor
 	if err != nil {
 		return core.Summaries{}, err
 	}
+	return selectFollowsFromCards(ctx, params, db, roles, wc, cards)
+}
+
+// selectFollowsFromCards complète les cartes sélectionnées avec les établissements suivis par l'utilisateur
+func selectFollowsFromCards(
+	ctx context.Context,
+	params core.KanbanSelectCardsForUserParams,
+	db *pgxpool.Pool,
+	roles []string,
+	wc libwekan.Config,
+	cards []libwekan.CardWithComments,
+) (core.Summaries, error) {
 	sirets := utils.Convert(cards, cardWithCommentsToSiret(wc))
 	if params.Type == "my-cards" {
 		err := followSiretsFromWekan(ctx, params.User.Username, sirets)
@@ -321,7 +334,7 @@ func (service wekanService) SelectFollowsForUser(ctx context.Context, params cor
 
 func addKanbanCardsToSummaries(summaries core.Summaries, cards []libwekan.CardWithComments, username libwekan.Username) {
 	var mappedCards = make(map[string][]core.KanbanCard)
-	cardWithCommentsToSiretFunc := cardWithCommentsToSiret(WekanConfig)
+	cardWithCommentsToSiretFunc := cardWithCommentsToSiret(getWekanConfig())
 	wekanCardWithCommentsToKanbanCardFunc := wekanCardWithCommentsToKanbanCard(username)
 	for _, card := range cards {
 		siret := cardWithCommentsToSiretFunc(card)
@@ -56,7 +56,7 @@ func wekanCardWithCommentsURL(wekanCard libwekan.CardWithComments) string {
 	return fmt.Sprintf("%sb/%s/%s/%s",
 		viper.GetString("wekanURL"),
 		string(wekanCard.Card.BoardID),
-		string(WekanConfig.Boards[wekanCard.Card.BoardID].Board.Slug),
+		string(getWekanConfig().Boards[wekanCard.Card.BoardID].Board.Slug),
 		wekanCard.Card.ID,
 	)
 }
@@ -64,23 +64,24 @@ func wekanCardURL(wekanCard libwekan.Card) string {
 	return fmt.Sprintf("%sb/%s/%s/%s",
 		viper.GetString("wekanURL"),
 		string(wekanCard.BoardID),
-		string(WekanConfig.Boards[wekanCard.BoardID].Board.Slug),
+		string(getWekanConfig().Boards[wekanCard.BoardID].Board.Slug),
 		wekanCard.ID,
 	)
 }
 
 func wekanCardWithCommentsToKanbanCard(username libwekan.Username) func(libwekan.CardWithComments) core.KanbanCard {
 	return func(wekanCard libwekan.CardWithComments) core.KanbanCard {
-		user, _ := WekanConfig.GetUserByUsername(username)
-		boardConfig := WekanConfig.Boards[wekanCard.Card.BoardID]
+		wc := getWekanConfig()
+		user, _ := wc.GetUserByUsername(username)
+		boardConfig := wc.Boards[wekanCard.Card.BoardID]
 
-		siret := cardWithCommentsToSiret(WekanConfig)(wekanCard)
+		siret := cardWithCommentsToSiret(wc)(wekanCard)
 
 		card := core.KanbanCard{
 			ListTitle:    boardConfig.Lists[wekanCard.Card.ListID].Title,
 			Archived:     wekanCard.Card.Archived,
 			BoardTitle:   boardConfig.Board.Title,
-			Creator:      WekanConfig.Users[wekanCard.Card.UserID].Username,
+			Creator:      wc.Users[wekanCard.Card.UserID].Username,
 			LastActivity: wekanCard.Card.DateLastActivity,
 			StartAt:      wekanCard.Card.StartAt,
 			EndAt:        wekanCard.Card.EndAt,
@@ -118,16 +119,17 @@ func wekanCommentToKanbanComment(comment libwekan.Comment) core.KanbanComment {
 
 func wekanCardToKanbanCard(username libwekan.Username) func(libwekan.Card) core.KanbanCard {
 	return func(wekanCard libwekan.Card) core.KanbanCard {
-		user, _ := WekanConfig.GetUserByUsername(username)
-		boardConfig := WekanConfig.Boards[wekanCard.BoardID]
+		wc := getWekanConfig()
+		user, _ := wc.GetUserByUsername(username)
+		boardConfig := wc.Boards[wekanCard.BoardID]
 
-		siret, _ := WekanConfig.GetCardCustomFieldByName(wekanCard, "SIRET")
+		siret, _ := wc.GetCardCustomFieldByName(wekanCard, "SIRET")
 
 		card := core.KanbanCard{
 			ListTitle:    boardConfig.Lists[wekanCard.ListID].Title,
 			Archived:     wekanCard.Archived,
 			BoardTitle:   boardConfig.Board.Title,
-			Creator:      WekanConfig.Users[wekanCard.UserID].Username,
+			Creator:      wc.Users[wekanCard.UserID].Username,
 			LastActivity: wekanCard.DateLastActivity,
 			StartAt:      wekanCard.StartAt,
 			EndAt:        wekanCard.EndAt,
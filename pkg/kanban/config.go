@@ -2,27 +2,116 @@ package kanban
 
 import (
 	"context"
+	"errors"
 	"github.com/signaux-faibles/libwekan"
 	"log"
+	"sync/atomic"
 	"time"
 )
 
-func watchWekanConfig(period time.Duration) {
+// kanbanConfigSnapshot configuration des tableaux publiée par publishWekanConfig,
+// elle est partagée entre les requêtes et ne doit jamais être modifiée
+type kanbanConfigSnapshot struct {
+	config   libwekan.Config
+	version  uint64
+	loadedAt time.Time
+}
+
+var currentConfig atomic.Pointer[kanbanConfigSnapshot]
+var configVersion atomic.Uint64
+
+// configLoader recharge la configuration du service démarré, pour ReloadConfig
+var configLoader atomic.Pointer[func(ctx context.Context) error]
+
+// ConfigStatus décrit la configuration kanban publiée, Version vaut 0 tant qu'aucune configuration n'est chargée
+type ConfigStatus struct {
+	Version    uint64     `json:"version"`
+	LoadedAt   *time.Time `json:"loadedAt,omitempty"`
+	AgeSeconds float64    `json:"ageSeconds"`
+}
+
+// getWekanConfig retourne la dernière configuration publiée, sans copie
+func getWekanConfig() libwekan.Config {
+	snapshot := currentConfig.Load()
+	if snapshot == nil {
+		return libwekan.Config{}
+	}
+	return snapshot.config
+}
+
+func publishWekanConfig(config libwekan.Config) {
+	currentConfig.Store(&kanbanConfigSnapshot{
+		config:   config,
+		version:  configVersion.Add(1),
+		loadedAt: time.Now(),
+	})
+}
+
+func GetConfigStatus() ConfigStatus {
+	snapshot := currentConfig.Load()
+	if snapshot == nil {
+		return ConfigStatus{}
+	}
+	loadedAt := snapshot.loadedAt
+	return ConfigStatus{
+		Version:    snapshot.version,
+		LoadedAt:   &loadedAt,
+		AgeSeconds: time.Since(loadedAt).Seconds(),
+	}
+}
+
+// ReloadConfig recharge immédiatement la configuration du service kanban
+func ReloadConfig(ctx context.Context) (ConfigStatus, error) {
+	load := configLoader.Load()
+	if load == nil {
+		return ConfigStatus{}, errors.New("aucun service kanban n'est démarré")
+	}
+	err := (*load)(ctx)
+	return GetConfigStatus(), err
+}
+
+// configWatcher suit les changements de la configuration et appelle notify pour chacun,
+// une première fois à l'ouverture du suivi pour ne manquer aucun changement.
+// Il bloque tant que le suivi est ouvert et retourne l'erreur qui l'a interrompu.
+type configWatcher func(ctx context.Context, notify func()) error
+
+// watchKanbanConfig recharge la configuration avec load à chaque changement signalé par watch,
+// à défaut de watch ou quand le suivi échoue, la configuration est rechargée toutes les period
+func watchKanbanConfig(ctx context.Context, period time.Duration, load func(ctx context.Context) error, watch configWatcher) {
+	configLoader.Store(&load)
+	reload := func() {
+		if err := load(ctx); err != nil {
+			log.Printf("Erreur lors du chargement de la config kanban : %s", err)
+		}
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	watchFailed := false
 	for {
-		loadWekanConfig()
-		time.Sleep(period)
+		if watch == nil {
+			reload()
+		} else if err := watch(ctx, reload); err != nil {
+			if !watchFailed {
+				log.Printf("Suivi des changements de la config kanban indisponible, rechargement périodique : %s", err)
+			}
+			watchFailed = true
+			reload()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
-func loadWekanConfig() {
-	newWekanConfig, err := wekan.SelectConfig(context.Background())
+func loadWekanConfig(ctx context.Context) error {
+	config, err := wekan.SelectConfig(ctx)
 	if err != nil {
-		log.Printf("Erreur lors du chargement de la config wekan pour l'utilisateur : %s", err)
-	} else {
-		wekanConfigMutex.Lock()
-		WekanConfig = newWekanConfig
-		wekanConfigMutex.Unlock()
+		return err
 	}
+	publishWekanConfig(config)
+	return nil
 }
 
 func wekanConfigForUser(wc libwekan.Config, user libwekan.User) libwekan.Config {
@@ -30,11 +119,7 @@ func wekanConfigForUser(wc libwekan.Config, user libwekan.User) libwekan.Config
 		Boards: make(map[libwekan.BoardID]libwekan.ConfigBoard),
 	}
 
-	wekanConfigMutex.Lock()
-	old := wc.Copy()
-	wekanConfigMutex.Unlock()
-
-	for boardID, board := range old.Boards {
+	for boardID, board := range wc.Boards {
 		if board.Board.UserIsActiveMember(user) {
 			new.Boards[boardID] = board
 		}
@@ -44,7 +129,11 @@ func wekanConfigForUser(wc libwekan.Config, user libwekan.User) libwekan.Config
 }
 
 func (service wekanService) ClearBoardIDs(boardIDs []libwekan.BoardID, user libwekan.User) []libwekan.BoardID {
-	wc := WekanConfig.Copy()
+	return clearBoardIDs(boardIDs, user)
+}
+
+func clearBoardIDs(boardIDs []libwekan.BoardID, user libwekan.User) []libwekan.BoardID {
+	wc := getWekanConfig()
 	var newBoardIDs []libwekan.BoardID
 	if len(boardIDs) == 0 {
 		for id, board := range wc.Boards {
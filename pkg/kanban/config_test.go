@@ -0,0 +1,66 @@
+package kanban
+
+import (
+	"context"
+	"errors"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_publishWekanConfig_version(t *testing.T) {
+	ass := assert.New(t)
+	before := GetConfigStatus()
+
+	publishWekanConfig(libwekan.Config{})
+	status := GetConfigStatus()
+	ass.Equal(before.Version+1, status.Version)
+	ass.NotNil(status.LoadedAt)
+}
+
+func Test_watchKanbanConfig_pollsWhenWatchFails(t *testing.T) {
+	ass := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var loads atomic.Int32
+	load := func(ctx context.Context) error {
+		loads.Add(1)
+		return nil
+	}
+	watch := func(ctx context.Context, notify func()) error {
+		return errors.New("change streams indisponibles")
+	}
+
+	go watchKanbanConfig(ctx, time.Millisecond, load, watch)
+	ass.Eventually(func() bool { return loads.Load() >= 3 }, time.Second, time.Millisecond)
+}
+
+func Test_watchKanbanConfig_reloadsOnChange(t *testing.T) {
+	ass := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var loads atomic.Int32
+	load := func(ctx context.Context) error {
+		loads.Add(1)
+		return nil
+	}
+	changes := make(chan struct{})
+	watch := func(ctx context.Context, notify func()) error {
+		notify()
+		for range changes {
+			notify()
+		}
+		return nil
+	}
+
+	go watchKanbanConfig(ctx, time.Hour, load, watch)
+	changes <- struct{}{}
+	close(changes)
+	ass.Eventually(func() bool { return loads.Load() == 2 }, time.Second, time.Millisecond)
+
+	_, err := ReloadConfig(ctx)
+	ass.NoError(err)
+	ass.Equal(int32(3), loads.Load())
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/signaux-faibles/libwekan"
 	"github.com/spf13/viper"
+	"log/slog"
 	"slices"
 )
 
@@ -21,39 +22,75 @@ func (service wekanService) CreateCard(ctx context.Context, params core.KanbanNe
 		user, _ := GetUser(username)
 		return user
 	})
-	var list libwekan.List
+	card, checklists, err := buildCardFromParams(ctx, params, user, db, func(_ libwekan.BoardID, listID libwekan.ListID) (libwekan.List, error) {
+		return wekan.GetListFromID(ctx, listID)
+	})
+	if err != nil {
+		return core.KanbanCard{}, err
+	}
+	kanbanCard := wekanCardToKanbanCard(username)(card)
+	// les listes de contrôle sont écrites avant la carte : un échec ne laisse pas de carte incomplète
+	if err := insertWekanChecklists(ctx, checklists); err != nil {
+		dropWekanChecklists(ctx, card.ID)
+		return core.KanbanCard{}, err
+	}
+	err = wekan.InsertCard(ctx, card)
+	if err != nil {
+		dropWekanChecklists(ctx, card.ID)
+		return core.KanbanCard{}, err
+	}
+	for _, member := range members {
+		wekan.EnsureMemberInCard(ctx, card, member, member)
+	}
+	return kanbanCard, nil
+}
 
+// dropWekanChecklists supprime les listes de contrôle d'une carte non créée, l'erreur éventuelle est seulement journalisée
+func dropWekanChecklists(ctx context.Context, cardID libwekan.CardID) {
+	if err := deleteWekanChecklists(ctx, cardID); err != nil {
+		slog.Error("erreur pendant la suppression des listes de contrôle d'une carte non créée", slog.String("cardID", string(cardID)), slog.Any("error", err))
+	}
+}
+
+// buildCardFromParams prépare la carte demandée et ses listes de contrôle d'après le modèle du tableau,
+// getList retrouve la liste lorsqu'elle est précisée dans params
+func buildCardFromParams(
+	ctx context.Context,
+	params core.KanbanNewCardParams,
+	user libwekan.User,
+	db *pgxpool.Pool,
+	getList func(libwekan.BoardID, libwekan.ListID) (libwekan.List, error),
+) (libwekan.Card, []cardChecklist, error) {
 	board, swimlane, err := getBoardWithSwimlaneID(params.SwimlaneID)
 	if err != nil {
-		return core.KanbanCard{}, err
+		return libwekan.Card{}, nil, err
 	}
+	var list libwekan.List
 	if params.ListID != "" {
-		list, err = wekan.GetListFromID(ctx, params.ListID)
+		list, err = getList(board.Board.ID, params.ListID)
 	} else {
 		list, err = getListWithBoardID(board.Board.ID, 0)
 	}
-
 	if err != nil {
-		return core.KanbanCard{}, err
+		return libwekan.Card{}, nil, err
 	}
 	etablissement, err := getEtablissementDataFromDb(ctx, db, params.Siret)
 	if err != nil {
-		return core.KanbanCard{}, err
+		return libwekan.Card{}, nil, err
 	}
-
-	card, err := buildCard(board, list.ID, swimlane.ID, params.Description, params.Siret, user, etablissement, params.Labels)
+	cardTemplate, err := cardTemplateForBoard(board.Board.Slug)
 	if err != nil {
-		return core.KanbanCard{}, err
+		return libwekan.Card{}, nil, err
 	}
-	kanbanCard := wekanCardToKanbanCard(username)(card)
-	err = wekan.InsertCard(ctx, card)
+	description, err := cardTemplate.description(params.Description, etablissement)
 	if err != nil {
-		return core.KanbanCard{}, err
+		return libwekan.Card{}, nil, err
 	}
-	for _, member := range members {
-		wekan.EnsureMemberInCard(ctx, card, member, member)
+	card, err := buildCard(board, list.ID, swimlane.ID, description, params.Siret, user, etablissement, cardTemplate.labels(params.Labels))
+	if err != nil {
+		return libwekan.Card{}, nil, err
 	}
-	return kanbanCard, nil
+	return card, cardTemplate.checklists(card, user), nil
 }
 
 func buildCard(
@@ -97,9 +134,7 @@ func labelNameToIDConvertor(board libwekan.ConfigBoard) func(libwekan.BoardLabel
 }
 
 func getListWithListID(boardID libwekan.BoardID, listID libwekan.ListID) (libwekan.List, error) {
-	wekanConfigMutex.Lock()
-	wc := WekanConfig.Copy()
-	wekanConfigMutex.Unlock()
+	wc := getWekanConfig()
 
 	configBoard, ok := wc.Boards[boardID]
 	if !ok {
@@ -115,9 +150,7 @@ func getListWithListID(boardID libwekan.BoardID, listID libwekan.ListID) (libwek
 }
 
 func getListWithBoardID(boardID libwekan.BoardID, rank int) (libwekan.List, error) {
-	wekanConfigMutex.Lock()
-	wc := WekanConfig.Copy()
-	wekanConfigMutex.Unlock()
+	wc := getWekanConfig()
 
 	configBoard, ok := wc.Boards[boardID]
 	if !ok {
@@ -143,9 +176,7 @@ func getListWithBoardID(boardID libwekan.BoardID, rank int) (libwekan.List, erro
 }
 
 func getBoardWithSwimlaneID(swimlaneID libwekan.SwimlaneID) (libwekan.ConfigBoard, libwekan.Swimlane, error) {
-	wekanConfigMutex.Lock()
-	wc := WekanConfig.Copy()
-	wekanConfigMutex.Unlock()
+	wc := getWekanConfig()
 
 	var swimlane libwekan.Swimlane
 	var configBoard libwekan.ConfigBoard
@@ -4,7 +4,9 @@ import (
 	"context"
 	"datapi/pkg/core"
 	"datapi/pkg/utils"
+	"errors"
 	"github.com/signaux-faibles/libwekan"
+	"time"
 )
 
 func (service wekanService) UpdateCard(ctx context.Context, card core.KanbanCard,
@@ -16,3 +18,176 @@ func (service wekanService) UpdateCard(ctx context.Context, card core.KanbanCard
 	}
 	return core.ForbiddenError{}
 }
+
+// selectWekanCardForEdit retourne la carte si l'utilisateur est membre de son tableau, comme UpdateCard
+func (service wekanService) selectWekanCardForEdit(ctx context.Context, cardID libwekan.CardID, username libwekan.Username) (libwekan.Card, libwekan.User, error) {
+	card, err := wekan.GetCardFromID(ctx, cardID)
+	if err != nil {
+		return libwekan.Card{}, libwekan.User{}, core.UnknownCardError{CardIdentifier: "cardID=" + string(cardID)}
+	}
+	boards := service.SelectBoardsForUsername(username)
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	if !utils.Contains(boardIDs, card.BoardID) {
+		return libwekan.Card{}, libwekan.User{}, core.ForbiddenError{Reason: "l'utilisateur n'est pas habilité à modifier cette carte"}
+	}
+	user, _ := GetUser(username)
+	return card, user, nil
+}
+
+// UpdateCardLabels ajoute et retire les étiquettes de la carte, libwekan ne permettant pas de les retirer
+// le retrait passe directement par la base wekan, chaque modification effective produit une activité
+func (service wekanService) UpdateCardLabels(ctx context.Context, cardID libwekan.CardID, params core.KanbanCardLabelsParams, username libwekan.Username) error {
+	card, user, err := service.selectWekanCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return err
+	}
+	board := getWekanConfig().Boards[card.BoardID]
+	add, err := boardLabelIDs(board, params.Add)
+	if err != nil {
+		return err
+	}
+	remove, err := boardLabelIDs(board, params.Remove)
+	if err != nil {
+		return err
+	}
+	for _, labelID := range add {
+		err := wekan.AddLabelToCard(ctx, cardID, labelID)
+		if errors.As(err, &libwekan.NothingDoneError{}) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := insertWekanActivity(ctx, labelActivity(card, labelID, user.ID, "addedLabel")); err != nil {
+			return err
+		}
+	}
+	for _, labelID := range remove {
+		removed, err := pullWekanCardLabel(ctx, cardID, labelID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			continue
+		}
+		if err := insertWekanActivity(ctx, labelActivity(card, labelID, user.ID, "removedLabel")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (service wekanService) UpdateCardUsers(ctx context.Context, cardID libwekan.CardID, params core.KanbanCardUsersParams, username libwekan.Username) error {
+	card, user, err := service.selectWekanCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return err
+	}
+	board := getWekanConfig().Boards[card.BoardID]
+	addMembers, err := boardUsers(board, params.AddMembers)
+	if err != nil {
+		return err
+	}
+	removeMembers, err := knownUsers(params.RemoveMembers)
+	if err != nil {
+		return err
+	}
+	addAssignees, err := boardUsers(board, params.AddAssignees)
+	if err != nil {
+		return err
+	}
+	removeAssignees, err := knownUsers(params.RemoveAssignees)
+	if err != nil {
+		return err
+	}
+	for _, member := range addMembers {
+		if _, err := wekan.EnsureMemberInCard(ctx, card, user, member); err != nil {
+			return err
+		}
+	}
+	for _, member := range removeMembers {
+		if _, err := wekan.EnsureMemberOutOfCard(ctx, card, user, member); err != nil {
+			return err
+		}
+	}
+	for _, assignee := range addAssignees {
+		err := wekan.AddAssigneeToCard(ctx, card, user, assignee)
+		if err != nil && !errors.As(err, &libwekan.NothingDoneError{}) {
+			return err
+		}
+	}
+	for _, assignee := range removeAssignees {
+		if _, err := wekan.EnsureAssigneeOutOfCard(ctx, card, user, assignee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (service wekanService) SetCardStartAt(ctx context.Context, cardID libwekan.CardID, startAt *time.Time, username libwekan.Username) error {
+	if _, _, err := service.selectWekanCardForEdit(ctx, cardID, username); err != nil {
+		return err
+	}
+	return wekan.SetCardStartAt(ctx, cardID, startAt)
+}
+
+func (service wekanService) SetCardEndAt(ctx context.Context, cardID libwekan.CardID, endAt *time.Time, username libwekan.Username) error {
+	if _, _, err := service.selectWekanCardForEdit(ctx, cardID, username); err != nil {
+		return err
+	}
+	return wekan.SetCardEndAt(ctx, cardID, endAt)
+}
+
+// AddCardComment ajoute le commentaire dans la base wekan, libwekan ne permettant pas d'écrire les commentaires
+func (service wekanService) AddCardComment(ctx context.Context, cardID libwekan.CardID, text string, username libwekan.Username) (core.KanbanComment, error) {
+	card, user, err := service.selectWekanCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return core.KanbanComment{}, err
+	}
+	comment := newComment(card, user, text)
+	if err := insertWekanComment(ctx, comment); err != nil {
+		return core.KanbanComment{}, err
+	}
+	err = insertWekanActivity(ctx, commentActivity(card, comment, user.ID, "addComment"))
+	return wekanCommentToKanbanComment(comment), err
+}
+
+// UpdateCardComment modifie le texte du commentaire, seul son auteur peut le modifier
+func (service wekanService) UpdateCardComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, text string, username libwekan.Username) error {
+	card, comment, user, err := service.selectWekanCommentForEdit(ctx, cardID, commentID, username)
+	if err != nil {
+		return err
+	}
+	comment.Text = text
+	comment.ModifiedAt = time.Now()
+	if err := updateWekanComment(ctx, comment); err != nil {
+		return err
+	}
+	return insertWekanActivity(ctx, commentActivity(card, comment, user.ID, "editComment"))
+}
+
+// DeleteCardComment supprime le commentaire, seul son auteur peut le supprimer
+func (service wekanService) DeleteCardComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, username libwekan.Username) error {
+	card, comment, user, err := service.selectWekanCommentForEdit(ctx, cardID, commentID, username)
+	if err != nil {
+		return err
+	}
+	if err := deleteWekanComment(ctx, comment); err != nil {
+		return err
+	}
+	return insertWekanActivity(ctx, commentActivity(card, comment, user.ID, "deleteComment"))
+}
+
+func (service wekanService) selectWekanCommentForEdit(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, username libwekan.Username) (libwekan.Card, libwekan.Comment, libwekan.User, error) {
+	card, user, err := service.selectWekanCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return libwekan.Card{}, libwekan.Comment{}, libwekan.User{}, err
+	}
+	comment, err := selectWekanComment(ctx, cardID, commentID)
+	if err != nil {
+		return libwekan.Card{}, libwekan.Comment{}, libwekan.User{}, err
+	}
+	if err := checkCommentAuthor(comment, user); err != nil {
+		return libwekan.Card{}, libwekan.Comment{}, libwekan.User{}, err
+	}
+	return card, comment, user, nil
+}
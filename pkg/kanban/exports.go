@@ -83,13 +83,13 @@ func joinCardAndKanbanDBExport(cardAndComments libwekan.CardWithComments, kanban
 
 	we.DescriptionWekan = strings.TrimSuffix(card.Description+"\n\n"+strings.ReplaceAll(strings.Join(commentTexts, "\n\n"), "#export", ""), "\n")
 
-	board := WekanConfig.Boards[card.BoardID].Board
+	board := getWekanConfig().Boards[card.BoardID].Board
 	we.Labels = utils.Convert(card.LabelIDs, labelIDToLabelName(board))
 
 	if card.EndAt != nil {
 		we.DateFinSuivi = dateUrssaf(*card.EndAt)
 	}
-	we.Board = string(WekanConfig.Boards[card.BoardID].Board.Title)
+	we.Board = string(board.Title)
 
 	we.LastActivity = card.DateLastActivity
 
@@ -238,9 +238,9 @@ func buildCardToCardAndCommentsPipeline() libwekan.Pipeline {
 }
 
 func (service wekanService) ExportFollowsForUser(ctx context.Context, params core.KanbanSelectCardsForUserParams, db *pgxpool.Pool, roles []string) (core.KanbanExports, error) {
-	wc := WekanConfig.Copy()
+	wc := getWekanConfig()
 
-	var sirets []string
+	var cards []libwekan.CardWithComments
 	if utils.Contains(roles, "wekan") {
 		pipeline := buildCardsForUserPipeline(wc, params)
 		pipeline.AppendPipeline(buildCardToCardAndCommentsPipeline())
@@ -253,13 +253,25 @@ func (service wekanService) ExportFollowsForUser(ctx context.Context, params cor
 		}
 
 		var err error
-		var cards []libwekan.CardWithComments
-		if utils.Contains(roles, "wekan") {
-			cards, err = wekan.SelectCardsWithCommentsFromPipeline(ctx, "boards", pipeline)
-		}
+		cards, err = wekan.SelectCardsWithCommentsFromPipeline(ctx, "boards", pipeline)
 		if err != nil {
 			return core.KanbanExports{}, err
 		}
+	}
+	return exportFollowsFromCards(ctx, params, db, roles, wc, cards)
+}
+
+// exportFollowsFromCards joint les cartes sélectionnées aux données d'export des établissements
+func exportFollowsFromCards(
+	ctx context.Context,
+	params core.KanbanSelectCardsForUserParams,
+	db *pgxpool.Pool,
+	roles []string,
+	wc libwekan.Config,
+	cards []libwekan.CardWithComments,
+) (core.KanbanExports, error) {
+	var sirets []string
+	if utils.Contains(roles, "wekan") {
 		sirets = utils.Convert(cards, cardWithCommentsToSiret(wc))
 
 		// my-cards et all-cards utilisent la même méthode
@@ -276,7 +288,8 @@ func (service wekanService) ExportFollowsForUser(ctx context.Context, params cor
 func (service wekanService) SelectKanbanExportsWithSiret(ctx context.Context, siret string, username string, db *pgxpool.Pool, roles []string) (core.KanbanExports, error) {
 	var cardsWithComments []libwekan.CardWithComments
 	if utils.Contains(roles, "wekan") {
-		_, ok := WekanConfig.GetUserByUsername(libwekan.Username(username))
+		wc := getWekanConfig()
+		_, ok := wc.GetUserByUsername(libwekan.Username(username))
 		if !ok {
 			return nil, errors.New("utilisateur non trouvé")
 		}
@@ -288,6 +301,17 @@ func (service wekanService) SelectKanbanExportsWithSiret(ctx context.Context, si
 			return nil, err
 		}
 	}
+	return kanbanExportsWithSiretFromCards(ctx, siret, username, db, roles, cardsWithComments)
+}
+
+func kanbanExportsWithSiretFromCards(
+	ctx context.Context,
+	siret string,
+	username string,
+	db *pgxpool.Pool,
+	roles []string,
+	cardsWithComments []libwekan.CardWithComments,
+) (core.KanbanExports, error) {
 	kanbanDBExports, err := selectKanbanDBExportsWithSirets(ctx, []string{siret}, db, username, roles, nil, nil)
 	kanbanExports := joinCardsWithKanbanDBExports(kanbanDBExports, cardsWithComments)
 
@@ -92,7 +92,7 @@ func (service wekanService) MoveCardList(ctx context.Context, cardID libwekan.Ca
 
 func (service wekanService) MoveCardListWithTitle(ctx context.Context, card libwekan.Card, listeTitle string, user libwekan.User) error {
 	var listID libwekan.ListID
-	for id, list := range WekanConfig.Boards[card.BoardID].Lists {
+	for id, list := range getWekanConfig().Boards[card.BoardID].Lists {
 		if list.Title == listeTitle {
 			listID = id
 		}
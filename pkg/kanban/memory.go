@@ -0,0 +1,413 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/signaux-faibles/libwekan"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore conserve les tableaux kanban en mémoire, pour les tests et le développement local sans wekan ni postgres
+type memoryStore struct {
+	mu         sync.Mutex
+	config     libwekan.Config
+	cards      []libwekan.CardWithComments
+	checklists []cardChecklist
+	activities []libwekan.Activity
+}
+
+// MemoryFixture décrit le contenu initial des tableaux du service kanban en mémoire,
+// les utilisateurs, tableaux, couloirs, listes et étiquettes sont référencés par leur nom
+type MemoryFixture struct {
+	Users  []MemoryFixtureUser  `json:"users"`
+	Boards []MemoryFixtureBoard `json:"boards"`
+	Cards  []MemoryFixtureCard  `json:"cards"`
+}
+
+type MemoryFixtureUser struct {
+	Username libwekan.Username `json:"username"`
+	Fullname string            `json:"fullname"`
+}
+
+// MemoryFixtureBoard décrit un tableau, les titres des couloirs portent le code département ou la région, ex: "21 (Côte-d'Or)"
+type MemoryFixtureBoard struct {
+	Title     libwekan.BoardTitle   `json:"title"`
+	Slug      libwekan.BoardSlug    `json:"slug"`
+	Members   []libwekan.Username   `json:"members"`
+	Swimlanes []string              `json:"swimlanes"`
+	Lists     []string              `json:"lists"`
+	Labels    []libwekan.BoardLabel `json:"labels"`
+}
+
+type MemoryFixtureCard struct {
+	ID          libwekan.CardID            `json:"id"`
+	Board       libwekan.BoardSlug         `json:"board"`
+	Swimlane    string                     `json:"swimlane"`
+	List        string                     `json:"list"`
+	Siret       core.Siret                 `json:"siret"`
+	Title       string                     `json:"title"`
+	Description string                     `json:"description"`
+	Creator     libwekan.Username          `json:"creator"`
+	Members     []libwekan.Username        `json:"members"`
+	Assignees   []libwekan.Username        `json:"assignees"`
+	Labels      []libwekan.BoardLabelName  `json:"labels"`
+	Archived    bool                       `json:"archived"`
+	StartAt     *time.Time                 `json:"startAt"`
+	EndAt       *time.Time                 `json:"endAt"`
+	Comments    []MemoryFixtureCardComment `json:"comments"`
+}
+
+type MemoryFixtureCardComment struct {
+	Username  libwekan.Username `json:"username"`
+	Text      string            `json:"text"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// InitMemoryService démarre le service kanban en mémoire avec le contenu du fichier fixture
+func InitMemoryService(path string) (core.KanbanService, error) {
+	fixture, err := LoadMemoryFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMemoryService(fixture)
+}
+
+func LoadMemoryFixture(path string) (MemoryFixture, error) {
+	var fixture MemoryFixture
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, err
+	}
+	err = json.Unmarshal(content, &fixture)
+	return fixture, err
+}
+
+// NewMemoryService construit le service kanban en mémoire, la configuration est publiée immédiatement
+func NewMemoryService(fixture MemoryFixture) (core.KanbanService, error) {
+	config, cards, err := fixture.build()
+	if err != nil {
+		return nil, err
+	}
+	service := storeService{store: &memoryStore{config: config, cards: cards}}
+	if err := service.loadConfig(context.Background()); err != nil {
+		return nil, err
+	}
+	load := service.loadConfig
+	configLoader.Store(&load)
+	return service, nil
+}
+
+func (store *memoryStore) selectConfig(_ context.Context) (libwekan.Config, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.config, nil
+}
+
+func (store *memoryStore) selectCards(_ context.Context, filter cardFilter) ([]libwekan.CardWithComments, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var cards []libwekan.CardWithComments
+	for _, card := range store.cards {
+		card = withLastActivity(card)
+		siret, _ := store.config.GetCardCustomFieldByName(card.Card, "SIRET")
+		if filter.match(card.Card, siret) {
+			cards = append(cards, card)
+		}
+	}
+	sortCards(cards)
+	return cards, nil
+}
+
+func (store *memoryStore) selectActivities(_ context.Context, cardID libwekan.CardID) ([]libwekan.Activity, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var activities []libwekan.Activity
+	for _, activity := range store.activities {
+		if activity.CardID == cardID {
+			activity.ModifiedAt = activity.CreatedAt
+			activities = append(activities, activity)
+		}
+	}
+	return activities, nil
+}
+
+func (store *memoryStore) selectBoardActivities(_ context.Context, boardIDs []libwekan.BoardID, activityTypes []string) ([]libwekan.Activity, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var activities []libwekan.Activity
+	for _, activity := range store.activities {
+		if utils.Contains(boardIDs, activity.BoardID) && utils.Contains(activityTypes, activity.ActivityType) {
+			activity.ModifiedAt = activity.CreatedAt
+			activities = append(activities, activity)
+		}
+	}
+	return activities, nil
+}
+
+func (store *memoryStore) insertCard(_ context.Context, card libwekan.Card, checklists []cardChecklist, activities []libwekan.Activity) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if slices.ContainsFunc(store.cards, func(c libwekan.CardWithComments) bool { return c.Card.ID == card.ID }) {
+		return fmt.Errorf("la carte %s existe déjà", card.ID)
+	}
+	store.cards = append(store.cards, libwekan.CardWithComments{Card: card})
+	store.checklists = append(store.checklists, checklists...)
+	store.appendActivities(activities)
+	return nil
+}
+
+func (store *memoryStore) updateCard(_ context.Context, cardID libwekan.CardID, update func(card *libwekan.Card) []libwekan.Activity) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	i := slices.IndexFunc(store.cards, func(c libwekan.CardWithComments) bool { return c.Card.ID == cardID })
+	if i < 0 {
+		return core.UnknownCardError{CardIdentifier: "cardID=" + string(cardID)}
+	}
+	// les tableaux de la carte sont copiés pour ne pas modifier les cartes déjà retournées par selectCards
+	card := store.cards[i].Card
+	card.Members = slices.Clone(card.Members)
+	card.Assignees = slices.Clone(card.Assignees)
+	card.LabelIDs = slices.Clone(card.LabelIDs)
+	card.CustomFields = slices.Clone(card.CustomFields)
+	activities := update(&card)
+	store.cards[i].Card = card
+	store.appendActivities(activities)
+	return nil
+}
+
+func (store *memoryStore) upsertComment(_ context.Context, comment libwekan.Comment, activity libwekan.Activity) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	i := slices.IndexFunc(store.cards, func(c libwekan.CardWithComments) bool { return c.Card.ID == comment.CardID })
+	if i < 0 {
+		return core.UnknownCardError{CardIdentifier: "cardID=" + string(comment.CardID)}
+	}
+	comments := slices.Clone(store.cards[i].Comments)
+	j := slices.IndexFunc(comments, func(c libwekan.Comment) bool { return c.ID == comment.ID })
+	if j < 0 {
+		comments = append(comments, comment)
+	} else {
+		comments[j].Text = comment.Text
+		comments[j].ModifiedAt = comment.ModifiedAt
+	}
+	store.cards[i].Comments = comments
+	store.appendActivities([]libwekan.Activity{activity})
+	return nil
+}
+
+func (store *memoryStore) deleteComment(_ context.Context, comment libwekan.Comment, activity libwekan.Activity) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	i := slices.IndexFunc(store.cards, func(c libwekan.CardWithComments) bool { return c.Card.ID == comment.CardID })
+	if i < 0 {
+		return core.UnknownCardError{CardIdentifier: "cardID=" + string(comment.CardID)}
+	}
+	comments := slices.DeleteFunc(slices.Clone(store.cards[i].Comments), func(c libwekan.Comment) bool { return c.ID == comment.ID })
+	if len(comments) == len(store.cards[i].Comments) {
+		return core.UnknownCommentError{CommentIdentifier: "commentID=" + string(comment.ID)}
+	}
+	store.cards[i].Comments = comments
+	store.appendActivities([]libwekan.Activity{activity})
+	return nil
+}
+
+func (store *memoryStore) appendActivities(activities []libwekan.Activity) {
+	now := time.Now()
+	for _, activity := range activities {
+		if activity.CreatedAt.IsZero() {
+			activity.CreatedAt = now
+		}
+		store.activities = append(store.activities, activity)
+	}
+}
+
+var notSlugRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fixtureID construit un identifiant stable à partir des noms de la fixture, ex: "tableau-crp-bfc-accompagnement-en-cours"
+func fixtureID(names ...string) string {
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, strings.Trim(notSlugRegexp.ReplaceAllString(strings.ToLower(name), "-"), "-"))
+	}
+	return strings.Join(parts, "-")
+}
+
+func (fixture MemoryFixture) build() (libwekan.Config, []libwekan.CardWithComments, error) {
+	config := libwekan.Config{
+		Boards: make(map[libwekan.BoardID]libwekan.ConfigBoard),
+		Users:  make(map[libwekan.UserID]libwekan.User),
+	}
+	for _, user := range fixture.Users {
+		userID := libwekan.UserID(fixtureID(string(user.Username)))
+		config.Users[userID] = libwekan.User{
+			ID:       userID,
+			Username: user.Username,
+			Profile:  libwekan.UserProfile{Fullname: user.Fullname},
+		}
+	}
+	for _, fixtureBoard := range fixture.Boards {
+		configBoard, err := fixtureBoard.build(config)
+		if err != nil {
+			return libwekan.Config{}, nil, err
+		}
+		config.Boards[configBoard.Board.ID] = configBoard
+	}
+	var cards []libwekan.CardWithComments
+	for i, fixtureCard := range fixture.Cards {
+		card, err := fixtureCard.build(config, i)
+		if err != nil {
+			return libwekan.Config{}, nil, err
+		}
+		cards = append(cards, card)
+	}
+	return config, cards, nil
+}
+
+func (fixtureBoard MemoryFixtureBoard) build(config libwekan.Config) (libwekan.ConfigBoard, error) {
+	boardID := libwekan.BoardID(fixtureID(string(fixtureBoard.Slug)))
+	configBoard := libwekan.ConfigBoard{
+		Board: libwekan.Board{
+			ID:    boardID,
+			Title: fixtureBoard.Title,
+			Slug:  fixtureBoard.Slug,
+			Type:  "board",
+		},
+		Swimlanes:    make(map[libwekan.SwimlaneID]libwekan.Swimlane),
+		Lists:        make(map[libwekan.ListID]libwekan.List),
+		CustomFields: make(libwekan.ConfigCustomFields),
+	}
+	for _, username := range fixtureBoard.Members {
+		user, ok := config.GetUserByUsername(username)
+		if !ok {
+			return libwekan.ConfigBoard{}, fmt.Errorf("tableau %s : utilisateur inconnu %s", fixtureBoard.Slug, username)
+		}
+		configBoard.Board.Members = append(configBoard.Board.Members, libwekan.BoardMember{UserID: user.ID, IsActive: true})
+	}
+	for i, title := range fixtureBoard.Swimlanes {
+		swimlaneID := libwekan.SwimlaneID(fixtureID(string(boardID), title))
+		configBoard.Swimlanes[swimlaneID] = libwekan.Swimlane{ID: swimlaneID, BoardID: boardID, Title: title, Sort: float64(i), Type: "swimlane"}
+	}
+	for i, title := range fixtureBoard.Lists {
+		listID := libwekan.ListID(fixtureID(string(boardID), title))
+		configBoard.Lists[listID] = libwekan.List{ID: listID, BoardID: boardID, Title: title, Sort: float64(i), Type: "list"}
+	}
+	for _, label := range fixtureBoard.Labels {
+		label.ID = libwekan.BoardLabelID(fixtureID(string(boardID), string(label.Name)))
+		configBoard.Board.Labels = append(configBoard.Board.Labels, label)
+	}
+	for _, field := range fixtureCustomFields(boardID) {
+		configBoard.CustomFields[field.ID] = field
+	}
+	return configBoard, nil
+}
+
+// fixtureCustomFields reprend les champs personnalisés des tableaux wekan utilisés par buildCard
+func fixtureCustomFields(boardID libwekan.BoardID) []libwekan.CustomField {
+	field := func(name string, fieldType string) libwekan.CustomField {
+		return libwekan.CustomField{
+			ID:       libwekan.CardCustomFieldID(fixtureID(string(boardID), name)),
+			Name:     name,
+			Type:     fieldType,
+			BoardIDs: []libwekan.BoardID{boardID},
+		}
+	}
+	effectif := field("Effectif", "dropdown")
+	var dropdownItems []map[string]string
+	for _, item := range []string{"10-20", "20-50", "50-100", "100+"} {
+		dropdownItems = append(dropdownItems, map[string]string{"_id": fixtureID(string(effectif.ID), item), "name": item})
+	}
+	// le type des éléments de la liste déroulante n'est pas exporté par libwekan
+	content, _ := json.Marshal(map[string]any{"dropdownItems": dropdownItems})
+	_ = json.Unmarshal(content, &effectif.Settings)
+	return []libwekan.CustomField{
+		field("SIRET", "text"),
+		field("Activité", "text"),
+		effectif,
+		field("Contact", "text"),
+		field("Fiche Signaux Faibles", "text"),
+	}
+}
+
+func (fixtureCard MemoryFixtureCard) build(config libwekan.Config, rank int) (libwekan.CardWithComments, error) {
+	boardID := libwekan.BoardID(fixtureID(string(fixtureCard.Board)))
+	configBoard, ok := config.Boards[boardID]
+	if !ok {
+		return libwekan.CardWithComments{}, fmt.Errorf("carte %s : tableau inconnu %s", fixtureCard.Siret, fixtureCard.Board)
+	}
+	swimlaneID := libwekan.SwimlaneID(fixtureID(string(boardID), fixtureCard.Swimlane))
+	if _, ok := configBoard.Swimlanes[swimlaneID]; !ok {
+		return libwekan.CardWithComments{}, fmt.Errorf("carte %s : couloir inconnu %s", fixtureCard.Siret, fixtureCard.Swimlane)
+	}
+	listID := libwekan.ListID(fixtureID(string(boardID), fixtureCard.List))
+	if _, ok := configBoard.Lists[listID]; !ok {
+		return libwekan.CardWithComments{}, fmt.Errorf("carte %s : liste inconnue %s", fixtureCard.Siret, fixtureCard.List)
+	}
+	userIDs := func(usernames []libwekan.Username) ([]libwekan.UserID, error) {
+		userIDs := []libwekan.UserID{}
+		for _, username := range usernames {
+			user, ok := config.GetUserByUsername(username)
+			if !ok {
+				return nil, fmt.Errorf("carte %s : utilisateur inconnu %s", fixtureCard.Siret, username)
+			}
+			userIDs = append(userIDs, user.ID)
+		}
+		return userIDs, nil
+	}
+	creator, ok := config.GetUserByUsername(fixtureCard.Creator)
+	if !ok {
+		return libwekan.CardWithComments{}, fmt.Errorf("carte %s : utilisateur inconnu %s", fixtureCard.Siret, fixtureCard.Creator)
+	}
+	for _, label := range fixtureCard.Labels {
+		if labelNameToIDConvertor(configBoard)(label) == "" {
+			return libwekan.CardWithComments{}, fmt.Errorf("carte %s : étiquette inconnue %s", fixtureCard.Siret, label)
+		}
+	}
+
+	etablissement := core.EtablissementData{RaisonSociale: fixtureCard.Title}
+	card, err := buildCard(configBoard, listID, swimlaneID, fixtureCard.Description, fixtureCard.Siret, creator, etablissement, fixtureCard.Labels)
+	if err != nil {
+		return libwekan.CardWithComments{}, err
+	}
+	card.ID = fixtureCard.ID
+	if card.ID == "" {
+		card.ID = libwekan.CardID(fmt.Sprintf("%s-%d", boardID, rank))
+	}
+	card.Sort = float64(rank)
+	card.Archived = fixtureCard.Archived
+	card.EndAt = fixtureCard.EndAt
+	if fixtureCard.StartAt != nil {
+		card.StartAt = *fixtureCard.StartAt
+	}
+	if card.Members, err = userIDs(fixtureCard.Members); err != nil {
+		return libwekan.CardWithComments{}, err
+	}
+	if card.Assignees, err = userIDs(fixtureCard.Assignees); err != nil {
+		return libwekan.CardWithComments{}, err
+	}
+
+	cardWithComments := libwekan.CardWithComments{Card: card}
+	for i, fixtureComment := range fixtureCard.Comments {
+		author, ok := config.GetUserByUsername(fixtureComment.Username)
+		if !ok {
+			return libwekan.CardWithComments{}, fmt.Errorf("carte %s : utilisateur inconnu %s", fixtureCard.Siret, fixtureComment.Username)
+		}
+		cardWithComments.Comments = append(cardWithComments.Comments, libwekan.Comment{
+			ID:         libwekan.CommentID(fmt.Sprintf("%s-%d", card.ID, i)),
+			BoardID:    boardID,
+			CardID:     card.ID,
+			UserID:     author.ID,
+			Text:       fixtureComment.Text,
+			CreatedAt:  fixtureComment.CreatedAt,
+			ModifiedAt: fixtureComment.CreatedAt,
+		})
+	}
+	return cardWithComments, nil
+}
@@ -0,0 +1,134 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// oneMemoryFixture charge le jeu de données partagé par les tests du service kanban en mémoire
+func oneMemoryFixture(t *testing.T) MemoryFixture {
+	fixture, err := LoadMemoryFixture("testdata/kanban_fixture.json")
+	require.NoError(t, err)
+	return fixture
+}
+
+func Test_NewMemoryService_unknownUser(t *testing.T) {
+	ass := assert.New(t)
+	fixture := oneMemoryFixture(t)
+	fixture.Cards[0].Members = []libwekan.Username{"inconnu"}
+
+	_, err := NewMemoryService(fixture)
+	ass.ErrorContains(err, "utilisateur inconnu inconnu")
+}
+
+func Test_LoadMemoryFixture_example(t *testing.T) {
+	ass := assert.New(t)
+	fixture, err := LoadMemoryFixture("../../kanban_fixture.json.example")
+	require.NoError(t, err)
+
+	service, err := NewMemoryService(fixture)
+	require.NoError(t, err)
+	config := service.LoadConfigForUser("john.doe@zone51.gov")
+	ass.Len(config.Boards, 1)
+	ass.Len(config.Boards["tableau-crp-bfc"].Swimlanes, 3)
+	ass.Len(config.Boards["tableau-crp-bfc"].Lists, 4)
+}
+
+func Test_memoryService_SelectCardsFromSiret(t *testing.T) {
+	ass := assert.New(t)
+	service, err := NewMemoryService(oneMemoryFixture(t))
+	require.NoError(t, err)
+
+	cards, err := service.SelectCardsFromSiret(context.Background(), "12345678900011", "john.doe@zone51.gov")
+	ass.NoError(err)
+	ass.Len(cards, 1)
+	ass.Equal(libwekan.CardID("carte"), cards[0].ID)
+	ass.Equal(core.Siret("12345678900011"), cards[0].Siret)
+
+	cards, err = service.SelectCardsFromSiret(context.Background(), "00000000000000", "john.doe@zone51.gov")
+	ass.NoError(err)
+	ass.Empty(cards)
+}
+
+func Test_memoryService_joinAndPartCard(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	service, err := NewMemoryService(oneMemoryFixture(t))
+	require.NoError(t, err)
+	john, _ := service.GetUser("john.doe@zone51.gov")
+	jane, _ := service.GetUser("jane.doe@zone51.gov")
+
+	ass.NoError(service.JoinCard(ctx, "carte", john))
+	ass.NoError(service.JoinCard(ctx, "carte", jane))
+	card, err := service.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.NoError(err)
+	ass.Equal("Accompagnement en cours", card.ListTitle)
+	ass.ElementsMatch([]libwekan.UserID{john.ID, jane.ID}, card.MemberIDs)
+
+	ass.NoError(service.PartCard(ctx, "carte", john))
+	card, _ = service.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.Equal("Accompagnement en cours", card.ListTitle)
+	ass.Nil(card.EndAt)
+
+	ass.NoError(service.PartCard(ctx, "carte", jane))
+	card, _ = service.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.Equal("Accompagnement terminé", card.ListTitle)
+	ass.NotNil(card.EndAt)
+
+	history, err := service.GetCardMembersHistory(ctx, "carte", string(john.Username))
+	ass.NoError(err)
+	ass.Len(history, 2)
+	for _, activity := range history {
+		ass.NotNil(activity.From)
+		ass.NotNil(activity.To)
+	}
+}
+
+func Test_memoryService_JoinCard_unknownCard(t *testing.T) {
+	ass := assert.New(t)
+	service, err := NewMemoryService(oneMemoryFixture(t))
+	require.NoError(t, err)
+	john, _ := service.GetUser("john.doe@zone51.gov")
+
+	err = service.JoinCard(context.Background(), "inconnue", john)
+	ass.ErrorAs(err, &core.UnknownCardError{})
+}
+
+func Test_joinCard_clearsEndAt(t *testing.T) {
+	ass := assert.New(t)
+	user := libwekan.User{ID: "user"}
+	endAt := time.Now()
+	card := libwekan.Card{ID: "carte", ListID: "terminé", EndAt: &endAt}
+
+	activities := joinCard(&card, user, "en cours")
+	ass.Nil(card.EndAt)
+	ass.Equal(libwekan.ListID("en cours"), card.ListID)
+	ass.Equal([]string{"joinMember", "moveCard"}, activityTypes(activities))
+	ass.Equal(libwekan.ListID("terminé"), activities[1].OldListID)
+
+	ass.Empty(joinCard(&card, user, "en cours"))
+}
+
+func Test_partCard_withoutList(t *testing.T) {
+	ass := assert.New(t)
+	user := libwekan.User{ID: "user"}
+	card := libwekan.Card{ID: "carte", ListID: "en cours", Members: []libwekan.UserID{"user"}, Assignees: []libwekan.UserID{"user"}}
+
+	activities := partCard(&card, user, "", time.Now())
+	ass.Equal([]string{"unjoinMember", "unjoinAssignee"}, activityTypes(activities))
+	ass.Equal(libwekan.ListID("en cours"), card.ListID)
+	ass.Nil(card.EndAt)
+}
+
+func activityTypes(activities []libwekan.Activity) []string {
+	var types []string
+	for _, activity := range activities {
+		types = append(types, activity.ActivityType)
+	}
+	return types
+}
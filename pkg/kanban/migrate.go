@@ -0,0 +1,121 @@
+package kanban
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/signaux-faibles/libwekan"
+	"log/slog"
+)
+
+// WekanMigration dénombre les éléments copiés de wekan vers les tables kanban de postgres
+type WekanMigration struct {
+	Users      int `json:"users"`
+	Boards     int `json:"boards"`
+	Cards      int `json:"cards"`
+	Comments   int `json:"comments"`
+	Activities int `json:"activities"`
+}
+
+// MigrateWekanToPostgres copie les tableaux du domaine, leurs cartes, commentaires et historiques
+// depuis la base mongodb de wekan. La copie nécessite que le service wekan soit initialisé,
+// elle peut être relancée : les éléments déjà copiés sont mis à jour.
+func MigrateWekanToPostgres(ctx context.Context) (WekanMigration, error) {
+	var migration WekanMigration
+	wc, err := wekan.SelectConfig(ctx)
+	if err != nil {
+		return migration, err
+	}
+	pipeline := wekan.BuildDomainCardsPipeline()
+	pipeline.AppendPipeline(buildCardToCardAndCommentsPipeline())
+	cards, err := wekan.SelectCardsWithCommentsFromPipeline(ctx, "boards", pipeline)
+	if err != nil {
+		return migration, err
+	}
+
+	err = withPostgresTx(ctx, func(tx pgx.Tx) error {
+		if err := migrateWekanConfig(ctx, tx, wc, &migration); err != nil {
+			return err
+		}
+		for _, card := range cards {
+			if _, ok := wc.Boards[card.Card.BoardID]; !ok {
+				continue
+			}
+			activities, err := wekan.SelectActivitiesFromCardID(ctx, card.Card.ID)
+			if err != nil {
+				return err
+			}
+			if err := migrateWekanCard(ctx, tx, wc, card, activities, &migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return WekanMigration{}, err
+	}
+	slog.Info("migration de wekan vers postgres terminée", slog.Any("migration", migration))
+	return migration, nil
+}
+
+func migrateWekanConfig(ctx context.Context, tx pgx.Tx, wc libwekan.Config, migration *WekanMigration) error {
+	batch := &pgx.Batch{}
+	for _, user := range wc.Users {
+		batch.Queue(sqlUpsertKanbanUser, user.ID, user.Username, user.Profile.Fullname)
+		migration.Users++
+	}
+	for _, configBoard := range wc.Boards {
+		board := configBoard.Board
+		batch.Queue(sqlUpsertKanbanBoard, board.ID, board.Title, board.Slug, board.Archived, board.CreatedAt, board.ModifiedAt)
+		for _, member := range board.Members {
+			// les membres inconnus de la configuration (utilisateurs supprimés) ne sont pas repris
+			if _, ok := wc.Users[member.UserID]; ok {
+				batch.Queue(sqlUpsertKanbanBoardMember, board.ID, member.UserID, member.IsActive, member.IsAdmin)
+			}
+		}
+		for _, swimlane := range configBoard.Swimlanes {
+			batch.Queue(sqlUpsertKanbanSwimlane, swimlane.ID, board.ID, swimlane.Title, swimlane.Sort, swimlane.Archived)
+		}
+		for _, list := range configBoard.Lists {
+			batch.Queue(sqlUpsertKanbanList, list.ID, board.ID, list.Title, list.Sort, list.Archived)
+		}
+		for _, label := range board.Labels {
+			batch.Queue(sqlUpsertKanbanLabel, label.ID, board.ID, label.Name, label.Color)
+		}
+		for fieldID, field := range configBoard.CustomFields {
+			batch.Queue(sqlUpsertKanbanCustomField, fieldID, board.ID, field.Name, field.Type, field.Settings)
+		}
+		migration.Boards++
+	}
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+func migrateWekanCard(ctx context.Context, tx pgx.Tx, wc libwekan.Config, card libwekan.CardWithComments, activities []libwekan.Activity, migration *WekanMigration) error {
+	if err := upsertPostgresCard(ctx, tx, card.Card, wc); err != nil {
+		return err
+	}
+	for _, comment := range card.Comments {
+		_, err := tx.Exec(ctx, sqlUpsertKanbanComment,
+			comment.ID, card.Card.BoardID, card.Card.ID, comment.UserID, comment.Text, comment.CreatedAt, comment.ModifiedAt)
+		if err != nil {
+			return err
+		}
+		migration.Comments++
+	}
+	// l'historique est recopié intégralement pour ne pas dupliquer les activités lors d'une nouvelle migration
+	if _, err := tx.Exec(ctx, sqlDeleteKanbanActivities, card.Card.ID); err != nil {
+		return err
+	}
+	for _, activity := range activities {
+		// l'historique des membres de wekan est daté par modifiedAt, cf wekanToKanbanJoinActivities
+		if !activity.ModifiedAt.IsZero() {
+			activity.CreatedAt = activity.ModifiedAt
+		}
+		activity.BoardID = card.Card.BoardID
+		if err := insertPostgresActivity(ctx, tx, activity); err != nil {
+			return err
+		}
+		migration.Activities++
+	}
+	migration.Cards++
+	return nil
+}
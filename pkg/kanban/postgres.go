@@ -0,0 +1,222 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/signaux-faibles/libwekan"
+	"time"
+)
+
+// postgresStore stocke les tableaux kanban dans les tables kanban_* de postgres, sans instance wekan.
+// La configuration est publiée avec publishWekanConfig pour partager avec wekanService la gestion des droits et des conversions.
+type postgresStore struct {
+	slugDomainRegexp string
+}
+
+// InitPostgresService démarre le service kanban adossé à postgres, seuls les tableaux dont le slug
+// correspond à slugDomainRegexp sont chargés. La base doit être initialisée (db.Init) au préalable.
+func InitPostgresService(ctx context.Context, slugDomainRegexp string) core.KanbanService {
+	service := storeService{store: postgresStore{slugDomainRegexp: slugDomainRegexp}}
+	go watchKanbanConfig(ctx, time.Minute, service.loadConfig, nil)
+	return service
+}
+
+func (store postgresStore) selectConfig(ctx context.Context) (libwekan.Config, error) {
+	var config libwekan.Config
+	err := db.Get().QueryRow(ctx, sqlSelectKanbanConfig, store.slugDomainRegexp).Scan(&config)
+	return config, err
+}
+
+func (store postgresStore) selectCards(ctx context.Context, filter cardFilter) ([]libwekan.CardWithComments, error) {
+	return selectPostgresCards(ctx, db.Get(), filter)
+}
+
+func selectPostgresCards(ctx context.Context, q db.Querier, filter cardFilter) ([]libwekan.CardWithComments, error) {
+	rows, err := q.Query(ctx, sqlSelectKanbanCards,
+		filter.boardIDs, filter.listIDs, filter.sirets, filter.cardID, filter.userID, filter.since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cards []libwekan.CardWithComments
+	for rows.Next() {
+		var card libwekan.CardWithComments
+		err := rows.Scan(&card)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+func (store postgresStore) selectActivities(ctx context.Context, cardID libwekan.CardID) ([]libwekan.Activity, error) {
+	rows, err := db.Get().Query(ctx, sqlSelectKanbanActivities, cardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var activities []libwekan.Activity
+	for rows.Next() {
+		activity := libwekan.Activity{CardID: cardID}
+		err := rows.Scan(&activity.UserID, &activity.MemberID, &activity.ActivityType, &activity.ListID, &activity.OldListID,
+			&activity.BoardLabelID, &activity.CommentID, &activity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		activity.ModifiedAt = activity.CreatedAt
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+func (store postgresStore) selectBoardActivities(ctx context.Context, boardIDs []libwekan.BoardID, activityTypes []string) ([]libwekan.Activity, error) {
+	rows, err := db.Get().Query(ctx, sqlSelectKanbanBoardActivities, boardIDs, activityTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var activities []libwekan.Activity
+	for rows.Next() {
+		var activity libwekan.Activity
+		err := rows.Scan(&activity.CardID, &activity.BoardID, &activity.UserID, &activity.ActivityType,
+			&activity.ListID, &activity.OldListID, &activity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		activity.ModifiedAt = activity.CreatedAt
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+func (store postgresStore) insertCard(ctx context.Context, card libwekan.Card, checklists []cardChecklist, activities []libwekan.Activity) error {
+	return withPostgresTx(ctx, func(tx pgx.Tx) error {
+		if err := upsertPostgresCard(ctx, tx, card, getWekanConfig()); err != nil {
+			return err
+		}
+		if err := insertPostgresChecklists(ctx, tx, checklists); err != nil {
+			return err
+		}
+		return insertPostgresActivities(ctx, tx, activities)
+	})
+}
+
+// updateCard verrouille la carte le temps de la transaction pour que les modifications concurrentes s'appliquent l'une après l'autre
+func (store postgresStore) updateCard(ctx context.Context, cardID libwekan.CardID, update func(card *libwekan.Card) []libwekan.Activity) error {
+	return withPostgresTx(ctx, func(tx pgx.Tx) error {
+		var id libwekan.CardID
+		err := tx.QueryRow(ctx, sqlLockKanbanCard, cardID).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return core.UnknownCardError{CardIdentifier: "cardID=" + string(cardID)}
+		}
+		if err != nil {
+			return err
+		}
+		cards, err := selectPostgresCards(ctx, tx, cardFilter{boardIDs: domainBoardIDs(), cardID: &cardID})
+		if err != nil {
+			return err
+		}
+		if len(cards) == 0 {
+			return core.UnknownCardError{CardIdentifier: "cardID=" + string(cardID)}
+		}
+		card := cards[0].Card
+		activities := update(&card)
+		if err := upsertPostgresCard(ctx, tx, card, getWekanConfig()); err != nil {
+			return err
+		}
+		return insertPostgresActivities(ctx, tx, activities)
+	})
+}
+
+func (store postgresStore) upsertComment(ctx context.Context, comment libwekan.Comment, activity libwekan.Activity) error {
+	return withPostgresTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, sqlUpsertKanbanComment,
+			comment.ID, comment.BoardID, comment.CardID, comment.UserID, comment.Text, comment.CreatedAt, comment.ModifiedAt)
+		if err != nil {
+			return err
+		}
+		return insertPostgresActivity(ctx, tx, activity)
+	})
+}
+
+func (store postgresStore) deleteComment(ctx context.Context, comment libwekan.Comment, activity libwekan.Activity) error {
+	return withPostgresTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, sqlDeleteKanbanComment, comment.ID, comment.CardID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return core.UnknownCommentError{CommentIdentifier: "commentID=" + string(comment.ID)}
+		}
+		return insertPostgresActivity(ctx, tx, activity)
+	})
+}
+
+func withPostgresTx(ctx context.Context, f func(tx pgx.Tx) error) error {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// upsertPostgresCard enregistre la carte, le siret est extrait des champs personnalisés selon la configuration wc
+func upsertPostgresCard(ctx context.Context, tx pgx.Tx, card libwekan.Card, wc libwekan.Config) error {
+	siret, _ := wc.GetCardCustomFieldByName(card, "SIRET")
+	_, err := tx.Exec(ctx, sqlUpsertKanbanCard,
+		card.ID, card.BoardID, card.SwimlaneID, card.ListID, siret, card.Title, card.Description, card.UserID,
+		card.Members, card.Assignees, card.LabelIDs, card.CustomFields, card.Sort, card.Archived,
+		card.CreatedAt, card.ModifiedAt, card.DateLastActivity, nullTime(card.StartAt), card.EndAt,
+	)
+	return err
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func insertPostgresActivity(ctx context.Context, tx pgx.Tx, activity libwekan.Activity) error {
+	_, err := tx.Exec(ctx, sqlInsertKanbanActivity,
+		activity.CardID, activity.BoardID, activity.UserID, activity.MemberID, activity.ActivityType,
+		activity.ListID, activity.OldListID, activity.BoardLabelID, activity.CommentID, nullTime(activity.CreatedAt),
+	)
+	return err
+}
+
+func insertPostgresChecklists(ctx context.Context, tx pgx.Tx, checklists []cardChecklist) error {
+	for _, checklist := range checklists {
+		_, err := tx.Exec(ctx, sqlInsertKanbanChecklist,
+			checklist.ID, checklist.CardID, checklist.UserID, checklist.Title, checklist.Sort, nullTime(checklist.CreatedAt),
+		)
+		if err != nil {
+			return err
+		}
+		for _, item := range checklist.Items {
+			_, err := tx.Exec(ctx, sqlInsertKanbanChecklistItem, item.ID, checklist.ID, checklist.CardID, item.Title, item.Sort)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func insertPostgresActivities(ctx context.Context, tx pgx.Tx, activities []libwekan.Activity) error {
+	for _, activity := range activities {
+		if err := insertPostgresActivity(ctx, tx, activity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -19,3 +19,60 @@ var sqlGetDbExport string
 
 //go:embed sql/getDbExportWithoutCards.sql
 var sqlGetDbExportWithoutCards string
+
+//go:embed sql/selectKanbanConfig.sql
+var sqlSelectKanbanConfig string
+
+//go:embed sql/selectKanbanCards.sql
+var sqlSelectKanbanCards string
+
+//go:embed sql/selectKanbanActivities.sql
+var sqlSelectKanbanActivities string
+
+//go:embed sql/selectKanbanBoardActivities.sql
+var sqlSelectKanbanBoardActivities string
+
+//go:embed sql/insertKanbanActivity.sql
+var sqlInsertKanbanActivity string
+
+//go:embed sql/insertKanbanChecklist.sql
+var sqlInsertKanbanChecklist string
+
+//go:embed sql/insertKanbanChecklistItem.sql
+var sqlInsertKanbanChecklistItem string
+
+//go:embed sql/deleteKanbanActivities.sql
+var sqlDeleteKanbanActivities string
+
+//go:embed sql/upsertKanbanCard.sql
+var sqlUpsertKanbanCard string
+
+//go:embed sql/upsertKanbanComment.sql
+var sqlUpsertKanbanComment string
+
+//go:embed sql/upsertKanbanUser.sql
+var sqlUpsertKanbanUser string
+
+//go:embed sql/upsertKanbanBoard.sql
+var sqlUpsertKanbanBoard string
+
+//go:embed sql/upsertKanbanBoardMember.sql
+var sqlUpsertKanbanBoardMember string
+
+//go:embed sql/upsertKanbanSwimlane.sql
+var sqlUpsertKanbanSwimlane string
+
+//go:embed sql/upsertKanbanList.sql
+var sqlUpsertKanbanList string
+
+//go:embed sql/upsertKanbanLabel.sql
+var sqlUpsertKanbanLabel string
+
+//go:embed sql/upsertKanbanCustomField.sql
+var sqlUpsertKanbanCustomField string
+
+//go:embed sql/lockKanbanCard.sql
+var sqlLockKanbanCard string
+
+//go:embed sql/deleteKanbanComment.sql
+var sqlDeleteKanbanComment string
@@ -0,0 +1 @@
+delete from kanban_activity where card_id = $1;
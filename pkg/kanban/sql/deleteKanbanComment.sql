@@ -0,0 +1 @@
+delete from kanban_comment where id = $1 and card_id = $2;
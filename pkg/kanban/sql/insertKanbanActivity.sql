@@ -0,0 +1,3 @@
+insert into kanban_activity (card_id, board_id, user_id, member_id, activity_type, list_id, old_list_id, label_id,
+  comment_id, created_at)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, coalesce($10::timestamptz, current_timestamp));
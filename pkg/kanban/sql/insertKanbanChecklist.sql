@@ -0,0 +1,2 @@
+insert into kanban_checklist (id, card_id, user_id, title, sort, created_at)
+values ($1, $2, $3, $4, $5, coalesce($6::timestamptz, current_timestamp));
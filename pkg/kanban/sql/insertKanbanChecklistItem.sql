@@ -0,0 +1,2 @@
+insert into kanban_checklist_item (id, checklist_id, card_id, title, sort)
+values ($1, $2, $3, $4, $5);
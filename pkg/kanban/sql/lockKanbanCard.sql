@@ -0,0 +1 @@
+select id from kanban_card where id = $1 for update;
@@ -0,0 +1,5 @@
+select coalesce(user_id, ''), coalesce(member_id, ''), activity_type, coalesce(list_id, ''), coalesce(old_list_id, ''),
+  coalesce(label_id, ''), coalesce(comment_id, ''), created_at
+from kanban_activity
+where card_id = $1
+order by created_at, id;
@@ -0,0 +1,4 @@
+select card_id, board_id, coalesce(user_id, ''), activity_type, coalesce(list_id, ''), coalesce(old_list_id, ''), created_at
+from kanban_activity
+where board_id = any($1) and activity_type = any($2)
+order by created_at, id;
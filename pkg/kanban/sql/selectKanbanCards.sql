@@ -0,0 +1,41 @@
+select jsonb_build_object(
+  'card', jsonb_build_object(
+    '_id', c.id,
+    'title', c.title,
+    'boardId', c.board_id,
+    'swimlaneId', c.swimlane_id,
+    'listId', c.list_id,
+    'description', c.description,
+    'userId', c.user_id,
+    'members', c.members,
+    'assignees', c.assignees,
+    'labelIds', c.label_ids,
+    'customFields', c.custom_fields,
+    'sort', c.sort,
+    'archived', c.archived,
+    'type', 'card',
+    'createdAt', c.created_at,
+    'modifiedAt', c.modified_at,
+    'dateLastActivity', greatest(c.date_last_activity, m.last_comment),
+    'startAt', c.start_at,
+    'endAt', c.end_at
+  ),
+  'comments', coalesce(m.comments, '[]')
+)
+from kanban_card c
+left join lateral (
+  select max(modified_at) as last_comment,
+    jsonb_agg(jsonb_build_object(
+      '_id', id, 'boardId', board_id, 'cardId', card_id, 'userId', user_id, 'text', text,
+      'createdAt', created_at, 'modifiedAt', modified_at
+    ) order by created_at) as comments
+  from kanban_comment
+  where card_id = c.id
+) m on true
+where c.board_id = any($1)
+  and ($2::text[] is null or c.list_id = any($2))
+  and ($3::text[] is null or c.siret = any($3))
+  and ($4::text is null or c.id = $4)
+  and ($5::text is null or $5 = any(c.members) or $5 = any(c.assignees))
+  and ($6::timestamptz is null or greatest(c.date_last_activity, m.last_comment) >= $6)
+order by c.board_id, c.sort, c.id;
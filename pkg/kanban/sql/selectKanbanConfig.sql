@@ -0,0 +1,33 @@
+select jsonb_build_object(
+  'boards', coalesce((
+    select jsonb_object_agg(b.id, jsonb_build_object(
+      'board', jsonb_build_object(
+        '_id', b.id,
+        'title', b.title,
+        'slug', b.slug,
+        'archived', b.archived,
+        'type', 'board',
+        'createdAt', b.created_at,
+        'modifiedAt', b.modified_at,
+        'labels', coalesce((
+          select jsonb_agg(jsonb_build_object('_id', l.id, 'name', l.name, 'color', l.color) order by l.name)
+          from kanban_label l where l.board_id = b.id), '[]'),
+        'members', coalesce((
+          select jsonb_agg(jsonb_build_object('userId', m.user_id, 'isActive', m.is_active, 'isAdmin', m.is_admin))
+          from kanban_board_member m where m.board_id = b.id), '[]')
+      ),
+      'swimlanes', coalesce((
+        select jsonb_object_agg(s.id, jsonb_build_object('_id', s.id, 'title', s.title, 'boardId', s.board_id, 'sort', s.sort, 'type', 'swimlane'))
+        from kanban_swimlane s where s.board_id = b.id and not s.archived), '{}'),
+      'lists', coalesce((
+        select jsonb_object_agg(l.id, jsonb_build_object('_id', l.id, 'title', l.title, 'boardId', l.board_id, 'sort', l.sort, 'type', 'list'))
+        from kanban_list l where l.board_id = b.id and not l.archived), '{}'),
+      'customFields', coalesce((
+        select jsonb_object_agg(f.id, jsonb_build_object('_id', f.id, 'name', f.name, 'type', f.type, 'settings', f.settings, 'boardIds', jsonb_build_array(f.board_id)))
+        from kanban_custom_field f where f.board_id = b.id), '{}')
+    ))
+    from kanban_board b where b.slug ~* $1), '{}'),
+  'users', coalesce((
+    select jsonb_object_agg(u.id, jsonb_build_object('_id', u.id, 'username', u.username, 'profile', jsonb_build_object('fullname', u.fullname)))
+    from kanban_user u), '{}')
+);
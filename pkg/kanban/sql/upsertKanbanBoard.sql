@@ -0,0 +1,7 @@
+insert into kanban_board (id, title, slug, archived, created_at, modified_at)
+values ($1, $2, $3, $4, $5, $6)
+on conflict (id) do update set
+  title = excluded.title,
+  slug = excluded.slug,
+  archived = excluded.archived,
+  modified_at = excluded.modified_at;
@@ -0,0 +1,5 @@
+insert into kanban_board_member (board_id, user_id, is_active, is_admin)
+values ($1, $2, $3, $4)
+on conflict (board_id, user_id) do update set
+  is_active = excluded.is_active,
+  is_admin = excluded.is_admin;
@@ -0,0 +1,22 @@
+insert into kanban_card (id, board_id, swimlane_id, list_id, siret, title, description, user_id, members, assignees,
+  label_ids, custom_fields, sort, archived, created_at, modified_at, date_last_activity, start_at, end_at)
+values ($1, $2, $3, $4, $5, $6, $7, $8, coalesce($9::text[], '{}'), coalesce($10::text[], '{}'),
+  coalesce($11::text[], '{}'), coalesce($12::jsonb, '[]'), $13, $14, $15, $16, $17, $18, $19)
+on conflict (id) do update set
+  board_id = excluded.board_id,
+  swimlane_id = excluded.swimlane_id,
+  list_id = excluded.list_id,
+  siret = excluded.siret,
+  title = excluded.title,
+  description = excluded.description,
+  user_id = excluded.user_id,
+  members = excluded.members,
+  assignees = excluded.assignees,
+  label_ids = excluded.label_ids,
+  custom_fields = excluded.custom_fields,
+  sort = excluded.sort,
+  archived = excluded.archived,
+  modified_at = excluded.modified_at,
+  date_last_activity = excluded.date_last_activity,
+  start_at = excluded.start_at,
+  end_at = excluded.end_at;
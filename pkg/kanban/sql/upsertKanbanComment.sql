@@ -0,0 +1,5 @@
+insert into kanban_comment (id, board_id, card_id, user_id, text, created_at, modified_at)
+values ($1, $2, $3, $4, $5, $6, $7)
+on conflict (id) do update set
+  text = excluded.text,
+  modified_at = excluded.modified_at;
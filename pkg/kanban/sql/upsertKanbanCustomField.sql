@@ -0,0 +1,6 @@
+insert into kanban_custom_field (id, board_id, name, type, settings)
+values ($1, $2, $3, $4, $5)
+on conflict (board_id, id) do update set
+  name = excluded.name,
+  type = excluded.type,
+  settings = excluded.settings;
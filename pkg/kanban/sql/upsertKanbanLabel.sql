@@ -0,0 +1,5 @@
+insert into kanban_label (id, board_id, name, color)
+values ($1, $2, $3, $4)
+on conflict (board_id, id) do update set
+  name = excluded.name,
+  color = excluded.color;
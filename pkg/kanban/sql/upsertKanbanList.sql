@@ -0,0 +1,6 @@
+insert into kanban_list (id, board_id, title, sort, archived)
+values ($1, $2, $3, $4, $5)
+on conflict (id) do update set
+  title = excluded.title,
+  sort = excluded.sort,
+  archived = excluded.archived;
@@ -0,0 +1,5 @@
+insert into kanban_user (id, username, fullname)
+values ($1, $2, $3)
+on conflict (id) do update set
+  username = excluded.username,
+  fullname = excluded.fullname;
@@ -0,0 +1,249 @@
+package kanban
+
+import (
+	"cmp"
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/stats"
+	"datapi/pkg/utils"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+	"go.mongodb.org/mongo-driver/bson"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const defaultStaleDays = 30
+
+// leadTimeActivityTypes activités qui font entrer une carte dans une liste
+var leadTimeActivityTypes = []string{"createCard", "moveCard"}
+
+// ConfigureStatsEndpoint configure les routes des indicateurs des tableaux kanban
+func ConfigureStatsEndpoint(kanbanService core.KanbanService) func(statsRoute *gin.RouterGroup) {
+	return func(statsRoute *gin.RouterGroup) {
+		statsRoute.GET("", statsHandler(kanbanService))
+		statsRoute.GET("/xlsx", statsXlsxHandler(kanbanService))
+	}
+}
+
+func (service wekanService) SelectKanbanStats(ctx context.Context, username libwekan.Username, staleDays int) (core.KanbanStats, error) {
+	boards := SelectBoardsForUser(username)
+	if len(boards) == 0 {
+		return buildKanbanStats(nil, nil, nil, staleDays, time.Now(), getWekanConfig()), nil
+	}
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	cards, err := wekan.SelectCardsFromQuery(ctx, bson.M{"boardId": bson.M{"$in": boardIDs}, "archived": false})
+	if err != nil {
+		return core.KanbanStats{}, err
+	}
+	activities, err := wekan.SelectActivitiesFromQuery(ctx, bson.M{
+		"boardId":      bson.M{"$in": boardIDs},
+		"activityType": bson.M{"$in": leadTimeActivityTypes},
+	})
+	if err != nil {
+		return core.KanbanStats{}, err
+	}
+	return buildKanbanStats(boards, cards, activities, staleDays, time.Now(), getWekanConfig()), nil
+}
+
+func (service storeService) SelectKanbanStats(ctx context.Context, username libwekan.Username, staleDays int) (core.KanbanStats, error) {
+	boards := SelectBoardsForUser(username)
+	if len(boards) == 0 {
+		return buildKanbanStats(nil, nil, nil, staleDays, time.Now(), getWekanConfig()), nil
+	}
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	cards, err := service.store.selectCards(ctx, cardFilter{boardIDs: boardIDs})
+	if err != nil {
+		return core.KanbanStats{}, err
+	}
+	activities, err := service.store.selectBoardActivities(ctx, boardIDs, leadTimeActivityTypes)
+	if err != nil {
+		return core.KanbanStats{}, err
+	}
+	wekanCards := utils.Convert(cards, func(card libwekan.CardWithComments) libwekan.Card { return card.Card })
+	return buildKanbanStats(boards, wekanCards, activities, staleDays, time.Now(), getWekanConfig()), nil
+}
+
+// buildKanbanStats calcule les indicateurs des tableaux boards, dans l'ordre des titres des tableaux
+// puis du tri des listes et des couloirs
+func buildKanbanStats(boards []libwekan.ConfigBoard, cards []libwekan.Card, activities []libwekan.Activity, staleDays int, now time.Time, wc libwekan.Config) core.KanbanStats {
+	kanbanStats := core.KanbanStats{
+		StaleDays:  staleDays,
+		Lists:      []core.KanbanListStats{},
+		Swimlanes:  []core.KanbanSwimlaneStats{},
+		Labels:     []core.KanbanLabelStats{},
+		StaleCards: []core.KanbanStaleCard{},
+	}
+	boards = slices.Clone(boards)
+	slices.SortFunc(boards, func(a, b libwekan.ConfigBoard) int { return cmp.Compare(a.Board.Title, b.Board.Title) })
+	cards = utils.Filter(cards, func(card libwekan.Card) bool { return !card.Archived })
+	stays := listStays(activities)
+	staleBefore := now.Add(-time.Duration(staleDays) * 24 * time.Hour)
+
+	for _, board := range boards {
+		boardCards := utils.Filter(cards, func(card libwekan.Card) bool { return card.BoardID == board.Board.ID })
+		for _, list := range sortedByRank(board.Lists, func(list libwekan.List) float64 { return list.Sort }) {
+			listStats := core.KanbanListStats{
+				Board:    board.Board.Title,
+				List:     list.Title,
+				Cards:    countCards(boardCards, func(card libwekan.Card) bool { return card.ListID == list.ID }),
+				Passages: len(stays[list.ID]),
+			}
+			listStats.AverageDays = averageDays(stays[list.ID])
+			kanbanStats.Lists = append(kanbanStats.Lists, listStats)
+		}
+		for _, swimlane := range sortedByRank(board.Swimlanes, func(swimlane libwekan.Swimlane) float64 { return swimlane.Sort }) {
+			kanbanStats.Swimlanes = append(kanbanStats.Swimlanes, core.KanbanSwimlaneStats{
+				Board:    board.Board.Title,
+				Swimlane: swimlane.Title,
+				Cards:    countCards(boardCards, func(card libwekan.Card) bool { return card.SwimlaneID == swimlane.ID }),
+			})
+		}
+		for _, label := range board.Board.Labels {
+			kanbanStats.Labels = append(kanbanStats.Labels, core.KanbanLabelStats{
+				Board: board.Board.Title,
+				Label: label.Name,
+				Cards: countCards(boardCards, func(card libwekan.Card) bool { return utils.Contains(card.LabelIDs, label.ID) }),
+			})
+		}
+		for _, card := range boardCards {
+			if card.DateLastActivity.Before(staleBefore) {
+				siret, _ := wc.GetCardCustomFieldByName(card, "SIRET")
+				kanbanStats.StaleCards = append(kanbanStats.StaleCards, core.KanbanStaleCard{
+					Board:        board.Board.Title,
+					Swimlane:     board.Swimlanes[card.SwimlaneID].Title,
+					List:         board.Lists[card.ListID].Title,
+					CardID:       card.ID,
+					Title:        card.Title,
+					Siret:        core.Siret(siret),
+					LastActivity: card.DateLastActivity,
+					InactiveDays: int(now.Sub(card.DateLastActivity).Hours() / 24),
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(kanbanStats.StaleCards, func(a, b core.KanbanStaleCard) int {
+		return a.LastActivity.Compare(b.LastActivity)
+	})
+	return kanbanStats
+}
+
+// listStays calcule la durée des passages terminés dans chaque liste à partir des activités createCard et moveCard,
+// un passage dont l'entrée n'est pas connue n'est pas compté
+func listStays(activities []libwekan.Activity) map[libwekan.ListID][]time.Duration {
+	activities = slices.Clone(activities)
+	slices.SortStableFunc(activities, func(a, b libwekan.Activity) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	entered := make(map[libwekan.CardID]time.Time)
+	stays := make(map[libwekan.ListID][]time.Duration)
+	for _, activity := range activities {
+		if since, ok := entered[activity.CardID]; ok && activity.ActivityType == "moveCard" {
+			stays[activity.OldListID] = append(stays[activity.OldListID], activity.CreatedAt.Sub(since))
+		}
+		entered[activity.CardID] = activity.CreatedAt
+	}
+	return stays
+}
+
+func averageDays(durations []time.Duration) float64 {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	days := total.Hours() / 24 / float64(len(durations))
+	return math.Round(days*10) / 10
+}
+
+func countCards(cards []libwekan.Card, predicate func(libwekan.Card) bool) int {
+	return len(utils.Filter(cards, predicate))
+}
+
+func sortedByRank[K comparable, V any](items map[K]V, rank func(V) float64) []V {
+	values := utils.GetValues(items)
+	slices.SortFunc(values, func(a, b V) int { return cmp.Compare(rank(a), rank(b)) })
+	return values
+}
+
+func kanbanStatsFromContext(c *gin.Context, kanbanService core.KanbanService) (core.KanbanStats, bool) {
+	var s core.Session
+	s.Bind(c)
+	staleDays, err := strconv.Atoi(c.DefaultQuery("staleDays", strconv.Itoa(defaultStaleDays)))
+	if err != nil || staleDays <= 0 {
+		c.JSON(http.StatusBadRequest, "le paramètre `staleDays` doit être un entier positif")
+		return core.KanbanStats{}, false
+	}
+	kanbanStats, err := kanbanService.SelectKanbanStats(c, libwekan.Username(s.Username), staleDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, "erreur inattendue: "+err.Error())
+		return core.KanbanStats{}, false
+	}
+	return kanbanStats, true
+}
+
+func statsHandler(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		kanbanStats, ok := kanbanStatsFromContext(c, kanbanService)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, kanbanStats)
+	}
+}
+
+func statsXlsxHandler(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		kanbanStats, ok := kanbanStatsFromContext(c, kanbanService)
+		if !ok {
+			return
+		}
+		workbook, err := newKanbanStatsWorkbook(kanbanStats)
+		defer func() {
+			if err := workbook.Close(); err != nil {
+				slog.Error("erreur à la fermeture du fichier", slog.Any("error", err))
+			}
+		}()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		filename := fmt.Sprintf("stats-kanban-%s.xlsx", time.Now().Format("060102"))
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Header("Content-Type", "application/octet-stream")
+		if err := workbook.Export(c.Writer); err != nil {
+			slog.Error("erreur pendant l'export des statistiques kanban", slog.Any("error", err))
+		}
+	}
+}
+
+// newKanbanStatsWorkbook écrit une feuille par indicateur
+func newKanbanStatsWorkbook(kanbanStats core.KanbanStats) (*stats.Workbook, error) {
+	workbook := stats.NewWorkbook()
+	err := stats.WriteSheet(workbook, "listes", kanbanStats.Lists, func(l core.KanbanListStats) []any {
+		return []any{l.Board, l.List, l.Cards, l.Passages, l.AverageDays}
+	})
+	if err != nil {
+		return workbook, err
+	}
+	err = stats.WriteSheet(workbook, "départements", kanbanStats.Swimlanes, func(s core.KanbanSwimlaneStats) []any {
+		return []any{s.Board, s.Swimlane, s.Cards}
+	})
+	if err != nil {
+		return workbook, err
+	}
+	err = stats.WriteSheet(workbook, "étiquettes", kanbanStats.Labels, func(l core.KanbanLabelStats) []any {
+		return []any{l.Board, l.Label, l.Cards}
+	})
+	if err != nil {
+		return workbook, err
+	}
+	return workbook, stats.WriteSheet(workbook, "cartes inactives", kanbanStats.StaleCards, func(s core.KanbanStaleCard) []any {
+		return []any{s.Board, s.Swimlane, s.List, s.CardID, s.Title, s.Siret, s.LastActivity, s.InactiveDays}
+	})
+}
@@ -0,0 +1,78 @@
+package kanban
+
+import (
+	"context"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_listStays_ignoreUnknownEntry(t *testing.T) {
+	ass := assert.New(t)
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	activities := []libwekan.Activity{
+		{CardID: "a", ActivityType: "moveCard", OldListID: "veille", ListID: "en cours", CreatedAt: start.Add(96 * time.Hour)},
+		{CardID: "a", ActivityType: "createCard", ListID: "veille", CreatedAt: start},
+		{CardID: "b", ActivityType: "moveCard", OldListID: "veille", ListID: "en cours", CreatedAt: start},
+		{CardID: "b", ActivityType: "moveCard", OldListID: "en cours", ListID: "terminé", CreatedAt: start.Add(48 * time.Hour)},
+	}
+
+	stays := listStays(activities)
+	ass.Equal([]time.Duration{96 * time.Hour}, stays["veille"])
+	ass.Equal([]time.Duration{48 * time.Hour}, stays["en cours"])
+	ass.Equal(3.0, averageDays(append(stays["veille"], stays["en cours"]...)))
+}
+
+func Test_storeService_SelectKanbanStats(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	fixture := oneMemoryFixture(t)
+	fixture.Cards = append(fixture.Cards, MemoryFixtureCard{
+		ID:       "archivée",
+		Board:    "tableau-crp-bfc",
+		Swimlane: "21 (Côte-d'Or)",
+		List:     "A définir",
+		Creator:  "john.doe@zone51.gov",
+		Archived: true,
+	})
+	service, err := NewMemoryService(fixture)
+	require.NoError(t, err)
+	john, _ := service.GetUser("john.doe@zone51.gov")
+	require.NoError(t, service.JoinCard(ctx, "carte", john))
+
+	kanbanStats, err := service.SelectKanbanStats(ctx, john.Username, 30)
+	ass.NoError(err)
+	ass.Len(kanbanStats.Lists, 3)
+	ass.Equal("A définir", kanbanStats.Lists[0].List)
+	ass.Equal(0, kanbanStats.Lists[0].Cards)
+	ass.Equal(1, kanbanStats.Lists[1].Cards)
+	ass.Equal(1, kanbanStats.Swimlanes[0].Cards)
+	ass.Equal(libwekan.BoardLabelName("CRP"), kanbanStats.Labels[0].Label)
+	ass.Equal(1, kanbanStats.Labels[0].Cards)
+	ass.Empty(kanbanStats.StaleCards)
+
+	kanbanStats, err = service.SelectKanbanStats(ctx, "inconnu", 30)
+	ass.NoError(err)
+	ass.Empty(kanbanStats.Lists)
+}
+
+func Test_buildKanbanStats_staleCards(t *testing.T) {
+	ass := assert.New(t)
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	board := libwekan.ConfigBoard{
+		Board: libwekan.Board{ID: "tableau", Title: "Tableau"},
+		Lists: map[libwekan.ListID]libwekan.List{"liste": {ID: "liste", Title: "Veille"}},
+	}
+	cards := []libwekan.Card{
+		{ID: "récente", BoardID: "tableau", ListID: "liste", DateLastActivity: now.Add(-24 * time.Hour)},
+		{ID: "ancienne", BoardID: "tableau", ListID: "liste", DateLastActivity: now.Add(-40 * 24 * time.Hour)},
+	}
+
+	kanbanStats := buildKanbanStats([]libwekan.ConfigBoard{board}, cards, nil, 30, now, libwekan.Config{})
+	ass.Len(kanbanStats.StaleCards, 1)
+	ass.Equal(libwekan.CardID("ancienne"), kanbanStats.StaleCards[0].CardID)
+	ass.Equal("Veille", kanbanStats.StaleCards[0].List)
+	ass.Equal(40, kanbanStats.StaleCards[0].InactiveDays)
+}
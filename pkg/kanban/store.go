@@ -0,0 +1,484 @@
+package kanban
+
+import (
+	"cmp"
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/utils"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/signaux-faibles/libwekan"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// kanbanStore conserve la configuration et les cartes des tableaux pour storeService,
+// qui implémente core.KanbanService sans wekan (stockage postgres ou en mémoire)
+type kanbanStore interface {
+	selectConfig(ctx context.Context) (libwekan.Config, error)
+	selectCards(ctx context.Context, filter cardFilter) ([]libwekan.CardWithComments, error)
+	selectActivities(ctx context.Context, cardID libwekan.CardID) ([]libwekan.Activity, error)
+	// selectBoardActivities retourne les activités des tableaux boardIDs dont le type est dans activityTypes
+	selectBoardActivities(ctx context.Context, boardIDs []libwekan.BoardID, activityTypes []string) ([]libwekan.Activity, error)
+	insertCard(ctx context.Context, card libwekan.Card, checklists []cardChecklist, activities []libwekan.Activity) error
+	// updateCard applique update à la carte et enregistre les activités retournées en une seule opération
+	updateCard(ctx context.Context, cardID libwekan.CardID, update func(card *libwekan.Card) []libwekan.Activity) error
+	// upsertComment crée ou modifie le commentaire et enregistre l'activité associée
+	upsertComment(ctx context.Context, comment libwekan.Comment, activity libwekan.Activity) error
+	deleteComment(ctx context.Context, comment libwekan.Comment, activity libwekan.Activity) error
+}
+
+// cardFilter restreint la sélection des cartes aux tableaux boardIDs, les autres champs ne filtrent pas lorsqu'ils sont nil
+type cardFilter struct {
+	boardIDs []libwekan.BoardID
+	listIDs  []libwekan.ListID
+	sirets   []string
+	cardID   *libwekan.CardID
+	userID   *libwekan.UserID
+	since    *time.Time
+}
+
+// match applique le filtre sur une carte dont le siret et la dernière activité sont déjà calculés
+func (filter cardFilter) match(card libwekan.Card, siret string) bool {
+	return utils.Contains(filter.boardIDs, card.BoardID) &&
+		(filter.listIDs == nil || utils.Contains(filter.listIDs, card.ListID)) &&
+		(filter.sirets == nil || utils.Contains(filter.sirets, siret)) &&
+		(filter.cardID == nil || *filter.cardID == card.ID) &&
+		(filter.userID == nil || utils.Contains(card.Members, *filter.userID) || utils.Contains(card.Assignees, *filter.userID)) &&
+		(filter.since == nil || !card.DateLastActivity.Before(*filter.since))
+}
+
+// withLastActivity reporte sur la carte la date du dernier commentaire lorsqu'il est plus récent
+func withLastActivity(card libwekan.CardWithComments) libwekan.CardWithComments {
+	for _, comment := range card.Comments {
+		if comment.ModifiedAt.After(card.Card.DateLastActivity) {
+			card.Card.DateLastActivity = comment.ModifiedAt
+		}
+	}
+	return card
+}
+
+func compareCards(a, b libwekan.CardWithComments) int {
+	if c := cmp.Compare(a.Card.BoardID, b.Card.BoardID); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Card.Sort, b.Card.Sort); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Card.ID, b.Card.ID)
+}
+
+type storeService struct {
+	store kanbanStore
+}
+
+func (service storeService) loadConfig(ctx context.Context) error {
+	config, err := service.store.selectConfig(ctx)
+	if err != nil {
+		return err
+	}
+	publishWekanConfig(config)
+	return nil
+}
+
+func (service storeService) LoadConfigForUser(username libwekan.Username) core.KanbanConfig {
+	return kanbanConfigForUser(username)
+}
+
+func (service storeService) GetUser(username libwekan.Username) (libwekan.User, bool) {
+	return GetUser(username)
+}
+
+func (service storeService) SelectBoardsForUsername(username libwekan.Username) []libwekan.ConfigBoard {
+	return SelectBoardsForUser(username)
+}
+
+func (service storeService) ClearBoardIDs(boardIDs []libwekan.BoardID, user libwekan.User) []libwekan.BoardID {
+	return clearBoardIDs(boardIDs, user)
+}
+
+func (service storeService) GetWekanConfig() libwekan.Config {
+	return getWekanConfig()
+}
+
+func (service storeService) selectCard(ctx context.Context, cardID libwekan.CardID) (libwekan.CardWithComments, error) {
+	cards, err := service.store.selectCards(ctx, cardFilter{boardIDs: domainBoardIDs(), cardID: &cardID})
+	if err != nil {
+		return libwekan.CardWithComments{}, err
+	}
+	if len(cards) == 0 {
+		return libwekan.CardWithComments{}, core.UnknownCardError{CardIdentifier: "cardID=" + string(cardID)}
+	}
+	return cards[0], nil
+}
+
+func (service storeService) SelectCardsFromSiret(ctx context.Context, siret string, username libwekan.Username) ([]core.KanbanCard, error) {
+	cards, err := service.store.selectCards(ctx, cardFilter{boardIDs: domainBoardIDs(), sirets: []string{siret}})
+	return toKanbanCards(cards, username), err
+}
+
+func (service storeService) ExportCardsFromSiret(ctx context.Context, siret string, username libwekan.Username) ([]core.KanbanCard, error) {
+	return service.SelectCardsFromSiret(ctx, siret, username)
+}
+
+func (service storeService) SelectCardsFromSiretsAndBoardIDs(ctx context.Context, sirets []core.Siret, boardIDs []libwekan.BoardID, username libwekan.Username) ([]core.KanbanCard, error) {
+	filter := cardFilter{
+		boardIDs: boardIDs,
+		sirets:   utils.Convert(sirets, func(siret core.Siret) string { return string(siret) }),
+	}
+	cards, err := service.store.selectCards(ctx, filter)
+	return toKanbanCards(cards, username), err
+}
+
+// SelectCardFromCardID retourne la carte seulement si l'utilisateur est membre du tableau
+func (service storeService) SelectCardFromCardID(ctx context.Context, cardID libwekan.CardID, username libwekan.Username) (core.KanbanCard, error) {
+	config := service.LoadConfigForUser(username)
+	card, err := service.selectCard(ctx, cardID)
+	if err != nil {
+		return core.KanbanCard{}, err
+	}
+	if utils.Contains(utils.GetKeys(config.Boards), card.Card.BoardID) {
+		return wekanCardWithCommentsToKanbanCard(username)(card), nil
+	}
+	return core.KanbanCard{}, core.ForbiddenError{}
+}
+
+// selectCardsForUser applique les critères de /kanban/follow sur les tableaux de wc
+func (service storeService) selectCardsForUser(ctx context.Context, wc libwekan.Config, params core.KanbanSelectCardsForUserParams) ([]libwekan.CardWithComments, error) {
+	filter := cardFilter{boardIDs: utils.GetKeys(wc.Boards), since: params.Since}
+	if len(params.BoardIDs) > 0 {
+		filter.boardIDs = utils.Filter(filter.boardIDs, func(boardID libwekan.BoardID) bool {
+			return utils.Contains(params.BoardIDs, boardID)
+		})
+	}
+	if len(params.Lists) > 0 {
+		filter.listIDs = listIDsWithTitles(wc, params.Lists)
+	}
+	if params.Type == "my-cards" {
+		filter.userID = &params.User.ID
+	}
+	cards, err := service.store.selectCards(ctx, filter)
+	if err != nil || len(params.Labels) == 0 {
+		return cards, err
+	}
+	return utils.Filter(cards, cardMatchesLabels(params.Labels, params.LabelMode, wc)), nil
+}
+
+func (service storeService) SelectFollowsForUser(ctx context.Context, params core.KanbanSelectCardsForUserParams, db *pgxpool.Pool, roles []string) (core.Summaries, error) {
+	wc := wekanConfigForUser(getWekanConfig(), params.User)
+	cards, err := service.selectCardsForUser(ctx, wc, params)
+	if err != nil {
+		return core.Summaries{}, err
+	}
+	return selectFollowsFromCards(ctx, params, db, roles, wc, cards)
+}
+
+func (service storeService) ExportFollowsForUser(ctx context.Context, params core.KanbanSelectCardsForUserParams, db *pgxpool.Pool, roles []string) (core.KanbanExports, error) {
+	wc := getWekanConfig()
+	var cards []libwekan.CardWithComments
+	if utils.Contains(roles, "wekan") {
+		var err error
+		cards, err = service.selectCardsForUser(ctx, wc, params)
+		if err != nil {
+			return core.KanbanExports{}, err
+		}
+	}
+	return exportFollowsFromCards(ctx, params, db, roles, wc, cards)
+}
+
+func (service storeService) SelectKanbanExportsWithSiret(ctx context.Context, siret string, username string, db *pgxpool.Pool, roles []string) (core.KanbanExports, error) {
+	var cards []libwekan.CardWithComments
+	if utils.Contains(roles, "wekan") {
+		if _, ok := GetUser(libwekan.Username(username)); !ok {
+			return nil, errors.New("utilisateur non trouvé")
+		}
+		var err error
+		cards, err = service.store.selectCards(ctx, cardFilter{boardIDs: domainBoardIDs(), sirets: []string{siret}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return kanbanExportsWithSiretFromCards(ctx, siret, username, db, roles, cards)
+}
+
+func (service storeService) SelectCardsFromListeAndDomainRegexp(ctx context.Context, wekanDomainRegexp string, liste string) ([]libwekan.Card, error) {
+	re, err := regexp.Compile("(?i)" + wekanDomainRegexp)
+	if err != nil {
+		return nil, err
+	}
+	wc := getWekanConfig()
+	boardIDs := []libwekan.BoardID{}
+	for boardID, board := range wc.Boards {
+		if re.MatchString(string(board.Board.Slug)) {
+			boardIDs = append(boardIDs, boardID)
+		}
+	}
+	filter := cardFilter{boardIDs: boardIDs, listIDs: listIDsWithTitles(wc, []string{liste})}
+	cards, err := service.store.selectCards(ctx, filter)
+	return utils.Convert(cards, func(card libwekan.CardWithComments) libwekan.Card { return card.Card }), err
+}
+
+func (service storeService) GetCardMembersHistory(ctx context.Context, cardID libwekan.CardID, username string) ([]core.KanbanActivity, error) {
+	activities, err := service.store.selectActivities(ctx, cardID)
+	return wekanToKanbanJoinActivities(activities), err
+}
+
+// CreateCard crée la carte, les membres demandés sont ajoutés à la carte
+func (service storeService) CreateCard(ctx context.Context, params core.KanbanNewCardParams, username libwekan.Username, membersUsernames []libwekan.Username, db *pgxpool.Pool) (core.KanbanCard, error) {
+	user, ok := GetUser(username)
+	if !ok {
+		return core.KanbanCard{}, core.ForbiddenError{Reason: "l'utilisateur n'est pas enregistré dans kanban"}
+	}
+	card, checklists, err := buildCardFromParams(ctx, params, user, db, getListWithListID)
+	if err != nil {
+		return core.KanbanCard{}, err
+	}
+	activities := []libwekan.Activity{cardActivity(card, user.ID, "createCard")}
+	for _, memberUsername := range membersUsernames {
+		if member, ok := GetUser(memberUsername); ok {
+			activities = append(activities, joinCardMember(&card, member)...)
+		}
+	}
+	if err := service.store.insertCard(ctx, card, checklists, activities); err != nil {
+		return core.KanbanCard{}, err
+	}
+	return wekanCardToKanbanCard(username)(card), nil
+}
+
+// checkCardMember vérifie que l'utilisateur est membre actif du tableau de la carte
+func checkCardMember(card libwekan.Card, username libwekan.Username, reason string) error {
+	user, ok := GetUser(username)
+	if !ok {
+		return core.ForbiddenError{Reason: reason}
+	}
+	board, ok := getWekanConfig().Boards[card.BoardID]
+	if !ok {
+		return core.UnknownBoardError{BoardIdentifier: "boardID=" + string(card.BoardID)}
+	}
+	if !board.Board.UserIsActiveMember(user) {
+		return core.ForbiddenError{Reason: reason}
+	}
+	return nil
+}
+
+func (service storeService) UnarchiveCard(ctx context.Context, cardID libwekan.CardID, username libwekan.Username) error {
+	card, err := service.selectCard(ctx, cardID)
+	if err != nil {
+		return err
+	}
+	err = checkCardMember(card.Card, username, "l'utilisateur n'est pas habilité à désarchiver cette carte")
+	if err != nil {
+		return err
+	}
+	user, _ := GetUser(username)
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		if !card.Archived {
+			return nil
+		}
+		card.Archived = false
+		touchCard(card)
+		return []libwekan.Activity{cardActivity(*card, user.ID, "restoredCard")}
+	})
+}
+
+func (service storeService) UpdateCard(ctx context.Context, card core.KanbanCard, description string, username libwekan.Username) error {
+	boards := service.SelectBoardsForUsername(username)
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	if !utils.Contains(boardIDs, card.BoardID) {
+		return core.ForbiddenError{}
+	}
+	return service.store.updateCard(ctx, card.ID, func(card *libwekan.Card) []libwekan.Activity {
+		card.Description = description
+		touchCard(card)
+		return nil
+	})
+}
+
+// JoinCard ajoute l'utilisateur aux membres de la carte et la déplace dans la liste «Accompagnement en cours»
+func (service storeService) JoinCard(ctx context.Context, cardID libwekan.CardID, user libwekan.User) error {
+	card, err := service.selectCard(ctx, cardID)
+	if err != nil {
+		return err
+	}
+	listID := listIDWithTitle(kanbanConfigForUser(user.Username), card.Card.BoardID, "Accompagnement en cours")
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		return joinCard(card, user, listID)
+	})
+}
+
+// PartCard retire l'utilisateur de la carte, qui est déplacée dans la liste «Accompagnement terminé»
+// lorsqu'il n'y reste plus d'accompagnant
+func (service storeService) PartCard(ctx context.Context, cardID libwekan.CardID, user libwekan.User) error {
+	card, err := service.selectCard(ctx, cardID)
+	if err != nil {
+		return err
+	}
+	listID := listIDWithTitle(kanbanConfigForUser(user.Username), card.Card.BoardID, "Accompagnement terminé")
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		return partCard(card, user, listID, time.Now())
+	})
+}
+
+func (service storeService) MoveCardList(ctx context.Context, cardID libwekan.CardID, listID libwekan.ListID, user libwekan.User) error {
+	card, err := service.selectCard(ctx, cardID)
+	if err != nil {
+		return err
+	}
+	if _, err := getListWithListID(card.Card.BoardID, listID); err != nil {
+		return err
+	}
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		return moveCard(card, listID, user.ID)
+	})
+}
+
+func (service storeService) MoveCardListWithTitle(ctx context.Context, card libwekan.Card, listeTitle string, user libwekan.User) error {
+	lists := getWekanConfig().Boards[card.BoardID].Lists
+	listID, _, ok := utils.MapFindTest(lists, func(_ libwekan.ListID, list libwekan.List) bool { return list.Title == listeTitle })
+	if !ok {
+		return libwekan.ListNotFoundError{}
+	}
+	return service.store.updateCard(ctx, card.ID, func(card *libwekan.Card) []libwekan.Activity {
+		return moveCard(card, listID, user.ID)
+	})
+}
+
+func listIDWithTitle(config core.KanbanConfig, boardID libwekan.BoardID, title string) libwekan.ListID {
+	listID, _, _ := utils.MapFindTest(
+		config.Boards[boardID].Lists,
+		func(listID libwekan.ListID, list core.KanbanList) bool {
+			return list.Title == title
+		})
+	return listID
+}
+
+// listIDsWithTitles retourne les listes des tableaux de wc dont le titre figure dans titles,
+// la liste retournée n'est jamais nil pour ne sélectionner aucune carte quand aucune liste ne correspond
+func listIDsWithTitles(wc libwekan.Config, titles []string) []libwekan.ListID {
+	listIDs := []libwekan.ListID{}
+	for _, board := range wc.Boards {
+		for listID, list := range board.Lists {
+			if utils.Contains(titles, list.Title) {
+				listIDs = append(listIDs, listID)
+			}
+		}
+	}
+	return listIDs
+}
+
+// cardMatchesLabels reprend la sélection par étiquettes de buildMatchLabelsPipeline :
+// en mode "or" la carte porte au moins une des étiquettes, sinon elle les porte toutes
+func cardMatchesLabels(labels []libwekan.BoardLabelName, labelMode string, wc libwekan.Config) func(libwekan.CardWithComments) bool {
+	return func(card libwekan.CardWithComments) bool {
+		board := wc.Boards[card.Card.BoardID].Board
+		var matches []libwekan.BoardLabelName
+		for _, label := range board.Labels {
+			if utils.Contains(labels, label.Name) && utils.Contains(card.Card.LabelIDs, label.ID) {
+				matches = append(matches, label.Name)
+			}
+		}
+		if labelMode == "or" {
+			return len(matches) > 0
+		}
+		return len(utils.Uniq(matches)) == len(utils.Uniq(labels))
+	}
+}
+
+func domainBoardIDs() []libwekan.BoardID {
+	return utils.GetKeys(getWekanConfig().Boards)
+}
+
+func toKanbanCards(cards []libwekan.CardWithComments, username libwekan.Username) []core.KanbanCard {
+	toKanbanCard := wekanCardToKanbanCard(username)
+	return utils.Convert(cards, func(card libwekan.CardWithComments) core.KanbanCard { return toKanbanCard(card.Card) })
+}
+
+func sortCards(cards []libwekan.CardWithComments) {
+	slices.SortFunc(cards, compareCards)
+}
+
+func touchCard(card *libwekan.Card) {
+	now := time.Now()
+	card.ModifiedAt = now
+	card.DateLastActivity = now
+}
+
+func cardActivity(card libwekan.Card, userID libwekan.UserID, activityType string) libwekan.Activity {
+	return libwekan.Activity{
+		CardID:       card.ID,
+		BoardID:      card.BoardID,
+		UserID:       userID,
+		ListID:       card.ListID,
+		ActivityType: activityType,
+	}
+}
+
+// moveCard déplace la carte dans la liste, sans effet si elle s'y trouve déjà
+func moveCard(card *libwekan.Card, listID libwekan.ListID, userID libwekan.UserID) []libwekan.Activity {
+	if card.ListID == listID {
+		return nil
+	}
+	activity := cardActivity(*card, userID, "moveCard")
+	activity.OldListID = card.ListID
+	activity.ListID = listID
+	card.ListID = listID
+	touchCard(card)
+	return []libwekan.Activity{activity}
+}
+
+// joinCardMember ajoute member aux membres de la carte, sans effet s'il en fait déjà partie
+func joinCardMember(card *libwekan.Card, member libwekan.User) []libwekan.Activity {
+	if utils.Contains(card.Members, member.ID) {
+		return nil
+	}
+	card.Members = append(card.Members, member.ID)
+	touchCard(card)
+	activity := cardActivity(*card, member.ID, "joinMember")
+	activity.MemberID = member.ID
+	return []libwekan.Activity{activity}
+}
+
+// joinCard ajoute l'utilisateur à la carte et, si listID est renseigné, la déplace dans cette liste
+// en effaçant la date de fin d'accompagnement
+func joinCard(card *libwekan.Card, user libwekan.User, listID libwekan.ListID) []libwekan.Activity {
+	activities := joinCardMember(card, user)
+	if listID == "" {
+		return activities
+	}
+	activities = append(activities, moveCard(card, listID, user.ID)...)
+	if card.EndAt != nil {
+		card.EndAt = nil
+		touchCard(card)
+	}
+	return activities
+}
+
+// partCard retire l'utilisateur des membres et des assignés de la carte. Lorsque plus personne ne suit
+// la carte et que listID est renseigné, elle y est déplacée et la date de fin d'accompagnement est fixée à now
+func partCard(card *libwekan.Card, user libwekan.User, listID libwekan.ListID, now time.Time) []libwekan.Activity {
+	var activities []libwekan.Activity
+	isNotUser := func(userID libwekan.UserID) bool { return userID != user.ID }
+	if utils.Contains(card.Members, user.ID) {
+		card.Members = utils.Filter(card.Members, isNotUser)
+		activity := cardActivity(*card, user.ID, "unjoinMember")
+		activity.MemberID = user.ID
+		activities = append(activities, activity)
+	}
+	if utils.Contains(card.Assignees, user.ID) {
+		card.Assignees = utils.Filter(card.Assignees, isNotUser)
+		activity := cardActivity(*card, user.ID, "unjoinAssignee")
+		activity.MemberID = user.ID
+		activities = append(activities, activity)
+	}
+	if len(activities) > 0 {
+		touchCard(card)
+	}
+	if len(card.Members)+len(card.Assignees) > 0 || listID == "" {
+		return activities
+	}
+	activities = append(activities, moveCard(card, listID, user.ID)...)
+	card.EndAt = &now
+	touchCard(card)
+	return activities
+}
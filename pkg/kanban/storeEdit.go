@@ -0,0 +1,261 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/signaux-faibles/libwekan"
+	"slices"
+	"time"
+)
+
+// selectCardForEdit retourne la carte et son tableau si l'utilisateur est membre actif du tableau
+func (service storeService) selectCardForEdit(ctx context.Context, cardID libwekan.CardID, username libwekan.Username) (libwekan.CardWithComments, libwekan.ConfigBoard, libwekan.User, error) {
+	card, err := service.selectCard(ctx, cardID)
+	if err != nil {
+		return libwekan.CardWithComments{}, libwekan.ConfigBoard{}, libwekan.User{}, err
+	}
+	if err := checkCardMember(card.Card, username, "l'utilisateur n'est pas habilité à modifier cette carte"); err != nil {
+		return libwekan.CardWithComments{}, libwekan.ConfigBoard{}, libwekan.User{}, err
+	}
+	user, _ := GetUser(username)
+	board := getWekanConfig().Boards[card.Card.BoardID]
+	return card, board, user, nil
+}
+
+func (service storeService) UpdateCardLabels(ctx context.Context, cardID libwekan.CardID, params core.KanbanCardLabelsParams, username libwekan.Username) error {
+	_, board, user, err := service.selectCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return err
+	}
+	add, err := boardLabelIDs(board, params.Add)
+	if err != nil {
+		return err
+	}
+	remove, err := boardLabelIDs(board, params.Remove)
+	if err != nil {
+		return err
+	}
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		return updateCardLabels(card, add, remove, user.ID)
+	})
+}
+
+func (service storeService) UpdateCardUsers(ctx context.Context, cardID libwekan.CardID, params core.KanbanCardUsersParams, username libwekan.Username) error {
+	_, board, user, err := service.selectCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return err
+	}
+	addMembers, err := boardUsers(board, params.AddMembers)
+	if err != nil {
+		return err
+	}
+	removeMembers, err := knownUsers(params.RemoveMembers)
+	if err != nil {
+		return err
+	}
+	addAssignees, err := boardUsers(board, params.AddAssignees)
+	if err != nil {
+		return err
+	}
+	removeAssignees, err := knownUsers(params.RemoveAssignees)
+	if err != nil {
+		return err
+	}
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		activities := updateCardUserIDs(card, &card.Members, addMembers, removeMembers, "Member", user.ID)
+		return append(activities, updateCardUserIDs(card, &card.Assignees, addAssignees, removeAssignees, "Assignee", user.ID)...)
+	})
+}
+
+func (service storeService) SetCardStartAt(ctx context.Context, cardID libwekan.CardID, startAt *time.Time, username libwekan.Username) error {
+	if _, _, _, err := service.selectCardForEdit(ctx, cardID, username); err != nil {
+		return err
+	}
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		card.StartAt = time.Time{}
+		if startAt != nil {
+			card.StartAt = *startAt
+		}
+		touchCard(card)
+		return nil
+	})
+}
+
+func (service storeService) SetCardEndAt(ctx context.Context, cardID libwekan.CardID, endAt *time.Time, username libwekan.Username) error {
+	if _, _, _, err := service.selectCardForEdit(ctx, cardID, username); err != nil {
+		return err
+	}
+	return service.store.updateCard(ctx, cardID, func(card *libwekan.Card) []libwekan.Activity {
+		card.EndAt = endAt
+		touchCard(card)
+		return nil
+	})
+}
+
+func (service storeService) AddCardComment(ctx context.Context, cardID libwekan.CardID, text string, username libwekan.Username) (core.KanbanComment, error) {
+	card, _, user, err := service.selectCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return core.KanbanComment{}, err
+	}
+	comment := newComment(card.Card, user, text)
+	err = service.store.upsertComment(ctx, comment, commentActivity(card.Card, comment, user.ID, "addComment"))
+	return wekanCommentToKanbanComment(comment), err
+}
+
+// UpdateCardComment modifie le texte du commentaire, seul son auteur peut le modifier
+func (service storeService) UpdateCardComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, text string, username libwekan.Username) error {
+	card, comment, user, err := service.selectCommentForEdit(ctx, cardID, commentID, username)
+	if err != nil {
+		return err
+	}
+	comment.Text = text
+	comment.ModifiedAt = time.Now()
+	return service.store.upsertComment(ctx, comment, commentActivity(card, comment, user.ID, "editComment"))
+}
+
+// DeleteCardComment supprime le commentaire, seul son auteur peut le supprimer
+func (service storeService) DeleteCardComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, username libwekan.Username) error {
+	card, comment, user, err := service.selectCommentForEdit(ctx, cardID, commentID, username)
+	if err != nil {
+		return err
+	}
+	return service.store.deleteComment(ctx, comment, commentActivity(card, comment, user.ID, "deleteComment"))
+}
+
+func (service storeService) selectCommentForEdit(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID, username libwekan.Username) (libwekan.Card, libwekan.Comment, libwekan.User, error) {
+	card, _, user, err := service.selectCardForEdit(ctx, cardID, username)
+	if err != nil {
+		return libwekan.Card{}, libwekan.Comment{}, libwekan.User{}, err
+	}
+	i := slices.IndexFunc(card.Comments, func(comment libwekan.Comment) bool { return comment.ID == commentID })
+	if i < 0 {
+		return libwekan.Card{}, libwekan.Comment{}, libwekan.User{}, core.UnknownCommentError{CommentIdentifier: "commentID=" + string(commentID)}
+	}
+	if err := checkCommentAuthor(card.Comments[i], user); err != nil {
+		return libwekan.Card{}, libwekan.Comment{}, libwekan.User{}, err
+	}
+	return card.Card, card.Comments[i], user, nil
+}
+
+// newComment prépare le commentaire de user sur la carte
+func newComment(card libwekan.Card, user libwekan.User, text string) libwekan.Comment {
+	now := time.Now()
+	return libwekan.Comment{
+		ID:         libwekan.CommentID(uuid.NewString()),
+		BoardID:    card.BoardID,
+		CardID:     card.ID,
+		UserID:     user.ID,
+		Text:       text,
+		CreatedAt:  now,
+		ModifiedAt: now,
+	}
+}
+
+// checkCommentAuthor vérifie que user est l'auteur du commentaire, seul habilité à le modifier
+func checkCommentAuthor(comment libwekan.Comment, user libwekan.User) error {
+	if comment.UserID != user.ID {
+		return core.ForbiddenError{Reason: "seul l'auteur peut modifier ce commentaire"}
+	}
+	return nil
+}
+
+func labelActivity(card libwekan.Card, labelID libwekan.BoardLabelID, userID libwekan.UserID, activityType string) libwekan.Activity {
+	activity := cardActivity(card, userID, activityType)
+	activity.BoardLabelID = labelID
+	return activity
+}
+
+func commentActivity(card libwekan.Card, comment libwekan.Comment, userID libwekan.UserID, activityType string) libwekan.Activity {
+	activity := cardActivity(card, userID, activityType)
+	activity.CommentID = comment.ID
+	return activity
+}
+
+// boardLabelIDs convertit les noms d'étiquettes avec labelNameToIDConvertor, une étiquette absente du tableau est une erreur
+func boardLabelIDs(board libwekan.ConfigBoard, names []libwekan.BoardLabelName) ([]libwekan.BoardLabelID, error) {
+	toID := labelNameToIDConvertor(board)
+	var labelIDs []libwekan.BoardLabelID
+	for _, name := range names {
+		labelID := toID(name)
+		if labelID == "" {
+			return nil, core.UnknownLabelError{LabelIdentifier: "name=" + string(name)}
+		}
+		labelIDs = append(labelIDs, labelID)
+	}
+	return labelIDs, nil
+}
+
+// boardUsers retourne les utilisateurs désignés, qui doivent être membres actifs du tableau comme l'exige wekan
+func boardUsers(board libwekan.ConfigBoard, usernames []libwekan.Username) ([]libwekan.User, error) {
+	var users []libwekan.User
+	for _, username := range usernames {
+		user, ok := GetUser(username)
+		if !ok || !board.Board.UserIsActiveMember(user) {
+			return nil, core.ForbiddenError{Reason: "l'utilisateur " + string(username) + " n'est pas membre du tableau"}
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// knownUsers retourne les utilisateurs désignés, qui peuvent avoir quitté le tableau
+func knownUsers(usernames []libwekan.Username) ([]libwekan.User, error) {
+	var users []libwekan.User
+	for _, username := range usernames {
+		user, ok := GetUser(username)
+		if !ok {
+			return nil, core.ForbiddenError{Reason: "l'utilisateur " + string(username) + " n'est pas enregistré dans kanban"}
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// updateCardLabels ajoute et retire les étiquettes de la carte, seules les modifications effectives produisent une activité
+func updateCardLabels(card *libwekan.Card, add []libwekan.BoardLabelID, remove []libwekan.BoardLabelID, userID libwekan.UserID) []libwekan.Activity {
+	var activities []libwekan.Activity
+	for _, labelID := range add {
+		if !utils.Contains(card.LabelIDs, labelID) {
+			card.LabelIDs = append(card.LabelIDs, labelID)
+			activities = append(activities, labelActivity(*card, labelID, userID, "addedLabel"))
+		}
+	}
+	for _, labelID := range remove {
+		if utils.Contains(card.LabelIDs, labelID) {
+			card.LabelIDs = utils.Filter(card.LabelIDs, func(id libwekan.BoardLabelID) bool { return id != labelID })
+			activities = append(activities, labelActivity(*card, labelID, userID, "removedLabel"))
+		}
+	}
+	if len(activities) > 0 {
+		touchCard(card)
+	}
+	return activities
+}
+
+// updateCardUserIDs ajoute et retire des utilisateurs de userIDs (membres ou assignés de la carte selon kind),
+// les activités join<kind> et unjoin<kind> sont celles de wekan
+func updateCardUserIDs(card *libwekan.Card, userIDs *[]libwekan.UserID, add []libwekan.User, remove []libwekan.User, kind string, userID libwekan.UserID) []libwekan.Activity {
+	var activities []libwekan.Activity
+	for _, user := range add {
+		if !utils.Contains(*userIDs, user.ID) {
+			*userIDs = append(*userIDs, user.ID)
+			activity := cardActivity(*card, userID, "join"+kind)
+			activity.MemberID = user.ID
+			activities = append(activities, activity)
+		}
+	}
+	for _, user := range remove {
+		if utils.Contains(*userIDs, user.ID) {
+			*userIDs = utils.Filter(*userIDs, func(id libwekan.UserID) bool { return id != user.ID })
+			activity := cardActivity(*card, userID, "unjoin"+kind)
+			activity.MemberID = user.ID
+			activities = append(activities, activity)
+		}
+	}
+	if len(activities) > 0 {
+		touchCard(card)
+	}
+	return activities
+}
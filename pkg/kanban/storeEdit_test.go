@@ -0,0 +1,101 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_storeService_UpdateCardLabels(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	fixture := oneMemoryFixture(t)
+	fixture.Boards[0].Labels = append(fixture.Boards[0].Labels, libwekan.BoardLabel{Name: "CODEFI"})
+	service, err := NewMemoryService(fixture)
+	require.NoError(t, err)
+
+	params := core.KanbanCardLabelsParams{Add: []libwekan.BoardLabelName{"CODEFI"}, Remove: []libwekan.BoardLabelName{"CRP"}}
+	ass.NoError(service.UpdateCardLabels(ctx, "carte", params, "john.doe@zone51.gov"))
+	card, _ := service.SelectCardFromCardID(ctx, "carte", "john.doe@zone51.gov")
+	ass.Equal([]libwekan.BoardLabelID{"tableau-crp-bfc-codefi"}, card.LabelIDs)
+
+	params = core.KanbanCardLabelsParams{Add: []libwekan.BoardLabelName{"inconnue"}}
+	err = service.UpdateCardLabels(ctx, "carte", params, "john.doe@zone51.gov")
+	ass.ErrorAs(err, &core.UnknownLabelError{})
+}
+
+func Test_storeService_UpdateCardUsers_onlyBoardMembers(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	fixture := oneMemoryFixture(t)
+	fixture.Users = append(fixture.Users, MemoryFixtureUser{Username: "externe@zone51.gov"})
+	service, err := NewMemoryService(fixture)
+	require.NoError(t, err)
+
+	params := core.KanbanCardUsersParams{AddAssignees: []libwekan.Username{"jane.doe@zone51.gov"}}
+	ass.NoError(service.UpdateCardUsers(ctx, "carte", params, "john.doe@zone51.gov"))
+	card, _ := service.SelectCardFromCardID(ctx, "carte", "john.doe@zone51.gov")
+	ass.Equal([]libwekan.UserID{"jane-doe-zone51-gov"}, card.AssigneeIDs)
+
+	params = core.KanbanCardUsersParams{AddMembers: []libwekan.Username{"externe@zone51.gov"}}
+	err = service.UpdateCardUsers(ctx, "carte", params, "john.doe@zone51.gov")
+	ass.ErrorAs(err, &core.ForbiddenError{})
+
+	err = service.UpdateCardUsers(ctx, "carte", core.KanbanCardUsersParams{}, "externe@zone51.gov")
+	ass.ErrorAs(err, &core.ForbiddenError{})
+}
+
+func Test_storeService_SetCardEndAt(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	service, err := NewMemoryService(oneMemoryFixture(t))
+	require.NoError(t, err)
+	endAt := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	ass.NoError(service.SetCardEndAt(ctx, "carte", &endAt, "john.doe@zone51.gov"))
+	card, _ := service.SelectCardFromCardID(ctx, "carte", "john.doe@zone51.gov")
+	ass.Equal(&endAt, card.EndAt)
+
+	ass.NoError(service.SetCardEndAt(ctx, "carte", nil, "john.doe@zone51.gov"))
+	card, _ = service.SelectCardFromCardID(ctx, "carte", "john.doe@zone51.gov")
+	ass.Nil(card.EndAt)
+}
+
+func Test_storeService_comments(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	service, err := NewMemoryService(oneMemoryFixture(t))
+	require.NoError(t, err)
+
+	comment, err := service.AddCardComment(ctx, "carte", "premier contact", "john.doe@zone51.gov")
+	ass.NoError(err)
+	commentID := libwekan.CommentID(comment.ID)
+
+	err = service.UpdateCardComment(ctx, "carte", commentID, "modifié", "jane.doe@zone51.gov")
+	ass.ErrorAs(err, &core.ForbiddenError{})
+	ass.NoError(service.UpdateCardComment(ctx, "carte", commentID, "modifié", "john.doe@zone51.gov"))
+	card, _ := service.SelectCardFromCardID(ctx, "carte", "john.doe@zone51.gov")
+	ass.Len(card.Comments, 1)
+	ass.Equal("modifié", card.Comments[0].Comment)
+
+	ass.NoError(service.DeleteCardComment(ctx, "carte", commentID, "john.doe@zone51.gov"))
+	card, _ = service.SelectCardFromCardID(ctx, "carte", "john.doe@zone51.gov")
+	ass.Empty(card.Comments)
+	err = service.DeleteCardComment(ctx, "carte", commentID, "john.doe@zone51.gov")
+	ass.ErrorAs(err, &core.UnknownCommentError{})
+}
+
+func Test_updateCardLabels_activities(t *testing.T) {
+	ass := assert.New(t)
+	card := libwekan.Card{ID: "carte", LabelIDs: []libwekan.BoardLabelID{"a"}}
+
+	activities := updateCardLabels(&card, []libwekan.BoardLabelID{"a", "b"}, []libwekan.BoardLabelID{"a", "c"}, "user")
+	ass.Equal([]libwekan.BoardLabelID{"b"}, card.LabelIDs)
+	ass.Equal([]string{"addedLabel", "removedLabel"}, activityTypes(activities))
+	ass.Equal(libwekan.BoardLabelID("b"), activities[0].BoardLabelID)
+	ass.Equal(libwekan.BoardLabelID("a"), activities[1].BoardLabelID)
+}
@@ -0,0 +1,59 @@
+package kanban
+
+import (
+	"datapi/pkg/test/factory"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func boardWithLabels(names ...libwekan.BoardLabelName) libwekan.ConfigBoard {
+	configBoard := factory.OneConfigBoardWithMembers(factory.OneWekanUser())
+	configBoard.Board.Labels = nil
+	for _, name := range names {
+		configBoard.Board.Labels = append(configBoard.Board.Labels, libwekan.BoardLabel{
+			ID:   libwekan.BoardLabelID("label-" + name),
+			Name: name,
+		})
+	}
+	return configBoard
+}
+
+func cardWithLabels(board libwekan.ConfigBoard, labelIDs ...libwekan.BoardLabelID) libwekan.CardWithComments {
+	card := libwekan.BuildCard(board.Board.ID, "", "", "titre", "", "")
+	card.LabelIDs = labelIDs
+	return libwekan.CardWithComments{Card: card}
+}
+
+func Test_cardMatchesLabels_and(t *testing.T) {
+	ass := assert.New(t)
+	board := boardWithLabels("CRP", "CODEFI", "ARCHIVE")
+	wc := factory.LibwekanConfigWith([]libwekan.ConfigBoard{board}, nil)
+	test := cardMatchesLabels([]libwekan.BoardLabelName{"CRP", "CODEFI"}, "and", wc)
+
+	ass.True(test(cardWithLabels(board, "label-CRP", "label-CODEFI", "label-ARCHIVE")))
+	ass.False(test(cardWithLabels(board, "label-CRP")))
+	ass.False(test(cardWithLabels(board)))
+}
+
+func Test_cardMatchesLabels_or(t *testing.T) {
+	ass := assert.New(t)
+	board := boardWithLabels("CRP", "CODEFI")
+	wc := factory.LibwekanConfigWith([]libwekan.ConfigBoard{board}, nil)
+	test := cardMatchesLabels([]libwekan.BoardLabelName{"CRP", "inconnu"}, "or", wc)
+
+	ass.True(test(cardWithLabels(board, "label-CRP")))
+	ass.False(test(cardWithLabels(board, "label-CODEFI")))
+}
+
+func Test_listIDsWithTitles_neverNil(t *testing.T) {
+	ass := assert.New(t)
+	board := factory.OneConfigBoardWithMembers()
+	list := libwekan.BuildList(board.Board.ID, "Accompagnement en cours", 1)
+	board.Lists = map[libwekan.ListID]libwekan.List{list.ID: list}
+	wc := factory.LibwekanConfigWith([]libwekan.ConfigBoard{board}, nil)
+
+	ass.Equal([]libwekan.ListID{list.ID}, listIDsWithTitles(wc, []string{"Accompagnement en cours"}))
+	ass.NotNil(listIDsWithTitles(wc, []string{"Accompagnement terminé"}))
+	ass.Empty(listIDsWithTitles(wc, []string{"Accompagnement terminé"}))
+}
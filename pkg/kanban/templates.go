@@ -0,0 +1,115 @@
+package kanban
+
+import (
+	"datapi/pkg/core"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/spf13/viper"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// CardTemplate modèle des cartes créées sur le tableau Board, déclaré dans `cardTemplates`,
+// le modèle sans tableau s'applique aux tableaux qui n'ont pas le leur.
+// Description est un squelette text/template qui reçoit les données de l'établissement (core.EtablissementData),
+// par exemple `{{.RaisonSociale}}` ou `{{.Effectif}}`.
+type CardTemplate struct {
+	Board       libwekan.BoardSlug        `mapstructure:"board"`
+	Description string                    `mapstructure:"description"`
+	Labels      []libwekan.BoardLabelName `mapstructure:"labels"`
+	Checklists  []CardTemplateChecklist   `mapstructure:"checklists"`
+}
+
+// CardTemplateChecklist liste de contrôle ajoutée aux cartes créées avec le modèle
+type CardTemplateChecklist struct {
+	Title string   `mapstructure:"title"`
+	Items []string `mapstructure:"items"`
+}
+
+// defaultCardTemplate modèle utilisé lorsqu'aucun modèle n'est configuré
+var defaultCardTemplate = CardTemplate{Description: "inscrire ici les informations de cet accompagnement"}
+
+// cardChecklist liste de contrôle d'une carte, enregistrée avec ses éléments
+type cardChecklist struct {
+	ID        string
+	CardID    libwekan.CardID
+	UserID    libwekan.UserID
+	Title     string
+	Sort      float64
+	CreatedAt time.Time
+	Items     []cardChecklistItem
+}
+
+type cardChecklistItem struct {
+	ID    string
+	Title string
+	Sort  float64
+}
+
+// cardTemplateForBoard retourne le modèle du tableau, à défaut le modèle sans tableau puis defaultCardTemplate
+func cardTemplateForBoard(slug libwekan.BoardSlug) (CardTemplate, error) {
+	var cardTemplates []CardTemplate
+	if err := viper.UnmarshalKey("cardTemplates", &cardTemplates); err != nil {
+		return CardTemplate{}, err
+	}
+	cardTemplate := defaultCardTemplate
+	for _, t := range cardTemplates {
+		if t.Board == slug {
+			return t, nil
+		}
+		if t.Board == "" {
+			cardTemplate = t
+		}
+	}
+	return cardTemplate, nil
+}
+
+// description rend le squelette du modèle lorsque la description demandée est vide
+func (cardTemplate CardTemplate) description(description string, etablissement core.EtablissementData) (string, error) {
+	if description != "" || cardTemplate.Description == "" {
+		return description, nil
+	}
+	skeleton, err := template.New("description").Parse(cardTemplate.Description)
+	if err != nil {
+		return "", fmt.Errorf("modèle de carte du tableau %s : %w", cardTemplate.Board, err)
+	}
+	var rendered strings.Builder
+	if err := skeleton.Execute(&rendered, etablissement); err != nil {
+		return "", fmt.Errorf("modèle de carte du tableau %s : %w", cardTemplate.Board, err)
+	}
+	return rendered.String(), nil
+}
+
+// labels ajoute les étiquettes du modèle aux étiquettes demandées
+func (cardTemplate CardTemplate) labels(labels []libwekan.BoardLabelName) []libwekan.BoardLabelName {
+	for _, label := range cardTemplate.Labels {
+		if !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// checklists prépare les listes de contrôle du modèle pour la carte créée par user
+func (cardTemplate CardTemplate) checklists(card libwekan.Card, user libwekan.User) []cardChecklist {
+	var checklists []cardChecklist
+	for i, checklist := range cardTemplate.Checklists {
+		items := make([]cardChecklistItem, 0, len(checklist.Items))
+		for j, title := range checklist.Items {
+			items = append(items, cardChecklistItem{ID: uuid.NewString(), Title: title, Sort: float64(j)})
+		}
+		checklists = append(checklists, cardChecklist{
+			ID:        uuid.NewString(),
+			CardID:    card.ID,
+			UserID:    user.ID,
+			Title:     checklist.Title,
+			Sort:      float64(i),
+			CreatedAt: card.CreatedAt,
+			Items:     items,
+		})
+	}
+	return checklists
+}
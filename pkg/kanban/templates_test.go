@@ -0,0 +1,76 @@
+package kanban
+
+import (
+	"datapi/pkg/core"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_cardTemplateForBoard(t *testing.T) {
+	ass := assert.New(t)
+	viper.Set("cardTemplates", []map[string]any{
+		{"board": "tableau-crp-bfc", "description": "BFC", "labels": []string{"CRP"}},
+		{"description": "défaut"},
+	})
+	defer viper.Set("cardTemplates", nil)
+
+	cardTemplate, err := cardTemplateForBoard("tableau-crp-bfc")
+	require.NoError(t, err)
+	ass.Equal("BFC", cardTemplate.Description)
+	ass.Equal([]libwekan.BoardLabelName{"CRP"}, cardTemplate.Labels)
+
+	cardTemplate, err = cardTemplateForBoard("tableau-crp-ara")
+	require.NoError(t, err)
+	ass.Equal("défaut", cardTemplate.Description)
+}
+
+func Test_cardTemplateForBoard_withoutConfig(t *testing.T) {
+	ass := assert.New(t)
+	cardTemplate, err := cardTemplateForBoard("tableau-crp-bfc")
+	require.NoError(t, err)
+	ass.Equal(defaultCardTemplate, cardTemplate)
+}
+
+func Test_CardTemplate_description(t *testing.T) {
+	ass := assert.New(t)
+	cardTemplate := CardTemplate{Description: "# {{.RaisonSociale}} ({{.Siret}})\n- effectif : {{.Effectif}}\n- contexte :"}
+	etablissement := core.EtablissementData{Siret: "12345678900011", RaisonSociale: "ENTREPRISE", Effectif: 42}
+
+	description, err := cardTemplate.description("", etablissement)
+	ass.NoError(err)
+	ass.Equal("# ENTREPRISE (12345678900011)\n- effectif : 42\n- contexte :", description)
+
+	description, err = cardTemplate.description("description saisie", etablissement)
+	ass.NoError(err)
+	ass.Equal("description saisie", description)
+
+	_, err = CardTemplate{Description: "{{.Inconnu}}"}.description("", etablissement)
+	ass.Error(err)
+}
+
+func Test_CardTemplate_labelsAndChecklists(t *testing.T) {
+	ass := assert.New(t)
+	cardTemplate := CardTemplate{
+		Labels: []libwekan.BoardLabelName{"CRP", "procol"},
+		Checklists: []CardTemplateChecklist{
+			{Title: "diagnostic", Items: []string{"prise de contact", "visite"}},
+			{Title: "clôture"},
+		},
+	}
+	ass.Equal([]libwekan.BoardLabelName{"CRP", "procol"}, cardTemplate.labels([]libwekan.BoardLabelName{"CRP"}))
+
+	card := libwekan.Card{ID: "carte", CreatedAt: time.Now()}
+	checklists := cardTemplate.checklists(card, libwekan.User{ID: "john"})
+	require.Len(t, checklists, 2)
+	ass.Equal(libwekan.CardID("carte"), checklists[0].CardID)
+	ass.Equal(libwekan.UserID("john"), checklists[0].UserID)
+	ass.Equal("diagnostic", checklists[0].Title)
+	require.Len(t, checklists[0].Items, 2)
+	ass.Equal("visite", checklists[0].Items[1].Title)
+	ass.Equal(float64(1), checklists[1].Sort)
+	ass.Empty(checklists[1].Items)
+}
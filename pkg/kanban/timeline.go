@@ -0,0 +1,88 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"github.com/signaux-faibles/libwekan"
+	"slices"
+)
+
+// timelineActivityTypes activités wekan reprises dans l'historique de la carte
+var timelineActivityTypes = []string{
+	"createCard", "moveCard", "addedLabel", "removedLabel",
+	"addComment", "editComment", "deleteComment",
+	"joinMember", "unjoinMember", "joinAssignee", "unjoinAssignee",
+	"archivedCard", "restoredCard",
+}
+
+func (service wekanService) GetCardTimeline(ctx context.Context, card core.KanbanCard) ([]core.KanbanTimelineEvent, error) {
+	activities, err := wekan.SelectActivitiesFromCardID(ctx, card.ID)
+	if err != nil {
+		return nil, err
+	}
+	return activitiesToTimelineEvents(card, activities, getWekanConfig()), nil
+}
+
+func (service storeService) GetCardTimeline(ctx context.Context, card core.KanbanCard) ([]core.KanbanTimelineEvent, error) {
+	activities, err := service.store.selectActivities(ctx, card.ID)
+	if err != nil {
+		return nil, err
+	}
+	return activitiesToTimelineEvents(card, activities, getWekanConfig()), nil
+}
+
+// activitiesToTimelineEvents traduit les activités de la carte en évènements lisibles,
+// les identifiants de listes, d'étiquettes et d'utilisateurs sont résolus avec wc
+func activitiesToTimelineEvents(card core.KanbanCard, activities []libwekan.Activity, wc libwekan.Config) []core.KanbanTimelineEvent {
+	board := wc.Boards[card.BoardID]
+	var events []core.KanbanTimelineEvent
+	for _, activity := range activities {
+		if !slices.Contains(timelineActivityTypes, activity.ActivityType) {
+			continue
+		}
+		event := core.KanbanTimelineEvent{
+			Date:     activity.CreatedAt,
+			Source:   "kanban",
+			Type:     activity.ActivityType,
+			Username: string(wc.Users[activity.UserID].Username),
+		}
+		switch activity.ActivityType {
+		case "createCard":
+			event.Detail = board.Lists[activity.ListID].Title
+		case "moveCard":
+			event.Detail = board.Lists[activity.OldListID].Title + " → " + board.Lists[activity.ListID].Title
+		case "addedLabel", "removedLabel":
+			event.Detail = string(boardLabelName(board, activity.BoardLabelID))
+		case "addComment", "editComment":
+			event.Detail = commentText(card, activity.CommentID)
+		case "joinMember", "unjoinMember", "joinAssignee", "unjoinAssignee":
+			// wekan renseigne assigneeId pour les assignés, le stockage postgres utilise memberId dans les deux cas
+			memberID := activity.MemberID
+			if memberID == "" {
+				memberID = activity.AssigneeID
+			}
+			event.Detail = string(wc.Users[memberID].Username)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func boardLabelName(board libwekan.ConfigBoard, labelID libwekan.BoardLabelID) libwekan.BoardLabelName {
+	for _, label := range board.Board.Labels {
+		if label.ID == labelID {
+			return label.Name
+		}
+	}
+	return ""
+}
+
+// commentText retourne le texte actuel du commentaire, vide si le commentaire a été supprimé
+func commentText(card core.KanbanCard, commentID libwekan.CommentID) string {
+	for _, comment := range card.Comments {
+		if comment.ID == string(commentID) {
+			return comment.Comment
+		}
+	}
+	return ""
+}
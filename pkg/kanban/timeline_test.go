@@ -0,0 +1,51 @@
+package kanban
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_storeService_GetCardTimeline(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	service, err := NewMemoryService(oneMemoryFixture(t))
+	require.NoError(t, err)
+	john, _ := service.GetUser("john.doe@zone51.gov")
+
+	require.NoError(t, service.JoinCard(ctx, "carte", john))
+	_, err = service.AddCardComment(ctx, "carte", "premier contact", john.Username)
+	require.NoError(t, err)
+	card, err := service.SelectCardFromCardID(ctx, "carte", john.Username)
+	require.NoError(t, err)
+
+	events, err := service.GetCardTimeline(ctx, card)
+	ass.NoError(err)
+	var details []string
+	for _, event := range events {
+		ass.Equal("kanban", event.Source)
+		ass.Equal("john.doe@zone51.gov", event.Username)
+		details = append(details, event.Type+" "+event.Detail)
+	}
+	ass.Equal([]string{
+		"joinMember john.doe@zone51.gov",
+		"moveCard A définir → Accompagnement en cours",
+		"addComment premier contact",
+	}, details)
+}
+
+func Test_activitiesToTimelineEvents_wekanAssignee(t *testing.T) {
+	ass := assert.New(t)
+	wc := libwekan.Config{Users: map[libwekan.UserID]libwekan.User{"jane": {ID: "jane", Username: "jane.doe@zone51.gov"}}}
+	activities := []libwekan.Activity{
+		{ActivityType: "joinAssignee", AssigneeID: "jane"},
+		{ActivityType: "a-definedCustomField"},
+	}
+
+	events := activitiesToTimelineEvents(core.KanbanCard{}, activities, wc)
+	ass.Len(events, 1)
+	ass.Equal("jane.doe@zone51.gov", events[0].Detail)
+}
@@ -3,19 +3,23 @@ package kanban
 import (
 	"context"
 	"datapi/pkg/core"
+	"errors"
+	"github.com/google/uuid"
 	"github.com/signaux-faibles/libwekan"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"log/slog"
 	"strings"
-	"sync"
 	"time"
 )
 
 var wekan libwekan.Wekan
-var WekanConfig libwekan.Config
-var wekanConfigMutex = &sync.Mutex{}
+
+// wekanDB base wekan des collections que libwekan ne gère pas, comme les listes de contrôle et les commentaires
+var wekanDB *mongo.Database
 
 type wekanService struct{}
 
@@ -32,9 +36,8 @@ func (service wekanService) SelectCardsFromSiret(ctx context.Context, siret stri
 }
 
 func GetUser(username libwekan.Username) (libwekan.User, bool) {
-	wekanConfigMutex.Lock()
-	defer wekanConfigMutex.Unlock()
-	return WekanConfig.GetUserByUsername(username)
+	wc := getWekanConfig()
+	return wc.GetUserByUsername(username)
 }
 
 func InitService(ctx context.Context, dBURL, dBName, admin, slugDomainRegexp string) core.KanbanService {
@@ -43,14 +46,187 @@ func InitService(ctx context.Context, dBURL, dBName, admin, slugDomainRegexp str
 	if err != nil {
 		log.Printf("Erreur lors de l'initialisation de wekan : %s", err)
 	}
-	go watchWekanConfig(time.Minute)
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dBURL))
+	if err != nil {
+		log.Printf("Erreur lors de la connexion à la base wekan : %s", err)
+	} else {
+		wekanDB = client.Database(dBName)
+	}
+	go watchKanbanConfig(ctx, time.Minute, loadWekanConfig, wekanConfigWatcher(dBURL, dBName))
 	return wekanService{}
 }
 
+// wekanConfigCollections collections lues par libwekan pour construire la configuration
+var wekanConfigCollections = []string{"boards", "swimlanes", "lists", "customFields", "users"}
+
+// wekanConfigWatcher suit les changements des collections de la configuration avec les change streams mongodb,
+// libwekan ne partageant pas sa connexion, le suivi utilise son propre client
+func wekanConfigWatcher(dBURL, dBName string) configWatcher {
+	return func(ctx context.Context, notify func()) error {
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(dBURL))
+		if err != nil {
+			return err
+		}
+		defer client.Disconnect(context.Background())
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$in": wekanConfigCollections}}}}}
+		stream, err := client.Database(dBName).Watch(ctx, pipeline)
+		if err != nil {
+			return err
+		}
+		defer stream.Close(context.Background())
+		notify()
+		for stream.Next(ctx) {
+			// les changements arrivent souvent par lots, un seul rechargement suffit pour ceux déjà reçus
+			for stream.TryNext(ctx) {
+			}
+			notify()
+		}
+		return stream.Err()
+	}
+}
+
+// insertWekanChecklists enregistre les listes de contrôle et leurs éléments dans les collections de wekan
+func insertWekanChecklists(ctx context.Context, checklists []cardChecklist) error {
+	if len(checklists) == 0 {
+		return nil
+	}
+	if wekanDB == nil {
+		return errors.New("la base wekan n'est pas connectée, impossible d'ajouter les listes de contrôle")
+	}
+	var documents, items []any
+	for _, checklist := range checklists {
+		createdAt := primitive.NewDateTimeFromTime(checklist.CreatedAt)
+		documents = append(documents, bson.M{
+			"_id":        checklist.ID,
+			"cardId":     checklist.CardID,
+			"userId":     checklist.UserID,
+			"title":      checklist.Title,
+			"sort":       checklist.Sort,
+			"createdAt":  createdAt,
+			"modifiedAt": createdAt,
+		})
+		for _, item := range checklist.Items {
+			items = append(items, bson.M{
+				"_id":         item.ID,
+				"checklistId": checklist.ID,
+				"cardId":      checklist.CardID,
+				"userId":      checklist.UserID,
+				"title":       item.Title,
+				"sort":        item.Sort,
+				"isFinished":  false,
+				"createdAt":   createdAt,
+				"modifiedAt":  createdAt,
+			})
+		}
+	}
+	if _, err := wekanDB.Collection("checklists").InsertMany(ctx, documents); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := wekanDB.Collection("checklistItems").InsertMany(ctx, items)
+	return err
+}
+
+// deleteWekanChecklists supprime les listes de contrôle de la carte et leurs éléments
+func deleteWekanChecklists(ctx context.Context, cardID libwekan.CardID) error {
+	for _, name := range []string{"checklistItems", "checklists"} {
+		collection, err := wekanCollection(name)
+		if err != nil {
+			return err
+		}
+		if _, err := collection.DeleteMany(ctx, bson.M{"cardId": cardID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wekanCollection retourne une collection de la base wekan, pour les écritures que libwekan ne propose pas
+func wekanCollection(name string) (*mongo.Collection, error) {
+	if wekanDB == nil {
+		return nil, errors.New("la base wekan n'est pas connectée")
+	}
+	return wekanDB.Collection(name), nil
+}
+
+// insertWekanActivity enregistre l'activité dans le journal de wekan
+func insertWekanActivity(ctx context.Context, activity libwekan.Activity) error {
+	activities, err := wekanCollection("activities")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	activity.ID = libwekan.ActivityID(uuid.NewString())
+	activity.CreatedAt = now
+	activity.ModifiedAt = now
+	_, err = activities.InsertOne(ctx, activity)
+	return err
+}
+
+// pullWekanCardLabel retire l'étiquette de la carte, removed est faux si la carte ne l'avait pas
+func pullWekanCardLabel(ctx context.Context, cardID libwekan.CardID, labelID libwekan.BoardLabelID) (removed bool, err error) {
+	cards, err := wekanCollection("cards")
+	if err != nil {
+		return false, err
+	}
+	result, err := cards.UpdateOne(ctx,
+		bson.M{"_id": cardID},
+		bson.M{"$pull": bson.M{"labelIds": labelID}, "$set": bson.M{"modifiedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// selectWekanComment retourne le commentaire de la carte
+func selectWekanComment(ctx context.Context, cardID libwekan.CardID, commentID libwekan.CommentID) (libwekan.Comment, error) {
+	comments, err := wekanCollection("card_comments")
+	if err != nil {
+		return libwekan.Comment{}, err
+	}
+	var comment libwekan.Comment
+	err = comments.FindOne(ctx, bson.M{"_id": commentID, "cardId": cardID}).Decode(&comment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return libwekan.Comment{}, core.UnknownCommentError{CommentIdentifier: "commentID=" + string(commentID)}
+	}
+	return comment, err
+}
+
+func insertWekanComment(ctx context.Context, comment libwekan.Comment) error {
+	comments, err := wekanCollection("card_comments")
+	if err != nil {
+		return err
+	}
+	_, err = comments.InsertOne(ctx, comment)
+	return err
+}
+
+func updateWekanComment(ctx context.Context, comment libwekan.Comment) error {
+	comments, err := wekanCollection("card_comments")
+	if err != nil {
+		return err
+	}
+	_, err = comments.UpdateOne(ctx,
+		bson.M{"_id": comment.ID},
+		bson.M{"$set": bson.M{"text": comment.Text, "modifiedAt": comment.ModifiedAt}},
+	)
+	return err
+}
+
+func deleteWekanComment(ctx context.Context, comment libwekan.Comment) error {
+	comments, err := wekanCollection("card_comments")
+	if err != nil {
+		return err
+	}
+	_, err = comments.DeleteOne(ctx, bson.M{"_id": comment.ID})
+	return err
+}
+
 func kanbanConfigForUser(username libwekan.Username) core.KanbanConfig {
-	wekanConfigMutex.Lock()
-	config := WekanConfig.Copy()
-	wekanConfigMutex.Unlock()
+	config := getWekanConfig()
 	var kanbanConfig core.KanbanConfig
 	for wekanUserID, wekanUser := range config.Users {
 		if wekanUser.Username == username {
@@ -231,5 +407,5 @@ func buildCardsFromListeAndDomainRegexpPipeline(wekanDomainRegexp string, liste
 }
 
 func (service wekanService) GetWekanConfig() libwekan.Config {
-	return WekanConfig
+	return getWekanConfig()
 }
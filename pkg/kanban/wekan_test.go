@@ -39,10 +39,10 @@ func Test_kanbanConfigForUser_withActiveMembers(t *testing.T) { // GIVEN
 	configBoardB := factory.OneConfigBoardWithMembers(userOne, userThree)
 
 	// set up WekanConfig for test
-	WekanConfig = factory.LibwekanConfigWith(
+	publishWekanConfig(factory.LibwekanConfigWith(
 		[]libwekan.ConfigBoard{configBoardA, configBoardB},
 		[]libwekan.User{userOne, userTwo, userThree},
-	)
+	))
 
 	// WHEN
 	configForUserOne := kanbanConfigForUser(userOne.Username)
@@ -73,10 +73,10 @@ func Test_kanbanConfigForUser_assertThatAllUsersArePresents(t *testing.T) { // G
 	factory.DeactiveMembers(configBoardB, userLambda3)
 
 	// set up WekanConfig for test
-	WekanConfig = factory.LibwekanConfigWith(
+	publishWekanConfig(factory.LibwekanConfigWith(
 		[]libwekan.ConfigBoard{configBoardA, configBoardB},
 		[]libwekan.User{userOne, userLambda1, userLambda2, userLambda3},
-	)
+	))
 
 	// WHEN
 	configForUserOne := kanbanConfigForUser(userOne.Username)
@@ -105,10 +105,10 @@ func Test_kanbanConfigForUser_withInactiveMembers(t *testing.T) { // GIVEN
 	factory.DeactiveMembers(configBoardA, userOne)
 
 	// set up WekanConfig for test
-	WekanConfig = factory.LibwekanConfigWith(
+	publishWekanConfig(factory.LibwekanConfigWith(
 		[]libwekan.ConfigBoard{configBoardA},
 		[]libwekan.User{userOne},
-	)
+	))
 
 	// WHEN
 	configForUserOne := kanbanConfigForUser(userOne.Username)
@@ -151,10 +151,10 @@ func Test_kanbanConfigForUser_hasOnlyDepartementsFromSwimlanes(t *testing.T) {
 			factory.AddSwimlanesWithDepartments(&configGenerale, tt.args...)
 
 			// set up WekanConfig for t
-			WekanConfig = factory.LibwekanConfigWith(
+			publishWekanConfig(factory.LibwekanConfigWith(
 				[]libwekan.ConfigBoard{configGenerale},
 				[]libwekan.User{user},
-			)
+			))
 			// WHEN
 			configForUserOne := kanbanConfigForUser(user.Username)
 			actual := utils.GetKeys(configForUserOne.Departements)
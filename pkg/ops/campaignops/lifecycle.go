@@ -0,0 +1,132 @@
+package campaignops
+
+import (
+	"datapi/pkg/campaign"
+	"datapi/pkg/db"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type cloneCampaignParams struct {
+	Libelle string    `json:"libelle"`
+	DateFin time.Time `json:"dateFin"`
+}
+
+func campaignIDParam(c *gin.Context) (campaign.CampaignID, bool) {
+	campaignID, err := strconv.Atoi(c.Param("campaignID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "le parametre campaignID doit être un entier")
+		return 0, false
+	}
+	return campaign.CampaignID(campaignID), true
+}
+
+func abortWithCampaignError(c *gin.Context, err error) {
+	if errors.As(err, &campaign.CampaignNotFoundError{}) {
+		c.JSON(http.StatusNotFound, err.Error())
+	} else if errors.As(err, &campaign.CampaignNotEmptyError{}) {
+		c.JSON(http.StatusConflict, err.Error())
+	} else if errors.As(err, &campaign.IllegalTransitionError{}) {
+		c.JSON(http.StatusUnprocessableEntity, err.Error())
+	} else {
+		c.JSON(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func createCampaignHandler(c *gin.Context) {
+	var params campaign.NewCampaign
+	err := c.Bind(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	err = params.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	campaignID, err := campaign.Insert(c, db.Get(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, campaignID)
+}
+
+func updateCampaignHandler(c *gin.Context) {
+	campaignID, ok := campaignIDParam(c)
+	if !ok {
+		return
+	}
+	var params campaign.CampaignUpdate
+	err := c.Bind(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	err = params.Validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	err = campaign.Update(c, campaignID, params)
+	if err != nil {
+		abortWithCampaignError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+func setStatusHandler(status campaign.Status) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		campaignID, ok := campaignIDParam(c)
+		if !ok {
+			return
+		}
+		err := campaign.SetStatus(c, campaignID, status)
+		if err != nil {
+			abortWithCampaignError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+func cloneCampaignHandler(c *gin.Context) {
+	campaignID, ok := campaignIDParam(c)
+	if !ok {
+		return
+	}
+	var params cloneCampaignParams
+	err := c.Bind(&params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.Libelle == "" || params.DateFin.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, "libelle obligatoire et dateFin dans le futur")
+		return
+	}
+	cloneID, err := campaign.Clone(c, campaignID, params.Libelle, params.DateFin)
+	if err != nil {
+		abortWithCampaignError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, cloneID)
+}
+
+func deleteCampaignHandler(c *gin.Context) {
+	campaignID, ok := campaignIDParam(c)
+	if !ok {
+		return
+	}
+	err := campaign.Delete(c, campaignID)
+	if err != nil {
+		abortWithCampaignError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
@@ -21,6 +21,15 @@ import (
 func ConfigureEndpoint(kanbanService core.KanbanService) func(endpoint *gin.RouterGroup) {
 	return func(endpoint *gin.RouterGroup) {
 		endpoint.POST("/new", newCampaignHandler(kanbanService))
+		endpoint.POST("/plan", planCampaignHandler(kanbanService))
+		endpoint.PUT("/workflow/:campaignID", updateWorkflowHandler)
+		endpoint.POST("/create", createCampaignHandler)
+		endpoint.POST("/update/:campaignID", updateCampaignHandler)
+		endpoint.POST("/close/:campaignID", setStatusHandler(campaign.StatusClosed))
+		endpoint.POST("/reopen/:campaignID", setStatusHandler(campaign.StatusOpen))
+		endpoint.POST("/archive/:campaignID", setStatusHandler(campaign.StatusArchived))
+		endpoint.POST("/clone/:campaignID", cloneCampaignHandler)
+		endpoint.DELETE("/:campaignID", deleteCampaignHandler)
 	}
 }
 
@@ -37,6 +46,7 @@ type newCampaignSirets struct {
 	fromListe                      []core.Siret
 	fromCampaignReports            []campaignAction
 	fromCampaignEncours            []campaignAction
+	workflow                       campaign.Workflow
 }
 
 type campaignAction struct {
@@ -71,18 +81,17 @@ func deleteCampaignActionFromSiretsSlice(sirets []core.Siret) func(campaignActio
 	}
 }
 
-func deleteCampaignReportDelai3mois(action campaignAction) bool {
-	return action.actionDetail == "delai_3mois"
-}
-
-func updateCampaignReport(action campaignAction) campaignAction {
-	switch action.actionDetail {
-	case "delai_9mois":
-		action.actionDetail = "delai_6mois"
-	case "delai_6mois":
-		action.actionDetail = "delai_3mois"
+// rollOverReports applique les règles de report du workflow aux actions `withdraw`
+func rollOverReports(workflow campaign.Workflow, actions []campaignAction) []campaignAction {
+	var reports []campaignAction
+	for _, action := range actions {
+		detail, keep := workflow.RollOver("withdraw", action.actionDetail)
+		if keep {
+			action.actionDetail = detail
+			reports = append(reports, action)
+		}
 	}
-	return action
+	return reports
 }
 
 func siretWithNameFunc(config libwekan.Config) func(card libwekan.Card) core.Siret {
@@ -105,22 +114,20 @@ func (n newCampaignSirets) siretsToInsert() []core.Siret {
 
 	campaignReports := utils.Convert(n.fromCampaignReports, campaignAction.getSiret)
 	campaignEnCours := utils.Convert(n.fromCampaignEncours, campaignAction.getSiret)
-	siretsToInsert = append(n.fromListe, campaignReports...)
+	siretsToInsert = append(slices.Clone(n.fromListe), campaignReports...)
 	siretsToInsert = append(siretsToInsert, campaignEnCours...)
 	return slices.DeleteFunc(siretsToInsert, deleteSiretsFromSiretsSlice(n.fromWekanAccompagnementEnCoursSirets()))
 }
 
 func (n newCampaignSirets) campaignEnCoursToInsert() []campaignAction {
 	// encours (campaign) - accompagnement en cours (wekan)
-	return slices.DeleteFunc(n.fromCampaignEncours, deleteCampaignActionFromSiretsSlice(n.fromWekanAccompagnementEnCoursSirets()))
+	return slices.DeleteFunc(slices.Clone(n.fromCampaignEncours), deleteCampaignActionFromSiretsSlice(n.fromWekanAccompagnementEnCoursSirets()))
 }
 
 func (n newCampaignSirets) campaignReportsToInsert() []campaignAction {
 	// reports (campaign) - accompagnement en cours (wekan)
-	reports := slices.DeleteFunc(n.fromCampaignReports, deleteCampaignActionFromSiretsSlice(n.fromWekanAccompagnementEnCoursSirets()))
-	reports = slices.DeleteFunc(reports, deleteCampaignReportDelai3mois)
-	reports = utils.Convert(reports, updateCampaignReport)
-	return reports
+	reports := slices.DeleteFunc(slices.Clone(n.fromCampaignReports), deleteCampaignActionFromSiretsSlice(n.fromWekanAccompagnementEnCoursSirets()))
+	return rollOverReports(n.workflow, reports)
 }
 
 func (n newCampaignSirets) wekanAnalyseEnCoursToMutatePasDAccompagnement() []core.Siret {
@@ -133,7 +140,6 @@ func newCampaignHandler(kanbanService core.KanbanService) func(c *gin.Context) {
 		var params newCampaignParams
 
 		err := c.Bind(&params)
-		fmt.Println(params)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, err.Error())
 			return
@@ -156,67 +162,148 @@ func newCampaignHandler(kanbanService core.KanbanService) func(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, err.Error())
 			return
 		}
-		// - création de la nouvelle campagne (titre calculé à partir du nom de la liste)
-		campaignID, err := campaign.Create(
-			c,
-			"Campagne de prise de contact "+params.FromListeDetection,
-			wekanDomainRegexp,
-			params.DateFin,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, err.Error())
-			return
-		}
 
-		// - insertion des sirets (sauf ceux qui sont accompagnement en cours) ->
-		_, err = campaign.AddSirets(c, campaignID, sirets.siretsToInsert(), "signaux.faibles")
+		campaignID, err := applyNewCampaign(c, kanbanService, params, wekanDomainRegexp, sirets)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, err.Error())
 			return
 		}
+		c.JSON(http.StatusCreated, campaignID)
+	}
+}
 
-		// - insertion des actions `take` (les gens qui avaient des entreprises en cours de contact les conservent dans la campagne d'après) (sauf sirets qui sont accompagnement en cours)
-		err = campaignTakeSiretUnsafe(c, campaignID, sirets.campaignEnCoursToInsert())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, err.Error())
-			return
-		}
+func newCampaignTitle(params newCampaignParams) string {
+	return "Campagne de prise de contact " + params.FromListeDetection
+}
 
-		// - insertion des actions `report` (avec un décallage des reports de 3 mois) (sauf sirets qui sont accompagnement en cours)
-		err = campaignReportSiretUnsafe(c, campaignID, sirets.campaignReportsToInsert())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, err.Error())
-			return
-		}
+// applyNewCampaign crée la campagne dans une transaction, les déplacements de cartes wekan
+// sont annulés si la transaction n'aboutit pas
+func applyNewCampaign(
+	ctx context.Context,
+	kanbanService core.KanbanService,
+	params newCampaignParams,
+	wekanDomainRegexp string,
+	sirets newCampaignSirets,
+) (campaign.CampaignID, error) {
+	tx, err := db.Get().Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// - création de la nouvelle campagne (titre calculé à partir du nom de la liste)
+	campaignID, err := campaign.Create(ctx, tx, newCampaignTitle(params), wekanDomainRegexp, params.DateFin)
+	if err != nil {
+		return 0, err
+	}
+
+	// - la nouvelle campagne reprend le workflow de la campagne précédente
+	err = campaign.SetWorkflow(ctx, tx, campaignID, sirets.workflow)
+	if err != nil {
+		return 0, err
+	}
+
+	// - insertion des sirets (sauf ceux qui sont accompagnement en cours) ->
+	_, err = campaign.AddSirets(ctx, tx, campaignID, sirets.siretsToInsert(), "signaux.faibles")
+	if err != nil {
+		return 0, err
+	}
+
+	// - insertion des actions `take` (les gens qui avaient des entreprises en cours de contact les conservent dans la campagne d'après) (sauf sirets qui sont accompagnement en cours)
+	err = campaignTakeSiretUnsafe(ctx, tx, campaignID, sirets.campaignEnCoursToInsert())
+	if err != nil {
+		return 0, err
+	}
+
+	// - insertion des actions `report` (avec un décallage des reports de 3 mois) (sauf sirets qui sont accompagnement en cours)
+	err = campaignReportSiretUnsafe(ctx, tx, campaignID, sirets.campaignReportsToInsert())
+	if err != nil {
+		return 0, err
+	}
+
+	// - les cartes Analyse en cours qui ne sont plus dans la campagne suivante passent en `Pas d'accompagnement`
+	//      - on récupère toutes les cartes en cours d'analyse (a priori commentées dans la campagne précédentes)
+	//      - on retranche les cartes dont les sirets sont encore dans la nouvelle campagne
+	//      - on bascule toutes celles qui restent dans la liste «Pas d'accompagnement»
+	moved, err := mutatePasDAccompagnement(ctx, sirets, kanbanService)
+	if err != nil {
+		compensateMoves(ctx, moved, kanbanService)
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		compensateMoves(ctx, moved, kanbanService)
+		return 0, err
+	}
+	return campaignID, nil
+}
+
+func updateWorkflowHandler(c *gin.Context) {
+	campaignID, ok := campaignIDParam(c)
+	if !ok {
+		return
+	}
+	var workflow campaign.Workflow
+	err := c.Bind(&workflow)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	err = campaign.SetWorkflow(c, db.Get(), campaignID, workflow)
+	if errors.As(err, &campaign.InvalidWorkflowError{}) {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		abortWithCampaignError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, workflow)
+}
 
-		// - les cartes Analyse en cours qui ne sont plus dans la campagne suivante passent en `Pas d'accompagnement`
-		//      - on récupère toutes les cartes en cours d'analyse (a priori commentées dans la campagne précédentes)
-		//      - on retranche les cartes dont les sirets sont encore dans la nouvelle campagne
-		//      - on bascule toutes celles qui restent dans la liste «Pas d'accompagnement»
+const listeAnalyseEnCours = "Analyse en cours"
+const listePasDAccompagnement = "Pas d'accompagnement"
 
-		err = mutatePasDAccompagnement(c, sirets, kanbanService)
+func (n newCampaignSirets) cardsToMutatePasDAccompagnement() []libwekan.Card {
+	siretsToInsert := n.siretsToInsert()
+	return slices.DeleteFunc(slices.Clone(n.fromWekanAnalyseEnCours), func(card libwekan.Card) bool {
+		return slices.Contains(siretsToInsert, siretWithNameFunc(n.config)(card))
+	})
+}
+
+// mutatePasDAccompagnement retourne les cartes effectivement déplacées, y compris en cas d'erreur
+func mutatePasDAccompagnement(ctx context.Context, sirets newCampaignSirets, kanbanService core.KanbanService) ([]libwekan.Card, error) {
+	user, found := kanbanService.GetUser("signaux.faibles")
+	if !found {
+		return nil, errors.New("L'utilisateur 'signaux.faibles' n'a pas été trouvé ?!")
+	}
+	var moved []libwekan.Card
+	for _, card := range sirets.cardsToMutatePasDAccompagnement() {
+		err := kanbanService.MoveCardListWithTitle(ctx, card, listePasDAccompagnement, user)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, err.Error())
-			return
+			return moved, err
 		}
+		moved = append(moved, card)
 	}
+	return moved, nil
 }
 
-func mutatePasDAccompagnement(ctx context.Context, sirets newCampaignSirets, kanbanService core.KanbanService) error {
+// compensateMoves replace les cartes déplacées dans la liste «Analyse en cours»
+func compensateMoves(ctx context.Context, moved []libwekan.Card, kanbanService core.KanbanService) {
 	user, found := kanbanService.GetUser("signaux.faibles")
 	if !found {
-		return errors.New("L'utilisateur 'signaux.faibles' n'a pas été trouvé ?!")
+		slog.Error("compensation impossible, utilisateur 'signaux.faibles' absent", slog.Int("cards", len(moved)))
+		return
 	}
-	cardsToMutate := slices.DeleteFunc(sirets.fromWekanAnalyseEnCours, func(card libwekan.Card) bool {
-		return slices.Contains(sirets.siretsToInsert(), siretWithNameFunc(sirets.config)(card))
-	})
-	for _, card := range cardsToMutate {
-		err := kanbanService.MoveCardListWithTitle(ctx, card, "Pas d'accompagnement", user)
+	for _, card := range moved {
+		err := kanbanService.MoveCardListWithTitle(ctx, card, listeAnalyseEnCours, user)
 		if err != nil {
-			return err
+			slog.Error("compensation du déplacement de carte impossible",
+				slog.String("cardID", string(card.ID)),
+				slog.Any("error", err),
+			)
 		}
 	}
-	return nil
 }
 
 func selectSirets(ctx context.Context, wekanDomainRegexp string, kanbanService core.KanbanService, params newCampaignParams) (sirets newCampaignSirets, err error) {
@@ -250,6 +337,11 @@ func selectSirets(ctx context.Context, wekanDomainRegexp string, kanbanService c
 	}
 	slog.Info("sirets provenant des analyses en cours wekan", slog.Int("siretsFromAnalyseEnEcours", len(sirets.fromWekanAnalyseEnCours)))
 
+	sirets.workflow, err = campaign.GetWorkflow(ctx, params.FromCampaignID)
+	if err != nil {
+		return sirets, err
+	}
+
 	sirets.config = kanbanService.GetWekanConfig()
 
 	return sirets, nil
@@ -260,7 +352,7 @@ func selectSiretsFromAccompagnement(ctx context.Context, wekanDomainRegexp strin
 }
 
 func selectSiretsFromAnalyseEnEcours(ctx context.Context, wekanDomainRegexp string, kanbanService core.KanbanService) ([]libwekan.Card, error) {
-	return kanbanService.SelectCardsFromListeAndDomainRegexp(ctx, wekanDomainRegexp, "Analyse en cours")
+	return kanbanService.SelectCardsFromListeAndDomainRegexp(ctx, wekanDomainRegexp, listeAnalyseEnCours)
 }
 
 //go:embed sql/siretsFromAction.sql
@@ -321,8 +413,7 @@ func checkParams(ctx context.Context, params newCampaignParams) error {
 //go:embed sql/unsafeInsertCampaignTakeSiret.sql
 var unsafeInsertCampaignTakeSiret string
 
-func campaignTakeSiretUnsafe(ctx context.Context, campaignID campaign.CampaignID, actions []campaignAction) error {
-	conn := db.Get()
+func campaignTakeSiretUnsafe(ctx context.Context, conn db.Querier, campaignID campaign.CampaignID, actions []campaignAction) error {
 	for _, action := range actions {
 		_, err := conn.Exec(ctx, unsafeInsertCampaignTakeSiret, action.username, action.siret, campaignID)
 		if err != nil {
@@ -335,8 +426,7 @@ func campaignTakeSiretUnsafe(ctx context.Context, campaignID campaign.CampaignID
 //go:embed sql/unsafeInsertCampaignReportSiret.sql
 var unsafeInsertCampaignReportSiret string
 
-func campaignReportSiretUnsafe(ctx context.Context, campaignID campaign.CampaignID, actions []campaignAction) error {
-	conn := db.Get()
+func campaignReportSiretUnsafe(ctx context.Context, conn db.Querier, campaignID campaign.CampaignID, actions []campaignAction) error {
 	for _, action := range actions {
 		_, err := conn.Exec(ctx, unsafeInsertCampaignReportSiret, action.username, action.actionDetail, action.siret, campaignID)
 		if err != nil {
@@ -0,0 +1,148 @@
+package campaignops
+
+import (
+	"datapi/pkg/campaign"
+	"datapi/pkg/core"
+	"datapi/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/signaux-faibles/libwekan"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	reasonListe                 = "liste"
+	reasonReport                = "report"
+	reasonReportEchu            = "report échu"
+	reasonEnCours               = "en cours"
+	reasonAccompagnementEnCours = "accompagnement en cours"
+	actionTake                  = "take"
+	actionWithdraw              = "withdraw"
+)
+
+type plannedSiret struct {
+	Siret    core.Siret `json:"siret"`
+	Included bool       `json:"included"`
+	Reason   string     `json:"reason"`
+	Action   string     `json:"action,omitempty"`
+	Username string     `json:"username,omitempty"`
+	Detail   string     `json:"detail,omitempty"`
+}
+
+type plannedCardMove struct {
+	CardID libwekan.CardID `json:"cardID"`
+	Siret  core.Siret      `json:"siret"`
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+}
+
+type campaignPlan struct {
+	Libelle           string            `json:"libelle"`
+	WekanDomainRegexp string            `json:"wekanDomainRegexp"`
+	DateFin           time.Time         `json:"dateFin"`
+	Workflow          campaign.Workflow `json:"workflow"`
+	Sirets            []plannedSiret    `json:"sirets"`
+	CardMoves         []plannedCardMove `json:"cardMoves"`
+}
+
+// plannedSirets détaille pour chaque siret candidat la raison de son inclusion ou de son exclusion
+func (n newCampaignSirets) plannedSirets() []plannedSiret {
+	accompagnement := n.fromWekanAccompagnementEnCoursSirets()
+	planned := make([]plannedSiret, 0, len(n.fromListe)+len(n.fromCampaignReports)+len(n.fromCampaignEncours))
+
+	for _, siret := range n.fromListe {
+		if slices.Contains(accompagnement, siret) {
+			planned = append(planned, plannedSiret{Siret: siret, Reason: reasonAccompagnementEnCours})
+			continue
+		}
+		planned = append(planned, plannedSiret{Siret: siret, Included: true, Reason: reasonListe})
+	}
+
+	for _, report := range n.fromCampaignReports {
+		if slices.Contains(accompagnement, report.siret) {
+			planned = append(planned, plannedSiret{Siret: report.siret, Reason: reasonAccompagnementEnCours})
+			continue
+		}
+		detail, keep := n.workflow.RollOver(actionWithdraw, report.actionDetail)
+		if !keep {
+			// le report échu n'est pas reconduit, l'établissement redevient à traiter
+			planned = append(planned, plannedSiret{Siret: report.siret, Included: true, Reason: reasonReportEchu})
+			continue
+		}
+		planned = append(planned, plannedSiret{
+			Siret:    report.siret,
+			Included: true,
+			Reason:   reasonReport,
+			Action:   actionWithdraw,
+			Username: report.username,
+			Detail:   detail,
+		})
+	}
+
+	for _, encours := range n.fromCampaignEncours {
+		if slices.Contains(accompagnement, encours.siret) {
+			planned = append(planned, plannedSiret{Siret: encours.siret, Reason: reasonAccompagnementEnCours})
+			continue
+		}
+		planned = append(planned, plannedSiret{
+			Siret:    encours.siret,
+			Included: true,
+			Reason:   reasonEnCours,
+			Action:   actionTake,
+			Username: encours.username,
+		})
+	}
+	return planned
+}
+
+func (n newCampaignSirets) plannedCardMoves() []plannedCardMove {
+	siretOf := siretWithNameFunc(n.config)
+	return utils.Convert(n.cardsToMutatePasDAccompagnement(), func(card libwekan.Card) plannedCardMove {
+		return plannedCardMove{
+			CardID: card.ID,
+			Siret:  siretOf(card),
+			From:   listeAnalyseEnCours,
+			To:     listePasDAccompagnement,
+		}
+	})
+}
+
+// planCampaignHandler calcule la nouvelle campagne sans rien modifier dans postgres ni dans wekan
+func planCampaignHandler(kanbanService core.KanbanService) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var params newCampaignParams
+		err := c.Bind(&params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = checkParams(c, params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		wekanDomainRegexp, err := campaign.GetCampaignWekanDomainRegexp(c, params.FromCampaignID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+
+		sirets, err := selectSirets(c, wekanDomainRegexp, kanbanService, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, campaignPlan{
+			Libelle:           newCampaignTitle(params),
+			WekanDomainRegexp: wekanDomainRegexp,
+			DateFin:           params.DateFin,
+			Workflow:          sirets.workflow,
+			Sirets:            sirets.plannedSirets(),
+			CardMoves:         sirets.plannedCardMoves(),
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package campaignops
+
+import (
+	"datapi/pkg/campaign"
+	"datapi/pkg/core"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_plannedSirets_appliqueLesReglesDeReport(t *testing.T) {
+	ass := assert.New(t)
+	sirets := newCampaignSirets{
+		fromListe: []core.Siret{"11111111111111"},
+		fromCampaignReports: []campaignAction{
+			{siret: "22222222222222", actionDetail: "delai_9mois", username: "a"},
+			{siret: "33333333333333", actionDetail: "delai_3mois", username: "b"},
+		},
+		fromCampaignEncours: []campaignAction{{siret: "44444444444444", username: "c"}},
+		workflow:            campaign.DefaultWorkflow(),
+	}
+
+	planned := sirets.plannedSirets()
+
+	ass.Equal([]plannedSiret{
+		{Siret: "11111111111111", Included: true, Reason: reasonListe},
+		{Siret: "22222222222222", Included: true, Reason: reasonReport, Action: actionWithdraw, Username: "a", Detail: "delai_6mois"},
+		{Siret: "33333333333333", Included: true, Reason: reasonReportEchu},
+		{Siret: "44444444444444", Included: true, Reason: reasonEnCours, Action: actionTake, Username: "c"},
+	}, planned)
+	ass.Len(sirets.campaignReportsToInsert(), 1)
+}
+
+func Test_siretsToInsert_neModifiePasLesSources(t *testing.T) {
+	ass := assert.New(t)
+	liste := make([]core.Siret, 1, 10)
+	liste[0] = "11111111111111"
+	sirets := newCampaignSirets{
+		fromListe:           liste,
+		fromCampaignEncours: []campaignAction{{siret: "22222222222222"}},
+	}
+
+	ass.Len(sirets.siretsToInsert(), 2)
+	ass.Len(sirets.siretsToInsert(), 2)
+	ass.Equal([]core.Siret{"11111111111111"}, sirets.fromListe)
+}
@@ -0,0 +1,64 @@
+package cardrules
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/ops/scripts"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"log/slog"
+	"net/http"
+)
+
+// ConfigureEndpoint configure l'endpoint du package `cardrules`
+func ConfigureEndpoint(kanbanService core.KanbanService) func(endpoint *gin.RouterGroup) {
+	return func(endpoint *gin.RouterGroup) {
+		endpoint.GET("/dryrun", evaluateHandler(kanbanService, true))
+		endpoint.POST("/apply", evaluateHandler(kanbanService, false))
+	}
+}
+
+func evaluateHandler(kanbanService core.KanbanService, dryRun bool) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		report, err := Evaluate(c, kanbanService, dryRun)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ImportsMiddleware évalue les règles en arrière-plan après chaque import réussi
+func ImportsMiddleware(kanbanService core.KanbanService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 && c.Writer.Status() < http.StatusBadRequest {
+			Trigger(kanbanService, c.FullPath())
+		}
+	}
+}
+
+// RefreshHook évalue les règles après chaque script de refresh terminé, à enregistrer avec scripts.AddFinishHook
+func RefreshHook(kanbanService core.KanbanService) func(scripts.Script) {
+	return func(script scripts.Script) {
+		Trigger(kanbanService, script.Label)
+	}
+}
+
+// Trigger lance l'évaluation des règles dans une routine lorsque `cardRules.enabled` est vrai,
+// origin indique l'import ou le refresh à l'origine de l'évaluation
+func Trigger(kanbanService core.KanbanService, origin string) {
+	if !viper.GetBool("cardRules.enabled") {
+		return
+	}
+	go func() {
+		logger := slog.With(slog.String("origin", origin))
+		report, err := Evaluate(context.Background(), kanbanService, false)
+		if err != nil {
+			logger.Error("erreur pendant l'évaluation des règles de cartes", slog.Any("error", err))
+			return
+		}
+		logger.Info("règles de cartes évaluées", slog.Int("actions", len(report.Actions)))
+	}()
+}
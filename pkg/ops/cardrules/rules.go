@@ -0,0 +1,227 @@
+// Package cardrules applique aux cartes kanban les règles déclarées dans la configuration
+// lorsque les données de l'établissement suivi changent (procédure collective, fermeture)
+package cardrules
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/db"
+	"datapi/pkg/utils"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/spf13/viper"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+//go:embed sql/selectProcolSirets.sql
+var sqlSelectProcolSirets string
+
+//go:embed sql/selectFermetureSirets.sql
+var sqlSelectFermetureSirets string
+
+// conditions associe chaque condition de règle à la requête des sirets suivis qui la vérifient
+var conditions = map[string]string{
+	"procol":    sqlSelectProcolSirets,
+	"fermeture": sqlSelectFermetureSirets,
+}
+
+// evaluation empêche deux évaluations simultanées d'appliquer deux fois les mêmes actions
+var evaluation sync.Mutex
+
+// Rule règle déclarée dans `cardRules.rules`, appliquée aux cartes des établissements qui vérifient Condition.
+// Une carte n'est concernée que si elle n'a pas encore l'étiquette AddLabel ou n'est pas dans la liste MoveToList,
+// ce qui évite d'appliquer plusieurs fois la règle, le commentaire accompagne ces actions.
+// Une règle DryRun liste seulement les cartes concernées, le temps de la mettre au point.
+type Rule struct {
+	Name       string                  `mapstructure:"name" json:"name"`
+	Condition  string                  `mapstructure:"condition" json:"condition"`
+	Lists      []string                `mapstructure:"lists" json:"lists,omitempty"`
+	AddLabel   libwekan.BoardLabelName `mapstructure:"addLabel" json:"addLabel,omitempty"`
+	Comment    string                  `mapstructure:"comment" json:"comment,omitempty"`
+	MoveToList string                  `mapstructure:"moveToList" json:"moveToList,omitempty"`
+	DryRun     bool                    `mapstructure:"dryRun" json:"dryRun"`
+}
+
+// CardAction actions d'une règle sur une carte
+type CardAction struct {
+	Rule    string          `json:"rule"`
+	CardID  libwekan.CardID `json:"cardID"`
+	Siret   core.Siret      `json:"siret"`
+	Actions []string        `json:"actions"`
+	DryRun  bool            `json:"dryRun"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Report résultat d'une évaluation des règles
+type Report struct {
+	DryRun  bool         `json:"dryRun"`
+	Actions []CardAction `json:"actions"`
+}
+
+func (rule Rule) validate() error {
+	if rule.Name == "" {
+		return errors.New("une règle doit avoir un nom")
+	}
+	if _, ok := conditions[rule.Condition]; !ok {
+		return fmt.Errorf("règle %s : condition `%s` inconnue", rule.Name, rule.Condition)
+	}
+	if rule.AddLabel == "" && rule.MoveToList == "" {
+		return fmt.Errorf("règle %s : la règle doit ajouter une étiquette ou déplacer la carte", rule.Name)
+	}
+	return nil
+}
+
+// loadRules lit et valide les règles de la configuration
+func loadRules() ([]Rule, error) {
+	var rules []Rule
+	if err := viper.UnmarshalKey("cardRules.rules", &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// Evaluate évalue les règles de la configuration avec l'utilisateur `cardRules.username`,
+// sans modifier les cartes lorsque dryRun est vrai
+func Evaluate(ctx context.Context, kanbanService core.KanbanService, dryRun bool) (Report, error) {
+	rules, err := loadRules()
+	if err != nil {
+		return Report{}, err
+	}
+	user, ok := kanbanService.GetUser(libwekan.Username(viper.GetString("cardRules.username")))
+	if !ok {
+		return Report{}, errors.New("l'utilisateur `cardRules.username` n'est pas enregistré dans kanban")
+	}
+	sirets := make(map[string][]core.Siret)
+	for _, rule := range rules {
+		if _, ok := sirets[rule.Condition]; !ok {
+			sirets[rule.Condition], err = selectConditionSirets(ctx, conditions[rule.Condition])
+			if err != nil {
+				return Report{}, err
+			}
+		}
+	}
+	evaluation.Lock()
+	defer evaluation.Unlock()
+	return applyRules(ctx, kanbanService, rules, sirets, user, dryRun)
+}
+
+func selectConditionSirets(ctx context.Context, sql string) ([]core.Siret, error) {
+	rows, err := db.Get().Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sirets []core.Siret
+	for rows.Next() {
+		var siret core.Siret
+		if err := rows.Scan(&siret); err != nil {
+			return nil, err
+		}
+		sirets = append(sirets, siret)
+	}
+	return sirets, rows.Err()
+}
+
+// applyRules applique chaque règle aux cartes des sirets de sa condition, sur les tableaux dont user est membre
+func applyRules(ctx context.Context, kanbanService core.KanbanService, rules []Rule, sirets map[string][]core.Siret, user libwekan.User, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, Actions: []CardAction{}}
+	boards := kanbanService.SelectBoardsForUsername(user.Username)
+	boardIDs := utils.Convert(boards, func(board libwekan.ConfigBoard) libwekan.BoardID { return board.Board.ID })
+	config := kanbanService.GetWekanConfig()
+	for _, rule := range rules {
+		if len(sirets[rule.Condition]) == 0 || len(boardIDs) == 0 {
+			continue
+		}
+		cards, err := kanbanService.SelectCardsFromSiretsAndBoardIDs(ctx, sirets[rule.Condition], boardIDs, user.Username)
+		if err != nil {
+			return report, err
+		}
+		for _, card := range cards {
+			action, ok := planCardAction(rule, card, config.Boards[card.BoardID])
+			if !ok {
+				continue
+			}
+			action.DryRun = dryRun || rule.DryRun
+			if !action.DryRun {
+				action = applyCardAction(ctx, kanbanService, rule, card, user, action)
+			}
+			logCardAction(action)
+			report.Actions = append(report.Actions, action)
+		}
+	}
+	return report, nil
+}
+
+// planCardAction liste les actions de la règle qui modifieraient la carte, ok est faux si la carte n'est pas concernée
+func planCardAction(rule Rule, card core.KanbanCard, board libwekan.ConfigBoard) (CardAction, bool) {
+	action := CardAction{Rule: rule.Name, CardID: card.ID, Siret: card.Siret}
+	if card.Archived || (len(rule.Lists) > 0 && !slices.Contains(rule.Lists, card.ListTitle)) {
+		return action, false
+	}
+	if rule.AddLabel != "" && !cardHasLabel(card, board, rule.AddLabel) {
+		action.Actions = append(action.Actions, "ajout de l'étiquette "+string(rule.AddLabel))
+	}
+	if rule.MoveToList != "" && card.ListTitle != rule.MoveToList {
+		action.Actions = append(action.Actions, "déplacement vers "+rule.MoveToList)
+	}
+	if len(action.Actions) == 0 {
+		return action, false
+	}
+	if rule.Comment != "" {
+		action.Actions = append(action.Actions, "commentaire")
+	}
+	return action, true
+}
+
+func cardHasLabel(card core.KanbanCard, board libwekan.ConfigBoard, name libwekan.BoardLabelName) bool {
+	for _, label := range board.Board.Labels {
+		if label.Name == name && slices.Contains(card.LabelIDs, label.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCardAction applique les actions de la règle, la première erreur interrompt les actions sur la carte.
+// Le commentaire est écrit une fois l'étiquette et le déplacement réussis, pour ne pas être répété à chaque nouvelle tentative.
+func applyCardAction(ctx context.Context, kanbanService core.KanbanService, rule Rule, card core.KanbanCard, user libwekan.User, action CardAction) CardAction {
+	var err error
+	if rule.AddLabel != "" {
+		params := core.KanbanCardLabelsParams{Add: []libwekan.BoardLabelName{rule.AddLabel}}
+		err = kanbanService.UpdateCardLabels(ctx, card.ID, params, user.Username)
+	}
+	if err == nil && rule.MoveToList != "" && card.ListTitle != rule.MoveToList {
+		err = kanbanService.MoveCardListWithTitle(ctx, libwekan.Card{ID: card.ID, BoardID: card.BoardID}, rule.MoveToList, user)
+	}
+	if err == nil && rule.Comment != "" {
+		_, err = kanbanService.AddCardComment(ctx, card.ID, rule.Comment, user.Username)
+	}
+	if err != nil {
+		action.Error = err.Error()
+	}
+	return action
+}
+
+func logCardAction(action CardAction) {
+	logger := slog.With(
+		slog.String("rule", action.Rule),
+		slog.String("cardID", string(action.CardID)),
+		slog.String("siret", string(action.Siret)),
+		slog.Any("actions", action.Actions),
+		slog.Bool("dryRun", action.DryRun),
+	)
+	if action.Error != "" {
+		logger.Error("erreur pendant l'application d'une règle de carte", slog.String("error", action.Error))
+		return
+	}
+	logger.Info("règle de carte évaluée")
+}
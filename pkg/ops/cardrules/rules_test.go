@@ -0,0 +1,119 @@
+package cardrules
+
+import (
+	"context"
+	"datapi/pkg/core"
+	"datapi/pkg/kanban"
+	"errors"
+	"github.com/signaux-faibles/libwekan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// oneRulesFixture reprend le jeu de données du service kanban en mémoire avec une carte en cours d'accompagnement
+func oneRulesFixture(t *testing.T) kanban.MemoryFixture {
+	fixture, err := kanban.LoadMemoryFixture("../../kanban/testdata/kanban_fixture.json")
+	require.NoError(t, err)
+	fixture.Boards[0].Labels = append(fixture.Boards[0].Labels, libwekan.BoardLabel{Name: "procol", Color: "red"})
+	fixture.Cards[0].List = "Accompagnement en cours"
+	fixture.Cards[0].Labels = nil
+	return fixture
+}
+
+func Test_Rule_validate(t *testing.T) {
+	ass := assert.New(t)
+	ass.NoError(Rule{Name: "procol", Condition: "procol", AddLabel: "procol"}.validate())
+	ass.ErrorContains(Rule{Condition: "procol", AddLabel: "procol"}.validate(), "nom")
+	ass.ErrorContains(Rule{Name: "r", Condition: "radiation", AddLabel: "procol"}.validate(), "condition `radiation` inconnue")
+	ass.ErrorContains(Rule{Name: "r", Condition: "fermeture", Comment: "fermé"}.validate(), "étiquette ou déplacer")
+}
+
+func Test_planCardAction(t *testing.T) {
+	ass := assert.New(t)
+	board := libwekan.ConfigBoard{Board: libwekan.Board{Labels: []libwekan.BoardLabel{{ID: "l1", Name: "procol"}}}}
+	card := core.KanbanCard{ID: "carte", ListTitle: "Accompagnement en cours"}
+	rule := Rule{Name: "procol", Lists: []string{"Accompagnement en cours"}, AddLabel: "procol", Comment: "procol"}
+
+	action, ok := planCardAction(rule, card, board)
+	ass.True(ok)
+	ass.Equal([]string{"ajout de l'étiquette procol", "commentaire"}, action.Actions)
+
+	card.LabelIDs = []libwekan.BoardLabelID{"l1"}
+	_, ok = planCardAction(rule, card, board)
+	ass.False(ok)
+
+	card.LabelIDs = nil
+	card.ListTitle = "A définir"
+	_, ok = planCardAction(rule, card, board)
+	ass.False(ok)
+
+	card.ListTitle = "Accompagnement en cours"
+	card.Archived = true
+	_, ok = planCardAction(rule, card, board)
+	ass.False(ok)
+}
+
+func Test_applyRules(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	service, err := kanban.NewMemoryService(oneRulesFixture(t))
+	require.NoError(t, err)
+	john, _ := service.GetUser("john.doe@zone51.gov")
+	rules := []Rule{{Name: "fermeture", Condition: "fermeture", AddLabel: "procol", MoveToList: "Accompagnement terminé"}}
+	sirets := map[string][]core.Siret{"fermeture": {"12345678900011"}}
+
+	report, err := applyRules(ctx, service, rules, sirets, john, true)
+	ass.NoError(err)
+	require.Len(t, report.Actions, 1)
+	ass.True(report.Actions[0].DryRun)
+	card, _ := service.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.Equal("Accompagnement en cours", card.ListTitle)
+
+	report, err = applyRules(ctx, service, rules, sirets, john, false)
+	ass.NoError(err)
+	require.Len(t, report.Actions, 1)
+	ass.Empty(report.Actions[0].Error)
+	card, _ = service.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.Equal("Accompagnement terminé", card.ListTitle)
+	ass.Len(card.LabelIDs, 1)
+
+	report, err = applyRules(ctx, service, rules, sirets, john, false)
+	ass.NoError(err)
+	ass.Empty(report.Actions)
+}
+
+// failingLabelService refuse les modifications d'étiquettes, comme un service kanban indisponible
+type failingLabelService struct {
+	core.KanbanService
+}
+
+func (failingLabelService) UpdateCardLabels(context.Context, libwekan.CardID, core.KanbanCardLabelsParams, libwekan.Username) error {
+	return errors.New("étiquette refusée")
+}
+
+func Test_applyRules_etiquetteEnEchec(t *testing.T) {
+	ass := assert.New(t)
+	ctx := context.Background()
+	memory, err := kanban.NewMemoryService(oneRulesFixture(t))
+	require.NoError(t, err)
+	service := failingLabelService{memory}
+	john, _ := service.GetUser("john.doe@zone51.gov")
+	rules := []Rule{{Name: "fermeture", Condition: "fermeture", AddLabel: "procol", Comment: "fermé"}}
+	sirets := map[string][]core.Siret{"fermeture": {"12345678900011"}}
+
+	report, err := applyRules(ctx, service, rules, sirets, john, false)
+	ass.NoError(err)
+	require.Len(t, report.Actions, 1)
+	ass.Equal("étiquette refusée", report.Actions[0].Error)
+	card, _ := memory.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.Empty(card.Comments)
+
+	report, err = applyRules(ctx, memory, rules, sirets, john, false)
+	ass.NoError(err)
+	require.Len(t, report.Actions, 1)
+	ass.Empty(report.Actions[0].Error)
+	card, _ = memory.SelectCardFromCardID(ctx, "carte", john.Username)
+	ass.Len(card.Comments, 1)
+	ass.Len(card.LabelIDs, 1)
+}
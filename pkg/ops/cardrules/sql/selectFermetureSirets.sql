@@ -0,0 +1,5 @@
+select distinct s.siret
+from etablissement_follow f
+inner join v_summaries s on s.siret = f.siret
+where f.active
+  and s.etat_administratif = 'F';
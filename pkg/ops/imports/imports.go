@@ -560,5 +560,20 @@ func importStockEtablissement(ctx context.Context) error {
 		return err
 	}
 	slog.Info("Create etablissement table indexes")
-	return CreateEtablissementIndex(ctx)
+	err = CreateEtablissementIndex(ctx)
+	if err != nil {
+		return err
+	}
+	return followEntreprisesEtablissements(ctx)
+}
+
+// followEntreprisesEtablissements étend les suivis d'entreprise aux nouveaux établissements
+func followEntreprisesEtablissements(ctx context.Context) error {
+	var count int
+	err := db.Get().QueryRow(ctx, "select follow_entreprise_etablissements()").Scan(&count)
+	if err != nil {
+		return err
+	}
+	slog.Info("suivi des nouveaux établissements des entreprises suivies", slog.Int("count", count))
+	return nil
 }
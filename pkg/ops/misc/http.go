@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"datapi/pkg/kanban"
 	"datapi/pkg/utils"
 )
 
@@ -14,6 +15,8 @@ import (
 func ConfigureEndpoint(endpoint *gin.RouterGroup) {
 	endpoint.GET("/keycloak", keycloakUsersHandler)
 	endpoint.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	endpoint.POST("/kanban/migrate", kanbanMigrateHandler)
+	endpoint.GET("/kanban/reload", kanbanReloadHandler)
 }
 
 func keycloakUsersHandler(c *gin.Context) {
@@ -24,3 +27,24 @@ func keycloakUsersHandler(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "utilisateurs mis à jour"})
 }
+
+// kanbanReloadHandler recharge la configuration kanban sans attendre le prochain changement détecté
+func kanbanReloadHandler(c *gin.Context) {
+	status, err := kanban.ReloadConfig(c)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// kanbanMigrateHandler copie les données de wekan dans les tables kanban de postgres,
+// à lancer avec le service wekan avant de passer kanbanBackend à "postgres"
+func kanbanMigrateHandler(c *gin.Context) {
+	migration, err := kanban.MigrateWekanToPostgres(c)
+	if err != nil {
+		utils.AbortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, migration)
+}
@@ -52,6 +52,9 @@ func runScript(ctx context.Context, dbase *pgxpool.Pool, exec Script, refresh *R
 		return
 	}
 	refresh.finish()
+	for _, hook := range finishHooks {
+		hook(exec)
+	}
 }
 
 func sqlAsLog(sql string) string {
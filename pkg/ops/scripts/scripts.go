@@ -32,6 +32,13 @@ var Fail = Script{
 
 var list = sync.Map{}
 var last = atomic.Value{}
+var finishHooks []func(Script)
+
+// AddFinishHook enregistre une fonction appelée dans la routine du script après chaque exécution réussie,
+// à appeler au démarrage de l'application
+func AddFinishHook(hook func(Script)) {
+	finishHooks = append(finishHooks, hook)
+}
 
 // Run représente une exécution du script de `refresh` configuré dans l'application
 type Run struct {
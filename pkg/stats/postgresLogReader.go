@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"datapi/pkg/core"
+	"datapi/pkg/utils"
+)
+
+//go:embed resources/sql/select_last_consultations.sql
+var selectLastConsultationsSQL string
+
+//go:embed resources/sql/select_history.sql
+var selectHistorySQL string
+
+//go:embed resources/sql/clear_history.sql
+var clearHistorySQL string
+
+// PostgresLogReader relit les access logs enregistrés par le PostgresLogSaver
+type PostgresLogReader struct {
+	db StatsDB
+}
+
+func NewPostgresLogReader(db StatsDB) *PostgresLogReader {
+	return &PostgresLogReader{db: db}
+}
+
+func NewPostgresLogReaderFromURL(ctx context.Context, connexionURL string) (*PostgresLogReader, error) {
+	statsDB, err := createStatsDBFromURL(ctx, connexionURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "erreur lors de l'initialisation du log reader")
+	}
+	return NewPostgresLogReader(statsDB), nil
+}
+
+// SelectLastConsultations retourne, par username, la date du dernier accès à un des chemins préfixés par `paths`
+func (pgReader *PostgresLogReader) SelectLastConsultations(ctx context.Context, paths []string) (map[string]time.Time, error) {
+	patterns := utils.Convert(paths, func(path string) string { return path + "%" })
+	rows, err := pgReader.db.pool.Query(ctx, selectLastConsultationsSQL, patterns)
+	if err != nil {
+		return nil, errors.Wrap(err, "erreur pendant la requête de sélection des dernières consultations")
+	}
+	defer rows.Close()
+	consultations := make(map[string]time.Time)
+	for rows.Next() {
+		var username *string
+		var lastConsultation time.Time
+		if err := rows.Scan(&username, &lastConsultation); err != nil {
+			return nil, errors.Wrap(err, "erreur pendant la récupération des dernières consultations")
+		}
+		if username != nil {
+			consultations[*username] = lastConsultation
+		}
+	}
+	return consultations, errors.Wrap(rows.Err(), "erreur après la récupération des dernières consultations")
+}
+
+// SelectHistory retourne les dernières fiches distinctes ouvertes par l'utilisateur depuis qu'il a effacé son historique
+func (pgReader *PostgresLogReader) SelectHistory(ctx context.Context, username string, limit int) ([]core.Consultation, error) {
+	rows, err := pgReader.db.pool.Query(ctx, selectHistorySQL, username, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "erreur pendant la requête de sélection de l'historique")
+	}
+	defer rows.Close()
+	history := []core.Consultation{}
+	for rows.Next() {
+		var consultation core.Consultation
+		if err := rows.Scan(&consultation.Type, &consultation.ID, &consultation.Date); err != nil {
+			return nil, errors.Wrap(err, "erreur pendant la récupération de l'historique")
+		}
+		history = append(history, consultation)
+	}
+	return history, errors.Wrap(rows.Err(), "erreur après la récupération de l'historique")
+}
+
+// ClearHistory masque l'historique de consultation de l'utilisateur,
+// les logs servent à la traçabilité des accès et aux statistiques et sont conservés
+func (pgReader *PostgresLogReader) ClearHistory(ctx context.Context, username string) error {
+	_, err := pgReader.db.pool.Exec(ctx, clearHistorySQL, username)
+	return errors.Wrap(err, "erreur pendant l'effacement de l'historique")
+}
@@ -0,0 +1,3 @@
+insert into history_cleared (username, history_cleared_at)
+values ($1, current_timestamp)
+on conflict (username) do update set history_cleared_at = excluded.history_cleared_at;
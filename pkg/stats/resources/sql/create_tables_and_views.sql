@@ -95,3 +95,13 @@ FROM stat_users u
        JOIN stat_export e ON e.mois = u.mois;
 
 alter materialized view v_stats owner to postgres;
+
+--
+-- Name: history_cleared; Type: TABLE
+-- date à laquelle l'utilisateur a effacé son historique de consultation, les logs sont conservés
+--
+
+CREATE TABLE IF NOT EXISTS history_cleared (
+  username           text PRIMARY KEY,
+  history_cleared_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,14 @@
+with consultations as (select split_part(l.path, '/', 2) as type,
+                              split_part(l.path, '/', 4) as id,
+                              max(l.date_add)            as last_consultation
+                       from v_log l
+                              left join history_cleared h on h.username = $1
+                       where l.method = 'GET'
+                         and (l.path like '/entreprise/get/%' or l.path like '/etablissement/get/%')
+                         and l.tokencontent ->> 'preferred_username'::text = $1
+                         and (h.history_cleared_at is null or l.date_add > h.history_cleared_at)
+                       group by split_part(l.path, '/', 2), split_part(l.path, '/', 4))
+select type, id, last_consultation
+from consultations
+order by last_consultation desc
+limit $2;
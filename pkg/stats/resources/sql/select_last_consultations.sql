@@ -0,0 +1,6 @@
+select tokencontent ->> 'preferred_username'::text as username,
+       max(date_add)                                 as last_consultation
+from v_log
+where method = 'GET'
+  and path like any ($1)
+group by tokencontent ->> 'preferred_username'::text;
@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Workbook expose les helpers excel du package aux autres packages
+// les colonnes sont décrites par les tags `col`, `size` et `dateFormat` du type des lignes
+type Workbook struct {
+	xls *excelize.File
+}
+
+// NewWorkbook crée un fichier excel vide
+func NewWorkbook() *Workbook {
+	return &Workbook{xls: newExcel()}
+}
+
+// WriteSheet ajoute une feuille contenant les items, asRow doit respecter l'ordre des champs de A
+func WriteSheet[A any](w *Workbook, sheetName string, items []A, asRow func(A) []any) error {
+	var item A
+	config := anySheetConfig[A]{
+		item:      item,
+		sheetName: sheetName,
+		asRow:     asRow,
+	}
+	rows := make(chan row[A], len(items))
+	for _, item := range items {
+		rows <- newRow(item)
+	}
+	close(rows)
+	return writeOneSheetToExcel(w.xls, config, rows)
+}
+
+// Export écrit le fichier excel dans output
+func (w *Workbook) Export(output io.Writer) error {
+	return exportTo(output, w.xls)
+}
+
+// Close libère les ressources du fichier excel
+func (w *Workbook) Close() error {
+	return w.xls.Close()
+}